@@ -43,7 +43,7 @@ import { Node, Link } from '../../../../models/topology.model';
 
           <!-- Acciones Rápidas -->
           <div class="grid grid-cols-2 gap-2">
-            <button (click)="openTerminal.emit(selectedNode()?.name!)"
+            <button (click)="openTerminal.emit(selectedNode()?.id!)"
                     class="bg-slate-800 hover:bg-slate-700 text-slate-300 text-xs py-2 px-3 rounded border border-slate-700 flex items-center justify-center gap-2">
               <span>Terminal</span>
             </button>
@@ -17,9 +17,12 @@ import { ToastComponent } from '../../shared/components/toast/toast.component';
 export class DashboardComponent implements OnInit {
   readonly store = inject(TopologyStore);
   
-  // Estado para gestión de terminales (Tabs)
+  // Estado para gestión de terminales (Tabs, por ID de nodo)
   activeTerminals = signal<string[]>([]);
   activeTab = signal<string | null>(null);
+  terminalLabels = computed(() =>
+    Object.fromEntries(this.store.topology().nodes.map(n => [n.id, n.name]))
+  );
   
   // Selección de nodo y link
   selectedNodeId = signal<string | null>(null);
@@ -72,26 +75,26 @@ export class DashboardComponent implements OnInit {
     this.selectedLinkId.set(null);
   }
 
-  openTerminal(nodeName: string) {
+  openTerminal(nodeId: string) {
     // Si no está abierta, añadirla
-    if (!this.activeTerminals().includes(nodeName)) {
-      this.activeTerminals.update(list => [...list, nodeName]);
+    if (!this.activeTerminals().includes(nodeId)) {
+      this.activeTerminals.update(list => [...list, nodeId]);
     }
     // Enfocarla
-    this.activeTab.set(nodeName);
+    this.activeTab.set(nodeId);
   }
 
-  closeTerminal(nodeName: string) {
-    this.activeTerminals.update(list => list.filter(n => n !== nodeName));
+  closeTerminal(nodeId: string) {
+    this.activeTerminals.update(list => list.filter(n => n !== nodeId));
     
     // Si cerramos la activa, cambiar foco
-    if (this.activeTab() === nodeName) {
+    if (this.activeTab() === nodeId) {
       const remaining = this.activeTerminals();
       this.activeTab.set(remaining.length > 0 ? remaining[remaining.length - 1] : null);
     }
   }
 
-  setActiveTab(nodeName: string) {
-    this.activeTab.set(nodeName);
+  setActiveTab(nodeId: string) {
+    this.activeTab.set(nodeId);
   }
 }
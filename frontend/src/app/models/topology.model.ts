@@ -15,10 +15,18 @@ export interface Node {
   image: string;
   x?: number;
   y?: number;
-  status?: 'pending' | 'running' | 'error';
+  startup?: StartupConfig;
+  status?: 'running' | 'stopped' | 'paused' | 'missing' | 'error';
   interfaces?: InterfaceInfo[]; // Runtime info
 }
 
+export interface StartupConfig {
+  frr_conf?: string;
+  daemons?: string;
+  files?: { [path: string]: string };
+  commands?: string[];
+}
+
 export interface Link {
   id: string;
   source: string;
@@ -11,10 +11,12 @@ import { FitAddon } from 'xterm-addon-fit';
   styleUrl: './terminal-panel.component.scss'
 })
 export class TerminalPanelComponent implements AfterViewInit, OnDestroy {
-  // Inputs: Lista de nodos que tienen terminal abierta
+  // Inputs: Lista de nodos (IDs) que tienen terminal abierta
   activeNodes = input.required<string[]>();
   // Input: Cuál es la pestaña activa
   activeTab = input.required<string | null>();
+  // Input: Nombre a mostrar por ID de nodo
+  labels = input<Record<string, string>>({});
 
   closeTerminal = output<string>();
   selectTab = output<string>();
@@ -56,8 +58,8 @@ export class TerminalPanelComponent implements AfterViewInit, OnDestroy {
     this.terminals.clear();
   }
 
-  setActiveTab(nodeName: string) {
-    this.selectTab.emit(nodeName);
+  setActiveTab(nodeId: string) {
+    this.selectTab.emit(nodeId);
   }
 
   clearActiveTerminal() {
@@ -72,11 +74,11 @@ export class TerminalPanelComponent implements AfterViewInit, OnDestroy {
 
   private syncTerminals(nodes: string[]) {
     // 1. Eliminar terminales cerradas
-    for (const [name, instance] of this.terminals) {
-      if (!nodes.includes(name)) {
+    for (const [id, instance] of this.terminals) {
+      if (!nodes.includes(id)) {
         instance.socket.close();
         instance.term.dispose();
-        this.terminals.delete(name);
+        this.terminals.delete(id);
       }
     }
 
@@ -84,14 +86,15 @@ export class TerminalPanelComponent implements AfterViewInit, OnDestroy {
     if (!this.termContainers) return;
 
     this.termContainers.forEach((el) => {
-      const nodeName = el.nativeElement.getAttribute('data-node');
-      if (nodes.includes(nodeName) && !this.terminals.has(nodeName)) {
-        this.createTerminal(nodeName, el.nativeElement);
+      const nodeId = el.nativeElement.getAttribute('data-node');
+      if (nodes.includes(nodeId) && !this.terminals.has(nodeId)) {
+        this.createTerminal(nodeId, el.nativeElement);
       }
     });
   }
 
-  private createTerminal(nodeName: string, container: HTMLElement) {
+  private createTerminal(nodeId: string, container: HTMLElement) {
+    const nodeName = this.labels()[nodeId] || nodeId;
     const term = new Terminal({
       cursorBlink: true,
       theme: {
@@ -110,31 +113,41 @@ export class TerminalPanelComponent implements AfterViewInit, OnDestroy {
     term.open(container);
     fitAddon.fit();
 
-    // WebSocket Connection
-    const wsUrl = `ws://localhost:8080/api/v1/terminal?node=${nodeName}`;
+    // WebSocket Connection (binario: salida de la TTY y teclado; texto: control)
+    const wsUrl = `ws://localhost:8080/api/v1/nodes/${encodeURIComponent(nodeId)}/terminal?cols=${term.cols}&rows=${term.rows}`;
     const socket = new WebSocket(wsUrl);
+    socket.binaryType = 'arraybuffer';
+    const encoder = new TextEncoder();
+
+    const sendResize = (cols: number, rows: number) => {
+      if (socket.readyState === WebSocket.OPEN) {
+        socket.send(JSON.stringify({ type: 'resize', cols, rows }));
+      }
+    };
 
     socket.onopen = () => {
       term.writeln(`\x1b[32m✔ Connected to ${nodeName}\x1b[0m`);
       fitAddon.fit();
+      sendResize(term.cols, term.rows);
     };
 
     socket.onmessage = (event) => {
-      if (event.data instanceof Blob) {
-        const reader = new FileReader();
-        reader.onload = () => term.write(reader.result as string);
-        reader.readAsText(event.data);
+      // xterm reensambla los caracteres UTF-8 partidos entre frames
+      if (event.data instanceof ArrayBuffer) {
+        term.write(new Uint8Array(event.data));
       } else {
         term.write(event.data);
       }
     };
 
     term.onData(data => {
-      if (socket.readyState === WebSocket.OPEN) socket.send(data);
+      if (socket.readyState === WebSocket.OPEN) socket.send(encoder.encode(data));
     });
 
+    term.onResize(({ cols, rows }) => sendResize(cols, rows));
+
     socket.onclose = () => term.writeln('\r\n\x1b[31m✖ Connection closed.\x1b[0m');
 
-    this.terminals.set(nodeName, { term, fit: fitAddon, socket });
+    this.terminals.set(nodeId, { term, fit: fitAddon, socket });
   }
 }
@@ -11,7 +11,8 @@ import { FitAddon } from 'xterm-addon-fit';
   styleUrl: './terminal-window.component.scss'
 })
 export class TerminalWindowComponent implements AfterViewInit, OnDestroy {
-  nodeName = input.required<string>();
+  nodeId = input.required<string>();
+  nodeName = input<string>('');
   close = output<void>();
 
   @ViewChild('terminal') terminalDiv!: ElementRef;
@@ -51,32 +52,33 @@ export class TerminalWindowComponent implements AfterViewInit, OnDestroy {
     this.term.open(this.terminalDiv.nativeElement);
     this.fitAddon.fit();
 
-    // Enviar teclas al socket
+    // Enviar teclas al socket (frames binarios)
+    const encoder = new TextEncoder();
     this.term.onData(data => {
       if (this.socket && this.socket.readyState === WebSocket.OPEN) {
-        this.socket.send(data);
+        this.socket.send(encoder.encode(data));
       }
     });
+
+    // Avisar al servidor los cambios de tamaño (frames de texto de control)
+    this.term.onResize(({ cols, rows }) => this.sendResize(cols, rows));
   }
 
   private connectWebSocket() {
-    const wsUrl = `ws://localhost:8080/api/v1/terminal?node=${this.nodeName()}`;
+    const wsUrl = `ws://localhost:8080/api/v1/nodes/${encodeURIComponent(this.nodeId())}/terminal?cols=${this.term.cols}&rows=${this.term.rows}`;
     this.socket = new WebSocket(wsUrl);
+    this.socket.binaryType = 'arraybuffer';
 
     this.socket.onopen = () => {
-      this.term.writeln('\x1b[32m✔ Connected to ' + this.nodeName() + '\x1b[0m');
+      this.term.writeln('\x1b[32m✔ Connected to ' + (this.nodeName() || this.nodeId()) + '\x1b[0m');
       this.fitAddon.fit();
+      this.sendResize(this.term.cols, this.term.rows);
     };
 
     this.socket.onmessage = (event) => {
-      // Recibimos datos crudos del pty
-      // Usamos FileReader si viene como Blob (común en WS binarios)
-      if (event.data instanceof Blob) {
-        const reader = new FileReader();
-        reader.onload = () => {
-          this.term.write(reader.result as string);
-        };
-        reader.readAsText(event.data);
+      // Recibimos datos crudos del pty (binario: xterm decodifica el UTF-8)
+      if (event.data instanceof ArrayBuffer) {
+        this.term.write(new Uint8Array(event.data));
       } else {
         this.term.write(event.data);
       }
@@ -91,4 +93,10 @@ export class TerminalWindowComponent implements AfterViewInit, OnDestroy {
       console.error('WS Error', err);
     };
   }
+
+  private sendResize(cols: number, rows: number) {
+    if (this.socket && this.socket.readyState === WebSocket.OPEN) {
+      this.socket.send(JSON.stringify({ type: 'resize', cols, rows }));
+    }
+  }
 }
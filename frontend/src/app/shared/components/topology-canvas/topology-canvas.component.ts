@@ -68,7 +68,7 @@ export class TopologyCanvasComponent implements AfterViewInit, OnDestroy {
 
   onContextMenuAction(action: 'terminal' | 'delete' | 'properties') {
     if (action === 'terminal') {
-      this.openTerminalRequest.emit(this.contextMenu.elementName);
+      this.openTerminalRequest.emit(this.contextMenu.elementId);
     } else if (action === 'properties') {
       if (this.contextMenu.elementType === 'edge') {
         this.linkSelected.emit(this.contextMenu.elementId);
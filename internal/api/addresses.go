@@ -0,0 +1,207 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"open-veth/internal/events"
+	"open-veth/internal/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Interface Address Handlers ---
+
+// listInterfaceAddresses returns the stored addresses of an interface merged with kernel state
+func (s *Server) listInterfaceAddresses(c *gin.Context) {
+	node, found := s.repo.GetNode(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+	ifname := c.Param("ifname")
+
+	stored, err := s.repo.ListAddresses(node.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	live := map[string]bool{}
+	if node.Type != models.SWITCH && requireNamespace(node) == nil {
+		cidrs, err := s.network.ListInterfaceIPs(node.PID, ifname)
+		if err == nil {
+			for _, cidr := range cidrs {
+				live[cidr] = true
+			}
+		}
+	}
+
+	result := make([]models.InterfaceAddress, 0)
+	for _, addr := range stored {
+		if addr.Interface != ifname {
+			continue
+		}
+		addr.Applied = live[addr.Address]
+		delete(live, addr.Address)
+		result = append(result, addr)
+	}
+
+	// Addresses configured by hand inside the node (or link-local) are reported without ID
+	for cidr := range live {
+		result = append(result, models.InterfaceAddress{NodeID: node.ID, Interface: ifname, Address: cidr, Applied: true})
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// addInterfaceAddress assigns an IPv4/IPv6 address to a node interface and persists it
+func (s *Server) addInterfaceAddress(c *gin.Context) {
+	node, found := s.repo.GetNode(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+	if node.Type == models.SWITCH {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "switch nodes have no addressable interfaces"})
+		return
+	}
+	if err := requireNamespace(node); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req struct {
+		Address string `json:"address" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	cidr, err := normalizeCIDR(req.Address)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	addr := models.InterfaceAddress{NodeID: node.ID, Interface: c.Param("ifname"), Address: cidr, Source: models.AddressManual}
+
+	// Stored manual addresses are reserved by labAllocator, so the pool must not hand
+	// out anything between this check and AddAddress
+	s.ipamMu.Lock()
+	defer s.ipamMu.Unlock()
+
+	existing, _ := s.repo.ListAddresses(node.ID)
+	for _, a := range existing {
+		if a.Interface == addr.Interface && a.Address == addr.Address {
+			c.JSON(http.StatusConflict, gin.H{"error": "address already assigned to this interface"})
+			return
+		}
+	}
+	if taken, found := s.ipamConflict(node.TopologyID, addr.Address); found {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("address overlaps %s allocated by IPAM to %s/%s", taken.Address, taken.NodeID, taken.Interface)})
+		return
+	}
+
+	if err := s.network.SetInterfaceIP(node.PID, addr.Interface, addr.Address); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	addr, err = s.repo.AddAddress(addr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	addr.Applied = true
+	s.publish(events.Event{Type: events.AddressAdded, TopologyID: node.TopologyID, NodeID: node.ID, Data: addr})
+	c.JSON(http.StatusCreated, addr)
+}
+
+// deleteInterfaceAddress removes an address from the kernel and from storage
+func (s *Server) deleteInterfaceAddress(c *gin.Context) {
+	node, found := s.repo.GetNode(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+
+	id, err := strconv.ParseUint(c.Param("addrId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid address id"})
+		return
+	}
+
+	addr, found := s.repo.GetAddress(uint(id))
+	if !found || addr.NodeID != node.ID || addr.Interface != c.Param("ifname") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "address not found"})
+		return
+	}
+	if err := requireNamespace(node); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.network.RemoveInterfaceIP(node.PID, addr.Interface, addr.Address); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.repo.DeleteAddress(addr.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	s.publish(events.Event{Type: events.AddressRemoved, TopologyID: node.TopologyID, NodeID: node.ID, Data: addr})
+	c.Status(http.StatusNoContent)
+}
+
+// restoreAddresses re-applies the persisted addresses of one interface of a (re)created node
+func (s *Server) restoreAddresses(node models.Node, ifname string) error {
+	if node.Type == models.SWITCH {
+		return nil
+	}
+	if err := requireNamespace(node); err != nil {
+		return err
+	}
+
+	addrs, err := s.repo.ListAddresses(node.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, addr := range addrs {
+		if addr.Interface != ifname {
+			continue
+		}
+		if err := s.network.SetInterfaceIP(node.PID, addr.Interface, addr.Address); err != nil {
+			return fmt.Errorf("error restoring %s on %s/%s: %v", addr.Address, node.Name, ifname, err)
+		}
+	}
+	return nil
+}
+
+// errNotRunning marks kernel operations refused because the node has no namespace
+var errNotRunning = errors.New("node is not running")
+
+// requireNamespace fails unless the node runs (or is paused) with a known PID.
+// Stopped nodes keep PID 0, which must never reach the kernel.
+func requireNamespace(node models.Node) error {
+	if node.PID <= 0 || !nodeUp(node) {
+		return fmt.Errorf("%w: %s is %s", errNotRunning, node.Name, node.Status)
+	}
+	return nil
+}
+
+// normalizeCIDR validates an interface address and returns it in canonical form
+func normalizeCIDR(value string) (string, error) {
+	ip, ipnet, err := net.ParseCIDR(value)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: expected CIDR notation (e.g. 10.0.0.1/30)", value)
+	}
+	ones, _ := ipnet.Mask.Size()
+	return fmt.Sprintf("%s/%d", ip, ones), nil
+}
@@ -0,0 +1,592 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"open-veth/internal/capture"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Capture session limits
+const (
+	defaultSummaryLimit = 500
+	maxSummaryLimit     = 5000
+	maxSummaryPackets   = 200000 // Packets decoded per summary request
+	maxCaptureDuration  = 24 * time.Hour
+)
+
+// captureRequest starts a named capture on one or more link ends
+type captureRequest struct {
+	Name    string `json:"name" binding:"required"`
+	Targets []struct {
+		LinkID string `json:"link_id" binding:"required"`
+		End    string `json:"end"` // source (default) | target
+	} `json:"targets" binding:"required,min=1"`
+	Filter      string  `json:"filter"` // pcap-filter expression or 'tcpdump -ddd' output
+	Snaplen     int     `json:"snaplen"`
+	MaxFileSize int64   `json:"max_file_size"` // Rotate after this many bytes
+	MaxFiles    int     `json:"max_files"`     // Ring buffer: files kept per end
+	DurationSec float64 `json:"duration_sec"`
+	MaxPackets  uint64  `json:"max_packets"` // Per end
+}
+
+func (req captureRequest) validate() error {
+	if !sessionNamePattern.MatchString(req.Name) {
+		return fmt.Errorf("invalid capture name")
+	}
+	if req.Snaplen != 0 && (req.Snaplen < 64 || req.Snaplen > defaultSnaplen) {
+		return fmt.Errorf("snaplen must be between 64 and %d", defaultSnaplen)
+	}
+	if req.MaxFileSize < 0 || req.MaxFiles < 0 || req.DurationSec < 0 {
+		return fmt.Errorf("limits cannot be negative")
+	}
+	if req.MaxFiles > 0 && req.MaxFileSize == 0 {
+		return fmt.Errorf("max_files requires max_file_size")
+	}
+	if req.DurationSec > maxCaptureDuration.Seconds() {
+		return fmt.Errorf("duration_sec cannot exceed %.0f", maxCaptureDuration.Seconds())
+	}
+	// An unbounded capture would fill the disk: it must rotate, time out or count packets
+	if req.MaxFiles == 0 && req.DurationSec == 0 && req.MaxPackets == 0 {
+		return fmt.Errorf("a capture needs a limit: max_files, duration_sec or max_packets")
+	}
+	return nil
+}
+
+// captureRun is a capture session in progress
+type captureRun struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	session models.CaptureSession
+}
+
+// snapshot returns a copy of the session with the live counters
+func (r *captureRun) snapshot() models.CaptureSession {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cs := r.session
+	cs.Targets = append([]models.CaptureTarget(nil), r.session.Targets...)
+	return cs
+}
+
+// captureEndpoint is an open socket and its rotating files
+type captureEndpoint struct {
+	sock orchestrator.PacketSource
+	ring *capture.Ring
+}
+
+// --- Capture Handlers ---
+
+// createCapture opens a socket on every requested link end and records them in the
+// background to <capturesDir>/<id>/<link>-<end>-NNNN.pcapng
+func (s *Server) createCapture(c *gin.Context) {
+	var req captureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := req.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	filter, err := capture.Compile(req.Filter)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Snaplen == 0 {
+		req.Snaplen = defaultSnaplen
+	}
+
+	// 1. Resolve every end before opening anything
+	session := models.CaptureSession{
+		Name:           req.Name,
+		Filter:         req.Filter,
+		Snaplen:        req.Snaplen,
+		MaxFileSize:    req.MaxFileSize,
+		MaxFiles:       req.MaxFiles,
+		MaxDurationSec: req.DurationSec,
+		MaxPackets:     req.MaxPackets,
+		Status:         models.CaptureRunning,
+		StartedAt:      time.Now(),
+	}
+	var pids []int
+	seen := make(map[string]bool)
+	for _, t := range req.Targets {
+		link, found := s.repo.GetLink(t.LinkID)
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("link %s not found", t.LinkID)})
+			return
+		}
+		if session.TopologyID == "" {
+			session.TopologyID = link.TopologyID
+		} else if link.TopologyID != session.TopologyID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "all links must belong to the same lab"})
+			return
+		}
+		if t.End == "" {
+			t.End = "source"
+		}
+		if seen[t.LinkID+"/"+t.End] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("link %s %s is listed twice", t.LinkID, t.End)})
+			return
+		}
+		seen[t.LinkID+"/"+t.End] = true
+
+		src, status, err := s.captureEnd(link, t.End)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		session.Targets = append(session.Targets, models.CaptureTarget{
+			LinkID:    link.ID,
+			End:       t.End,
+			NodeName:  src.name,
+			Interface: src.iface,
+		})
+		pids = append(pids, src.pid)
+	}
+
+	// 2. Open the sockets, so errors are reported before anything is stored
+	endpoints := make([]captureEndpoint, len(session.Targets))
+	closeAll := func() {
+		for _, ep := range endpoints {
+			if ep.sock != nil {
+				ep.sock.Close()
+			}
+			if ep.ring != nil {
+				ep.ring.Close()
+			}
+		}
+	}
+	for i, t := range session.Targets {
+		sock, err := s.network.OpenPacketSocket(pids[i], t.Interface, filter)
+		if err != nil {
+			closeAll()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		endpoints[i].sock = sock
+	}
+
+	// 3. Store the session and create its files
+	session, err = s.repo.AddCapture(session)
+	if err != nil {
+		closeAll()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error saving capture: %v", err)})
+		return
+	}
+	session.Dir = filepath.Join(s.capturesDir, strconv.FormatUint(uint64(session.ID), 10))
+	for i, t := range session.Targets {
+		prefix := filepath.Base(fmt.Sprintf("%s-%s", t.LinkID, t.End))
+		ring, err := capture.NewRing(session.Dir, prefix, t.NodeName+":"+t.Interface, uint32(session.Snaplen), session.MaxFileSize, session.MaxFiles)
+		if err != nil {
+			closeAll()
+			_ = os.RemoveAll(session.Dir)
+			_ = s.repo.DeleteCapture(session.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		endpoints[i].ring = ring
+	}
+	if err := s.repo.SaveCapture(session); err != nil {
+		log.Printf("Warning: could not save capture %d: %v", session.ID, err)
+	}
+
+	// 4. Record in the background until stopped, timed out or every end hit max_packets
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if session.MaxDurationSec > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(session.MaxDurationSec*float64(time.Second)))
+	} else {
+		ctx, cancel = context.WithCancel(context.Background())
+	}
+	run := &captureRun{cancel: cancel, done: make(chan struct{}), session: session}
+
+	s.capturesMu.Lock()
+	s.captures[session.ID] = run
+	s.capturesMu.Unlock()
+
+	var wg sync.WaitGroup
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.recordCaptureEnd(ctx, run, i, ep, filter)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		s.finishCapture(run)
+	}()
+
+	c.JSON(http.StatusCreated, run.snapshot())
+}
+
+// recordCaptureEnd copies the packets of one socket to its ring until ctx is done
+func (s *Server) recordCaptureEnd(ctx context.Context, run *captureRun, i int, ep captureEndpoint, filter *capture.Filter) {
+	defer ep.ring.Close()
+	defer ep.sock.Close()
+
+	buf := make([]byte, run.session.Snaplen)
+	var packets, bytes uint64
+	var runErr error
+	for run.session.MaxPackets == 0 || packets < run.session.MaxPackets {
+		n, origLen, dir, err := ep.sock.ReadPacket(ctx, buf)
+		if err != nil {
+			if ctx.Err() == nil {
+				runErr = err
+			}
+			break
+		}
+		if filter != nil {
+			pkt := capture.Decode(buf[:n])
+			if !filter.Match(&pkt) {
+				continue
+			}
+		}
+		if err := ep.ring.WritePacket(time.Now(), buf[:n], origLen, dir); err != nil {
+			runErr = err
+			break
+		}
+
+		packets++
+		bytes += uint64(origLen)
+		run.mu.Lock()
+		run.session.Targets[i].Packets, run.session.Targets[i].Bytes = packets, bytes
+		run.mu.Unlock()
+	}
+
+	dropped, _ := ep.sock.Dropped()
+	run.mu.Lock()
+	run.session.Targets[i].Dropped = dropped
+	if runErr != nil {
+		t := run.session.Targets[i]
+		log.Printf("Capture %d on %s/%s stopped: %v", run.session.ID, t.NodeName, t.Interface, runErr)
+		run.session.Targets[i].Error = runErr.Error()
+	}
+	run.mu.Unlock()
+}
+
+// finishCapture stores the final counters of a session that stopped
+func (s *Server) finishCapture(run *captureRun) {
+	run.mu.Lock()
+	now := time.Now()
+	run.session.StoppedAt = &now
+	run.session.Status = models.CaptureStopped
+
+	failed := 0
+	for _, t := range run.session.Targets {
+		if t.Error != "" {
+			failed++
+		}
+	}
+	if failed == len(run.session.Targets) {
+		run.session.Status = models.CaptureFailed
+		run.session.Error = "every capture end failed"
+	}
+	session := run.session
+	run.mu.Unlock()
+
+	if err := s.repo.SaveCapture(session); err != nil {
+		log.Printf("Warning: could not save capture %d: %v", session.ID, err)
+	}
+
+	s.capturesMu.Lock()
+	delete(s.captures, session.ID)
+	s.capturesMu.Unlock()
+	close(run.done)
+}
+
+// stopLabCaptures ends the running capture sessions of a lab and waits for their files
+func (s *Server) stopLabCaptures(topologyID string) {
+	s.capturesMu.Lock()
+	var runs []*captureRun
+	for _, run := range s.captures {
+		if run.snapshot().TopologyID == topologyID {
+			runs = append(runs, run)
+		}
+	}
+	s.capturesMu.Unlock()
+
+	for _, run := range runs {
+		run.cancel()
+		<-run.done
+	}
+}
+
+// recoverCaptures marks sessions left running by a previous process as failed
+func (s *Server) recoverCaptures() {
+	sessions, err := s.repo.ListCaptures("")
+	if err != nil {
+		return
+	}
+	for _, cs := range sessions {
+		if cs.Status != models.CaptureRunning {
+			continue
+		}
+		now := time.Now()
+		cs.Status, cs.Error, cs.StoppedAt = models.CaptureFailed, "interrupted by a server restart", &now
+		if err := s.repo.SaveCapture(cs); err != nil {
+			log.Printf("Warning: could not save capture %d: %v", cs.ID, err)
+		}
+	}
+}
+
+// liveCapture returns the session with its live counters when it is still running
+func (s *Server) liveCapture(cs models.CaptureSession) models.CaptureSession {
+	s.capturesMu.Lock()
+	run, ok := s.captures[cs.ID]
+	s.capturesMu.Unlock()
+	if ok {
+		return run.snapshot()
+	}
+	return cs
+}
+
+// listCaptures returns capture sessions, optionally of one lab (?topology=)
+func (s *Server) listCaptures(c *gin.Context) {
+	list, err := s.repo.ListCaptures(c.Query("topology"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	for i := range list {
+		list[i] = s.liveCapture(list[i])
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+func (s *Server) getCapture(c *gin.Context) {
+	cs, ok := s.captureParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, s.liveCapture(cs))
+}
+
+// stopCapture ends a running session and waits for its files to be closed
+func (s *Server) stopCapture(c *gin.Context) {
+	cs, ok := s.captureParam(c)
+	if !ok {
+		return
+	}
+
+	s.capturesMu.Lock()
+	run, running := s.captures[cs.ID]
+	s.capturesMu.Unlock()
+	if !running {
+		c.JSON(http.StatusConflict, gin.H{"error": "capture is not running"})
+		return
+	}
+
+	run.cancel()
+	<-run.done
+	cs, _ = s.repo.GetCapture(cs.ID)
+	c.JSON(http.StatusOK, cs)
+}
+
+// deleteCapture removes a stopped session and its files
+func (s *Server) deleteCapture(c *gin.Context) {
+	cs, ok := s.captureParam(c)
+	if !ok {
+		return
+	}
+	if s.liveCapture(cs).Status == models.CaptureRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "capture is still running, stop it first"})
+		return
+	}
+	if err := os.RemoveAll(cs.Dir); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.repo.DeleteCapture(cs.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// captureFile is a pcapng file of a session
+type captureFile struct {
+	Name     string    `json:"name"`
+	Source   string    `json:"source"` // <link>-<end>
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// captureFiles lists the files still on disk (old ones rotate out), sorted by name
+func captureFiles(dir string) ([]captureFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	files := make([]captureFile, 0, len(entries))
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !strings.HasSuffix(e.Name(), ".pcapng") {
+			continue
+		}
+		source := strings.TrimSuffix(e.Name(), ".pcapng")
+		if i := strings.LastIndex(source, "-"); i > 0 {
+			source = source[:i]
+		}
+		files = append(files, captureFile{Name: e.Name(), Source: source, Size: info.Size(), Modified: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	return files, nil
+}
+
+func (s *Server) listCaptureFiles(c *gin.Context) {
+	cs, ok := s.captureParam(c)
+	if !ok {
+		return
+	}
+	files, err := captureFiles(cs.Dir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, files)
+}
+
+// downloadCaptureFile serves one pcapng file of a session
+func (s *Server) downloadCaptureFile(c *gin.Context) {
+	cs, ok := s.captureParam(c)
+	if !ok {
+		return
+	}
+	name := c.Param("file")
+	if filepath.Base(name) != name || !strings.HasSuffix(name, ".pcapng") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file name"})
+		return
+	}
+	path := filepath.Join(cs.Dir, name)
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("%s-%s", cs.Name, name)))
+	c.Header("Content-Type", "application/x-pcapng")
+	c.File(path)
+}
+
+// captureSummary decodes the files of a session: per-protocol counters and a
+// packet list sorted by time. ?protocol= filters the list, ?offset= and ?limit= page it.
+func (s *Server) captureSummary(c *gin.Context) {
+	cs, ok := s.captureParam(c)
+	if !ok {
+		return
+	}
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSummaryLimit)))
+	if err != nil || limit <= 0 || limit > maxSummaryLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSummaryLimit)})
+		return
+	}
+	protocol := c.Query("protocol")
+
+	files, err := captureFiles(cs.Dir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	sum := capture.NewSummarizer()
+	var packets []capture.PacketInfo
+	for _, f := range files {
+		if err := summarizeFile(filepath.Join(cs.Dir, f.Name), f.Source, sum, &packets); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%s: %v", f.Name, err)})
+			return
+		}
+	}
+
+	truncated := sum.Packets >= maxSummaryPackets
+
+	// Files are read one after the other: interleave the ends by time
+	sort.SliceStable(packets, func(i, j int) bool { return packets[i].Time.Before(packets[j].Time) })
+	list := make([]capture.PacketInfo, 0, limit)
+	matched := 0
+	for i := range packets {
+		packets[i].No = i + 1
+		if protocol != "" && packets[i].Protocol != protocol {
+			continue
+		}
+		if matched >= offset && len(list) < limit {
+			list = append(list, packets[i])
+		}
+		matched++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"capture":   s.liveCapture(cs),
+		"summary":   sum.Summary,
+		"total":     matched,
+		"offset":    offset,
+		"limit":     limit,
+		"truncated": truncated, // More than maxSummaryPackets: download the files instead
+		"packets":   list,
+	})
+}
+
+// summarizeFile decodes a pcapng file into the summary. A file being written is
+// read up to its last complete packet.
+func summarizeFile(path, source string, sum *capture.Summarizer, packets *[]capture.PacketInfo) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r, err := capture.NewReader(f)
+	if err != nil {
+		return err
+	}
+	for sum.Packets < maxSummaryPackets {
+		rec, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		*packets = append(*packets, sum.Add(rec, source))
+	}
+	return nil
+}
+
+// captureParam loads the capture session of the URL. It writes the error response itself.
+func (s *Server) captureParam(c *gin.Context) (models.CaptureSession, bool) {
+	id, err := strconv.ParseUint(c.Param("capId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid capture id"})
+		return models.CaptureSession{}, false
+	}
+	cs, found := s.repo.GetCapture(uint(id))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "capture not found"})
+		return cs, false
+	}
+	return cs, true
+}
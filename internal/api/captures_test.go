@@ -0,0 +1,55 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCaptureRequestValidate(t *testing.T) {
+	ok := captureRequest{Name: "ospf-area0", MaxFileSize: 1 << 20, MaxFiles: 4}
+	if err := ok.validate(); err != nil {
+		t.Errorf("error inesperado: %v", err)
+	}
+	for _, req := range []captureRequest{{Name: "x", DurationSec: 60}, {Name: "x", MaxPackets: 100}} {
+		if err := req.validate(); err != nil {
+			t.Errorf("%+v: error inesperado: %v", req, err)
+		}
+	}
+
+	bad := []captureRequest{
+		{Name: "con espacios"},
+		{Name: "x", Snaplen: 10},
+		{Name: "x", MaxFiles: 3}, // Ring sin tamaño de archivo
+		{Name: "x", DurationSec: -1},
+		{Name: "x"}, // Sin límite llenaría el disco
+		{Name: "x", MaxFileSize: 1 << 20},
+		{Name: "x", DurationSec: 1e12}, // Desborda time.Duration
+	}
+	for _, req := range bad {
+		if err := req.validate(); err == nil {
+			t.Errorf("se esperaba un error para %+v", req)
+		}
+	}
+}
+
+// TestCaptureFiles verifica el orden y el extremo de cada archivo
+func TestCaptureFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"link-b-source-0002.pcapng", "link-a-target-0001.pcapng", "notas.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := captureFiles(dir)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if len(files) != 2 || files[0].Source != "link-a-target" || files[1].Source != "link-b-source" {
+		t.Errorf("archivos inesperados: %+v", files)
+	}
+	if files, err := captureFiles(filepath.Join(dir, "no-existe")); err != nil || len(files) != 0 {
+		t.Errorf("un directorio que no existe debería ser una lista vacía")
+	}
+}
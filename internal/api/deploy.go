@@ -0,0 +1,422 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"open-veth/internal/events"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DeployResult describes the outcome of a single topology element
+type DeployResult struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`             // "node" | "link"
+	Action string `json:"action,omitempty"` // Plan action when applying a lab file
+	Status string `json:"status"`           // "ok" | "error" | "skipped"
+	Error  string `json:"error,omitempty"`
+}
+
+// DeployReport is returned by the batch deploy endpoint
+type DeployReport struct {
+	TopologyID string         `json:"topology_id"`
+	Nodes      []DeployResult `json:"nodes"`
+	Links      []DeployResult `json:"links"`
+}
+
+// Failed reports whether any element could not be deployed
+func (r DeployReport) Failed() bool {
+	for _, res := range append(r.Nodes, r.Links...) {
+		if res.Status != "ok" {
+			return true
+		}
+	}
+	return false
+}
+
+// deployTopology creates every node and link of a topology in one request
+func (s *Server) deployTopology(c *gin.Context) {
+	var topo models.Topology
+	if err := c.ShouldBindJSON(&topo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if topo.ID == "" {
+		topo.ID = models.DefaultTopologyID
+	}
+	if !topologyIDPattern.MatchString(topo.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid topology id"})
+		return
+	}
+
+	// Persist the lab itself before its contents
+	if err := s.saveTopologyMeta(topo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	report := s.deploy(c.Request.Context(), topo)
+
+	status := http.StatusOK
+	if report.Failed() {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, report)
+}
+
+// deploy provisions nodes first and then wires links between the nodes
+// that came up. Failures are recorded per element and never abort the batch.
+func (s *Server) deploy(ctx context.Context, topo models.Topology) DeployReport {
+	report := DeployReport{
+		TopologyID: topo.ID,
+		Nodes:      make([]DeployResult, 0, len(topo.Nodes)),
+		Links:      make([]DeployResult, 0, len(topo.Links)),
+	}
+
+	// 1. Nodes
+	ready := make(map[string]models.Node)
+	for _, node := range topo.Nodes {
+		res := DeployResult{ID: node.ID, Kind: "node", Status: "ok"}
+		node.TopologyID = topo.ID
+
+		err := s.checkNodeBudget(node)
+		var created models.Node
+		if err == nil {
+			created, err = s.provisionNode(ctx, node)
+		}
+		if err != nil {
+			res.Status = "error"
+			res.Error = err.Error()
+		} else {
+			ready[created.ID] = created
+		}
+		report.Nodes = append(report.Nodes, res)
+		s.publishProgress(topo.ID, res)
+	}
+
+	// 2. Links (only between nodes that are running)
+	for _, link := range topo.Links {
+		res := DeployResult{ID: link.ID, Kind: "link", Status: "ok"}
+		link.TopologyID = topo.ID
+
+		source, okS := s.lookupNode(ready, topo.ID, link.SourceID)
+		target, okT := s.lookupNode(ready, topo.ID, link.TargetID)
+		if !okS || !okT {
+			res.Status = "skipped"
+			res.Error = "source or target node not available"
+			report.Links = append(report.Links, res)
+			s.publishProgress(topo.ID, res)
+			continue
+		}
+
+		if err := link.Impairment.Validate(); err != nil {
+			res.Status = "error"
+			res.Error = err.Error()
+			report.Links = append(report.Links, res)
+			s.publishProgress(topo.ID, res)
+			continue
+		}
+
+		if s.linkExists(link) {
+			res.Status = "skipped"
+			res.Error = "link already exists between these nodes"
+			report.Links = append(report.Links, res)
+			s.publishProgress(topo.ID, res)
+			continue
+		}
+
+		if err := s.provisionLink(link, source, target); err != nil {
+			res.Status = "error"
+			res.Error = err.Error()
+		}
+		report.Links = append(report.Links, res)
+		s.publishProgress(topo.ID, res)
+	}
+
+	return report
+}
+
+// publishProgress reports a finished element of a deploy or apply to event subscribers
+func (s *Server) publishProgress(topologyID string, res DeployResult) {
+	ev := events.Event{Type: events.DeployProgress, TopologyID: topologyID, Data: res}
+	if res.Kind == "node" {
+		ev.NodeID = res.ID
+	} else {
+		ev.LinkID = res.ID
+	}
+	s.publish(ev)
+}
+
+// saveTopologyMeta creates or renames the stored lab a batch targets
+func (s *Server) saveTopologyMeta(topo models.Topology) error {
+	stored, err := s.mergeTopologyMeta(topo)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SaveTopology(stored); err != nil {
+		return fmt.Errorf("error saving topology %s: %v", topo.ID, err)
+	}
+	return nil
+}
+
+// mergeTopologyMeta returns the stored lab updated with the metadata of a batch,
+// without saving it
+func (s *Server) mergeTopologyMeta(topo models.Topology) (models.Topology, error) {
+	stored, found := s.repo.GetTopology(topo.ID)
+	if !found {
+		stored = models.Topology{ID: topo.ID, CreatedAt: time.Now()}
+	}
+	if topo.Name != "" {
+		stored.Name = topo.Name
+	}
+	if topo.CPUBudget != "" || topo.RAMBudget != "" {
+		if err := validateBudget(topo); err != nil {
+			return stored, err
+		}
+		stored.CPUBudget, stored.RAMBudget = topo.CPUBudget, topo.RAMBudget
+	}
+	if topo.P2PPoolV4 != "" || topo.P2PPoolV6 != "" || topo.LoopbackPool != "" {
+		if err := validatePools(topo); err != nil {
+			return stored, err
+		}
+		stored.P2PPoolV4, stored.P2PPoolV6, stored.LoopbackPool = topo.P2PPoolV4, topo.P2PPoolV6, topo.LoopbackPool
+	}
+	return stored, nil
+}
+
+// lookupNode resolves a node from the current batch, falling back to the nodes
+// already stored in the same lab
+func (s *Server) lookupNode(batch map[string]models.Node, topologyID, id string) (models.Node, bool) {
+	if n, ok := batch[id]; ok {
+		return n, true
+	}
+	n, found := s.repo.GetNode(id)
+	if !found || n.TopologyID != topologyID {
+		return models.Node{}, false
+	}
+	return n, true
+}
+
+// provisionNode starts the container of a node and persists its runtime state.
+// Switches have no container: they are a Linux bridge on the host.
+func (s *Server) provisionNode(ctx context.Context, node models.Node) (models.Node, error) {
+	if err := checkNodeName(node); err != nil {
+		return node, err
+	}
+	if err := node.Startup.Validate(node.Type); err != nil {
+		return node, err
+	}
+	if err := s.checkRuntime(node); err != nil {
+		return node, err
+	}
+	if err := s.checkNodeID(node); err != nil {
+		return node, err
+	}
+
+	if node.Type == models.SWITCH {
+		if err := s.network.CreateBridge(orchestrator.BridgeName(node)); err != nil {
+			return node, err
+		}
+
+		node.ContainerID = ""
+		node.PID = 0
+		node.Status = models.StatusRunning
+		if err := s.repo.SaveNode(node); err != nil {
+			return node, fmt.Errorf("error saving node %s: %v", node.ID, err)
+		}
+		s.publish(events.Event{Type: events.NodeCreated, TopologyID: node.TopologyID, NodeID: node.ID, Data: node})
+		return node, nil
+	}
+
+	containerID, err := s.runtime.CreateNode(ctx, node)
+	if err != nil {
+		return node, err
+	}
+
+	// Until the node is saved nothing else knows the container: remove it on failure
+	pid, err := s.runtime.GetNodePID(ctx, containerID)
+	if err != nil {
+		_ = s.runtime.DeleteNode(ctx, containerID)
+		return node, err
+	}
+
+	node.ContainerID = containerID
+	node.PID = pid
+	node.Status = models.StatusRunning
+	if err := s.repo.SaveNode(node); err != nil {
+		_ = s.runtime.DeleteNode(ctx, containerID)
+		return node, fmt.Errorf("error saving node %s: %v", node.ID, err)
+	}
+
+	s.watchNodeKernel(node)
+	s.publish(events.Event{Type: events.NodeCreated, TopologyID: node.TopologyID, NodeID: node.ID, Data: node})
+
+	// Loopback exists from the start; link interfaces are restored by provisionLink
+	if err := s.restoreAddresses(node, "lo"); err != nil {
+		return node, err
+	}
+	return node, nil
+}
+
+// destroyNode removes the runtime resources of a node (container or bridge)
+func (s *Server) destroyNode(ctx context.Context, node models.Node) error {
+	s.unwatchNodeKernel(node.ID)
+
+	if node.Type == models.SWITCH {
+		return s.network.DeleteBridge(orchestrator.BridgeName(node))
+	}
+	// The container ID is authoritative; the name only covers rows saved before it was known
+	ref := node.ContainerID
+	if ref == "" {
+		ref = orchestrator.ContainerName(node)
+	}
+	return s.runtime.DeleteNode(ctx, ref)
+}
+
+// nodeAlive reports whether the runtime resources of a stored node still exist
+func (s *Server) nodeAlive(ctx context.Context, node models.Node) bool {
+	if node.Type == models.SWITCH {
+		return s.network.BridgeExists(orchestrator.BridgeName(node))
+	}
+	_, ok := s.nodePID(ctx, node)
+	return ok
+}
+
+// nodePID asks the runtime for the current PID of a container node.
+// The stored PID goes stale when the container restarts behind our back.
+func (s *Server) nodePID(ctx context.Context, node models.Node) (int, bool) {
+	if node.ContainerID == "" {
+		return 0, false
+	}
+	pid, err := s.runtime.GetNodePID(ctx, node.ContainerID)
+	return pid, err == nil
+}
+
+// provisionLink wires two running nodes and persists the link.
+// Links touching a switch become a bridge port instead of a veth between namespaces.
+func (s *Server) provisionLink(link models.Link, source, target models.Node) error {
+	s.plumbMu.Lock()
+	defer s.plumbMu.Unlock()
+	return s.plumbLink(link, source, target)
+}
+
+// plumbLink is provisionLink for callers that already hold s.plumbMu
+func (s *Server) plumbLink(link models.Link, source, target models.Node) error {
+	if err := s.checkLinkID(link); err != nil {
+		return err
+	}
+	if _, ok := link.IPAM.PrefixLen(); !ok && link.IPAM != models.IPAMNone {
+		return fmt.Errorf("invalid ipam mode %q", link.IPAM)
+	}
+	if link.IPAM != models.IPAMNone && (source.Type == models.SWITCH || target.Type == models.SWITCH) {
+		return fmt.Errorf("ipam requires a point-to-point link between two nodes")
+	}
+
+	// Point-to-point addressing from the lab pool (kept across recreations).
+	// Allocated before wiring: an exhausted pool must not leave a veth behind.
+	_, stored := s.repo.GetLink(link.ID)
+	rollback := func() {
+		if !stored {
+			_ = s.repo.DeleteLinkAddresses(link.ID)
+		}
+	}
+	if err := s.allocateLinkAddresses(link, source, target); err != nil {
+		rollback()
+		return err
+	}
+
+	var err error
+	switch {
+	case source.Type == models.SWITCH && target.Type == models.SWITCH:
+		err = fmt.Errorf("links between two switches are not supported")
+	case source.Type == models.SWITCH:
+		err = s.connectToSwitch(source, target.PID, link.TargetInt, link.Impairment)
+	case target.Type == models.SWITCH:
+		err = s.connectToSwitch(target, source.PID, link.SourceInt, link.Impairment)
+	default:
+		err = s.network.CreateLink(link, source.PID, target.PID)
+	}
+	if err != nil {
+		rollback()
+		return err
+	}
+
+	// Unsaved links are invisible to teardown: on failure the veth goes away now
+	unwire := func() {
+		node, iface := source, link.SourceInt
+		if source.Type == models.SWITCH {
+			node, iface = target, link.TargetInt
+		}
+		_ = s.network.DeleteLink(node.PID, iface)
+		rollback()
+	}
+
+	// Re-apply persisted addressing on both ends before the link is stored
+	err = s.restoreAddresses(source, link.SourceInt)
+	if err == nil {
+		err = s.restoreAddresses(target, link.TargetInt)
+	}
+	if err != nil {
+		unwire()
+		return err
+	}
+
+	if err := s.repo.SaveLink(link); err != nil {
+		unwire()
+		return fmt.Errorf("error saving link %s: %v", link.ID, err)
+	}
+	s.publish(events.Event{Type: events.LinkCreated, TopologyID: link.TopologyID, LinkID: link.ID, Data: link})
+	return nil
+}
+
+// teardownLink removes a link from the kernel by deleting one veth end inside its node.
+// Switch ends live on the host, so the node end is used for bridge ports.
+// When the node is already gone its namespace took the veth with it.
+func (s *Server) teardownLink(ctx context.Context, link models.Link) error {
+	s.plumbMu.Lock()
+	defer s.plumbMu.Unlock()
+
+	source, okS := s.repo.GetNode(link.SourceID)
+	target, okT := s.repo.GetNode(link.TargetID)
+
+	node, iface := source, link.SourceInt
+	if !okS || source.Type == models.SWITCH {
+		node, iface = target, link.TargetInt
+		if !okT || target.Type == models.SWITCH {
+			return fmt.Errorf("link %s has no container end to tear down", link.ID)
+		}
+	}
+
+	pid, ok := s.nodePID(ctx, node)
+	if !ok {
+		return nil
+	}
+
+	if err := s.network.DeleteLink(pid, iface); err != nil {
+		return fmt.Errorf("cannot tear down link %s on %s/%s: %v", link.ID, node.Name, iface, err)
+	}
+	s.publish(events.Event{Type: events.LinkDeleted, TopologyID: link.TopologyID, LinkID: link.ID})
+	return nil
+}
+
+// connectToSwitch plugs a node interface into the bridge of a switch node
+func (s *Server) connectToSwitch(sw models.Node, pid int, iface string, imp models.Impairment) error {
+	if err := s.network.ConnectNodeToBridge(pid, iface, orchestrator.BridgeName(sw)); err != nil {
+		return err
+	}
+	if imp.IsZero() {
+		return nil
+	}
+	// The bridge side lives on the host, so only the node end is shaped.
+	// On failure the port goes away with the node end: the link is not stored.
+	if err := s.network.ApplyImpairment(pid, iface, imp); err != nil {
+		_ = s.network.DeleteLink(pid, iface)
+		return err
+	}
+	return nil
+}
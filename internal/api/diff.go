@@ -0,0 +1,124 @@
+package api
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines kept around each change
+const diffContext = 3
+
+// diffOp is one line of a line-based diff: ' ' kept, '-' removed, '+' added
+type diffOp struct {
+	kind byte
+	text string
+	a, b int // Line index in each side (valid for the sides that contain the line)
+}
+
+// splitLines splits a config into lines, ignoring the trailing newline
+func splitLines(s string) []string {
+	s = strings.TrimSuffix(s, "\n")
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// diffLines computes a minimal line diff of a and b (LCS; configs are small)
+func diffLines(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{kind: ' ', text: a[i], a: i, b: j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			// Removals go first, as in diff -u
+			ops = append(ops, diffOp{kind: '-', text: a[i], a: i, b: j})
+			i++
+		default:
+			ops = append(ops, diffOp{kind: '+', text: b[j], a: i, b: j})
+			j++
+		}
+	}
+	return ops
+}
+
+// unifiedDiff renders the differences between two configs in unified format.
+// It returns an empty string when both are equal.
+func unifiedDiff(from, to, fromName, toName string) string {
+	ops := diffLines(splitLines(from), splitLines(to))
+
+	var out strings.Builder
+	for start := 0; start < len(ops); {
+		// Find the next change
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+
+		// Extend the hunk while changes are close enough to share context
+		end := start
+		for k := start; k < len(ops); k++ {
+			if ops[k].kind != ' ' {
+				end = k
+			} else if k-end > 2*diffContext {
+				break
+			}
+		}
+		lo := max(start-diffContext, 0)
+		hi := min(end+diffContext+1, len(ops))
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromName, toName)
+		}
+
+		var aLen, bLen int
+		for _, op := range ops[lo:hi] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(ops[lo].a, aLen), hunkRange(ops[lo].b, bLen))
+		for _, op := range ops[lo:hi] {
+			out.WriteByte(op.kind)
+			out.WriteString(op.text)
+			out.WriteByte('\n')
+		}
+
+		start = hi
+	}
+	return out.String()
+}
+
+// hunkRange formats the 1-based line range of a hunk side
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
@@ -0,0 +1,31 @@
+package api
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	from := "frr version 9.1\nhostname r1\n!\nrouter ospf\n network 10.0.0.0/30 area 0\n!\n"
+	to := "frr version 9.1\nhostname r1\n!\nrouter ospf\n network 10.0.0.0/30 area 0\n network 10.0.0.4/30 area 0\n!\n"
+
+	want := "--- v1\n+++ v2\n" +
+		"@@ -3,4 +3,5 @@\n" +
+		" !\n router ospf\n  network 10.0.0.0/30 area 0\n+ network 10.0.0.4/30 area 0\n !\n"
+	if got := unifiedDiff(from, to, "v1", "v2"); got != want {
+		t.Errorf("diff inesperado:\n%s\nse esperaba:\n%s", got, want)
+	}
+
+	if got := unifiedDiff(from, from, "v1", "v1"); got != "" {
+		t.Errorf("configs iguales no deberían generar diff, se obtuvo:\n%s", got)
+	}
+}
+
+func TestUnifiedDiffSeparateHunks(t *testing.T) {
+	from := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\n"
+	to := "A\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\n"
+
+	want := "--- old\n+++ new\n" +
+		"@@ -1,4 +1,4 @@\n-a\n+A\n b\n c\n d\n" +
+		"@@ -9,4 +9,4 @@\n i\n j\n k\n-l\n+L\n"
+	if got := unifiedDiff(from, to, "old", "new"); got != want {
+		t.Errorf("diff inesperado:\n%s\nse esperaba:\n%s", got, want)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"io"
+	"open-veth/internal/events"
+	"open-veth/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleEvents streams topology and runtime events as Server-Sent Events.
+// ?topology=<id> restricts the stream to a single lab.
+func (s *Server) handleEvents(c *gin.Context) {
+	topologyID := c.Query("topology")
+
+	ch, unsubscribe := s.events.Subscribe(64)
+	defer unsubscribe()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case ev, ok := <-ch:
+			if !ok {
+				return false
+			}
+			if topologyID != "" && ev.TopologyID != topologyID {
+				return true
+			}
+			c.SSEvent(string(ev.Type), ev)
+			return true
+		}
+	})
+}
+
+// publish stamps an API event with its origin and sends it to subscribers
+func (s *Server) publish(ev events.Event) {
+	if ev.Source == "" {
+		ev.Source = events.SourceAPI
+	}
+	s.events.Publish(ev)
+}
+
+// kernelWatch is the netlink watcher of one node namespace
+type kernelWatch struct {
+	pid    int
+	cancel context.CancelFunc
+}
+
+// watchNodeKernel follows link and address changes inside the namespace of a running node.
+// Any previous watcher of the node is replaced (its PID may have changed).
+func (s *Server) watchNodeKernel(node models.Node) {
+	s.unwatchNodeKernel(node.ID)
+	if node.Type == models.SWITCH || node.PID <= 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	kernelEvents, err := s.network.WatchNamespace(ctx, node.PID)
+	if err != nil {
+		cancel()
+		return
+	}
+
+	w := &kernelWatch{pid: node.PID, cancel: cancel}
+	s.watchMu.Lock()
+	s.nsWatchers[node.ID] = w
+	s.watchMu.Unlock()
+
+	go func() {
+		// The channel also closes when the namespace goes away: forget the watcher
+		defer func() {
+			s.watchMu.Lock()
+			if s.nsWatchers[node.ID] == w {
+				delete(s.nsWatchers, node.ID)
+			}
+			s.watchMu.Unlock()
+			cancel()
+		}()
+
+		for kev := range kernelEvents {
+			ev := events.Event{
+				Source:     events.SourceKernel,
+				TopologyID: node.TopologyID,
+				NodeID:     node.ID,
+				Data:       kev,
+			}
+			switch {
+			case kev.Kind == "addr" && kev.Deleted:
+				ev.Type = events.AddressRemoved
+			case kev.Kind == "addr":
+				ev.Type = events.AddressAdded
+			case kev.Up && !kev.Deleted:
+				ev.Type = events.LinkUp
+			default:
+				ev.Type = events.LinkDown
+			}
+			s.events.Publish(ev)
+		}
+	}()
+}
+
+// watchingKernel reports whether a watcher follows the namespace of a node at this PID
+func (s *Server) watchingKernel(nodeID string, pid int) bool {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	w, ok := s.nsWatchers[nodeID]
+	return ok && w.pid == pid
+}
+
+// unwatchNodeKernel stops the namespace watcher of a node, if any
+func (s *Server) unwatchNodeKernel(nodeID string) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if w, ok := s.nsWatchers[nodeID]; ok {
+		w.cancel()
+		delete(s.nsWatchers, nodeID)
+	}
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+	"open-veth/internal/recording"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultExecTimeout = 30 * time.Second
+	maxExecTimeout     = 10 * time.Minute
+)
+
+// execRequest is the body of POST /nodes/:id/exec. Exactly one of Cmd or Shell is set.
+type execRequest struct {
+	Cmd        []string `json:"cmd"`         // argv, run as is
+	Shell      string   `json:"shell"`       // Run with sh -c (pipes, redirections)
+	TimeoutSec int      `json:"timeout_sec"` // 0 = default (30s)
+}
+
+// command returns the argv and the timeout of the request
+func (r execRequest) command() ([]string, time.Duration, error) {
+	var cmd []string
+	switch {
+	case len(r.Cmd) > 0 && r.Shell != "":
+		return nil, 0, fmt.Errorf("cmd and shell are mutually exclusive")
+	case len(r.Cmd) > 0:
+		cmd = r.Cmd
+	case r.Shell != "":
+		cmd = []string{"sh", "-c", r.Shell}
+	default:
+		return nil, 0, fmt.Errorf("cmd or shell is required")
+	}
+
+	// Range check before converting: a huge value would overflow time.Duration
+	if r.TimeoutSec < 0 || r.TimeoutSec > int(maxExecTimeout/time.Second) {
+		return nil, 0, fmt.Errorf("timeout_sec must be between 0 and %d", int(maxExecTimeout.Seconds()))
+	}
+	timeout := time.Duration(r.TimeoutSec) * time.Second
+	if timeout == 0 {
+		timeout = defaultExecTimeout
+	}
+	return cmd, timeout, nil
+}
+
+// --- Exec Handlers ---
+
+// execNode runs a command in a node and returns its output once it finishes.
+// A timeout is reported with timed_out=true and the output gathered so far.
+func (s *Server) execNode(c *gin.Context) {
+	node, cmd, timeout, ok := s.bindExec(c)
+	if !ok {
+		return
+	}
+
+	res, err := s.runtime.Exec(c.Request.Context(), node.ContainerID, cmd, timeout)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, res)
+}
+
+// execNodeStream runs a command in a node and streams its output as NDJSON over a
+// chunked response: one {"stream","data"} line per chunk and a final result line.
+// Closing the connection kills the command.
+func (s *Server) execNodeStream(c *gin.Context) {
+	node, cmd, timeout, ok := s.bindExec(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	enc := json.NewEncoder(c.Writer)
+	start := time.Now()
+	stdout := &execChunkWriter{c: c, enc: enc, stream: "stdout"}
+	stderr := &execChunkWriter{c: c, enc: enc, stream: "stderr"}
+	code, err := s.runtime.ExecStream(ctx, node.ContainerID, cmd, stdout, stderr)
+	_ = stdout.flush()
+	_ = stderr.flush()
+
+	final := gin.H{"exit_code": code, "duration_ms": time.Since(start).Milliseconds(), "timed_out": false}
+	switch {
+	case errors.Is(err, orchestrator.ErrExecTimeout) && ctx.Err() == context.DeadlineExceeded:
+		final["timed_out"] = true
+	case err != nil:
+		final["error"] = err.Error()
+	}
+	_ = enc.Encode(final)
+	c.Writer.Flush()
+}
+
+// execChunkWriter turns each chunk of command output into an NDJSON line.
+// A UTF-8 rune split across two reads is held back until it is complete.
+type execChunkWriter struct {
+	c       *gin.Context
+	enc     *json.Encoder
+	stream  string
+	pending []byte
+}
+
+func (w *execChunkWriter) Write(p []byte) (int, error) {
+	buf := append(w.pending, p...)
+	cut := recording.IncompleteSuffix(buf)
+	w.pending = append([]byte(nil), buf[len(buf)-cut:]...)
+	if err := w.emit(buf[:len(buf)-cut]); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flush writes whatever is still held back once the command has finished
+func (w *execChunkWriter) flush() error {
+	buf := w.pending
+	w.pending = nil
+	return w.emit(buf)
+}
+
+func (w *execChunkWriter) emit(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := w.enc.Encode(gin.H{"stream": w.stream, "data": string(data)}); err != nil {
+		return err
+	}
+	w.c.Writer.Flush()
+	return nil
+}
+
+// bindExec loads the node of the URL and decodes the exec request.
+// It writes the error response itself.
+func (s *Server) bindExec(c *gin.Context) (models.Node, []string, time.Duration, bool) {
+	node, ok := s.runningContainer(c)
+	if !ok {
+		return node, nil, 0, false
+	}
+
+	var req execRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return node, nil, 0, false
+	}
+	cmd, timeout, err := req.command()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return node, nil, 0, false
+	}
+	return node, cmd, timeout, true
+}
+
+// runningContainer loads the node of the URL and checks its container is running.
+// It writes the error response itself.
+func (s *Server) runningContainer(c *gin.Context) (models.Node, bool) {
+	node, found := s.repo.GetNode(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return node, false
+	}
+	if node.Type == models.SWITCH {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "switch nodes have no container"})
+		return node, false
+	}
+	if node.ContainerID == "" || node.Status != models.StatusRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("node is %s", node.Status)})
+		return node, false
+	}
+	return node, true
+}
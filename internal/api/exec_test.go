@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestExecRequestCommand(t *testing.T) {
+	cmd, timeout, err := execRequest{Shell: "ip -j addr | head"}.command()
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if !slices.Equal(cmd, []string{"sh", "-c", "ip -j addr | head"}) || timeout != defaultExecTimeout {
+		t.Errorf("se obtuvo %v (timeout %s)", cmd, timeout)
+	}
+
+	cmd, timeout, err = execRequest{Cmd: []string{"ping", "-c1", "10.0.0.2"}, TimeoutSec: 5}.command()
+	if err != nil || len(cmd) != 3 || timeout != 5*time.Second {
+		t.Errorf("se obtuvo %v (timeout %s, err %v)", cmd, timeout, err)
+	}
+
+	invalid := []execRequest{
+		{},
+		{Cmd: []string{"true"}, Shell: "true"},
+		{Shell: "true", TimeoutSec: -1},
+		{Shell: "true", TimeoutSec: 3600},
+		{Shell: "true", TimeoutSec: 9223372037},  // Desborda a una duración negativa
+		{Shell: "true", TimeoutSec: 18446744074}, // Desborda a 290ms
+	}
+	for _, req := range invalid {
+		if _, _, err := req.command(); err == nil {
+			t.Errorf("se esperaba error para %+v", req)
+		}
+	}
+}
+
+func TestExecChunkWriterSplitRune(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+	w := &execChunkWriter{c: c, enc: json.NewEncoder(c.Writer), stream: "stdout"}
+
+	// "ñ" son dos bytes y llegan en dos lecturas distintas
+	text := []byte("año\n")
+	for _, chunk := range [][]byte{text[:2], text[2:3], text[3:]} {
+		if n, err := w.Write(chunk); err != nil || n != len(chunk) {
+			t.Fatalf("Write devolvió %d, %v", n, err)
+		}
+	}
+	// Un carácter que nunca se completa sale igual al terminar
+	if _, err := w.Write([]byte{0xe2, 0x82}); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	sc := bufio.NewScanner(rec.Body)
+	for sc.Scan() {
+		var line struct{ Data string }
+		if err := json.Unmarshal(sc.Bytes(), &line); err != nil {
+			t.Fatalf("línea inválida %q: %v", sc.Text(), err)
+		}
+		got += line.Data
+	}
+	if got != "año\n\ufffd\ufffd" {
+		t.Errorf("se obtuvo %q", got)
+	}
+}
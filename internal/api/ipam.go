@@ -0,0 +1,230 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"open-veth/internal/events"
+	"open-veth/internal/ipam"
+	"open-veth/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// allocateLinkAddresses gives both ends of a point-to-point link an address from the lab pool.
+// Existing allocations are kept when they still match the link, so recreated links get the same IPs.
+func (s *Server) allocateLinkAddresses(link models.Link, source, target models.Node) error {
+	s.ipamMu.Lock()
+	defer s.ipamMu.Unlock()
+
+	bits, _ := link.IPAM.PrefixLen()
+	if s.linkAllocationValid(link, source, target, bits) {
+		return nil
+	}
+
+	// Stale or partial allocation (mode or endpoints changed): start over
+	if err := s.repo.DeleteLinkAddresses(link.ID); err != nil {
+		return err
+	}
+	if link.IPAM == models.IPAMNone {
+		return nil
+	}
+
+	topo, _ := s.repo.GetTopology(link.TopologyID)
+	pool := topo.P2PPoolV4
+	if pool == "" {
+		pool = ipam.DefaultP2PPoolV4
+	}
+	if link.IPAM == models.IPAMv6Net127 {
+		pool = topo.P2PPoolV6
+		if pool == "" {
+			pool = ipam.DefaultP2PPoolV6
+		}
+	}
+
+	alloc, err := s.labAllocator(link.TopologyID, pool)
+	if err != nil {
+		return err
+	}
+
+	subnet, err := alloc.Next(bits)
+	if err != nil {
+		return err
+	}
+	sourceAddr, targetAddr, err := ipam.PointToPoint(subnet)
+	if err != nil {
+		return err
+	}
+
+	ends := []models.InterfaceAddress{
+		{NodeID: source.ID, Interface: link.SourceInt, Address: sourceAddr, Source: models.AddressIPAM, LinkID: link.ID},
+		{NodeID: target.ID, Interface: link.TargetInt, Address: targetAddr, Source: models.AddressIPAM, LinkID: link.ID},
+	}
+	for _, addr := range ends {
+		if _, err := s.repo.AddAddress(addr); err != nil {
+			return fmt.Errorf("error saving allocation %s: %v", addr.Address, err)
+		}
+	}
+
+	fmt.Printf("IPAM: link %s -> %s (%s), %s (%s)\n", link.ID, sourceAddr, source.Name, targetAddr, target.Name)
+	return nil
+}
+
+// linkAllocationValid checks that a link owns exactly one address per end of the expected size
+func (s *Server) linkAllocationValid(link models.Link, source, target models.Node, bits int) bool {
+	var owned []models.InterfaceAddress
+	for _, nodeID := range []string{source.ID, target.ID} {
+		addrs, _ := s.repo.ListAddresses(nodeID)
+		for _, a := range addrs {
+			if a.LinkID == link.ID {
+				owned = append(owned, a)
+			}
+		}
+	}
+
+	if link.IPAM == models.IPAMNone {
+		return len(owned) == 0
+	}
+	if len(owned) != 2 {
+		return false
+	}
+
+	want := map[string]bool{
+		source.ID + "/" + link.SourceInt: true,
+		target.ID + "/" + link.TargetInt: true,
+	}
+	for _, a := range owned {
+		p, err := netip.ParsePrefix(a.Address)
+		if err != nil || p.Bits() != bits || !want[a.NodeID+"/"+a.Interface] {
+			return false
+		}
+		delete(want, a.NodeID+"/"+a.Interface)
+	}
+	return true
+}
+
+// labAllocator builds an allocator over pool that avoids every address already used in the lab
+func (s *Server) labAllocator(topologyID, pool string) (*ipam.Allocator, error) {
+	alloc, err := ipam.NewAllocator(pool, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, _ := s.repo.ListNodesByTopology(topologyID)
+	for _, n := range nodes {
+		addrs, _ := s.repo.ListAddresses(n.ID)
+		for _, a := range addrs {
+			if err := alloc.Reserve(a.Address); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return alloc, nil
+}
+
+// ipamConflict returns the pool allocation of a lab that overlaps cidr, if any. Requires ipamMu.
+func (s *Server) ipamConflict(topologyID, cidr string) (models.InterfaceAddress, bool) {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return models.InterfaceAddress{}, false
+	}
+
+	nodes, _ := s.repo.ListNodesByTopology(topologyID)
+	for _, n := range nodes {
+		addrs, _ := s.repo.ListAddresses(n.ID)
+		for _, a := range addrs {
+			if a.Source != models.AddressIPAM {
+				continue
+			}
+			if q, err := netip.ParsePrefix(a.Address); err == nil && p.Overlaps(q) {
+				return a, true
+			}
+		}
+	}
+	return models.InterfaceAddress{}, false
+}
+
+// allocateLoopback assigns the router a /32 loopback from the lab pool (idempotent)
+func (s *Server) allocateLoopback(c *gin.Context) {
+	node, found := s.repo.GetNode(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+	if node.Type != models.ROUTER {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "loopback allocation is only available for routers"})
+		return
+	}
+	if err := requireNamespace(node); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	s.ipamMu.Lock()
+	defer s.ipamMu.Unlock()
+
+	addrs, _ := s.repo.ListAddresses(node.ID)
+	for _, a := range addrs {
+		if a.Interface == "lo" && a.Source == models.AddressIPAM {
+			c.JSON(http.StatusOK, a)
+			return
+		}
+	}
+
+	topo, _ := s.repo.GetTopology(node.TopologyID)
+	pool := topo.LoopbackPool
+	if pool == "" {
+		pool = ipam.DefaultLoopbackPool
+	}
+
+	alloc, err := s.labAllocator(node.TopologyID, pool)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	prefix, err := alloc.Next(32)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	addr := models.InterfaceAddress{NodeID: node.ID, Interface: "lo", Address: prefix.String(), Source: models.AddressIPAM}
+	if err := s.network.SetInterfaceIP(node.PID, addr.Interface, addr.Address); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	addr, err = s.repo.AddAddress(addr)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	addr.Applied = true
+	s.publish(events.Event{Type: events.AddressAdded, TopologyID: node.TopologyID, NodeID: node.ID, Data: addr})
+	c.JSON(http.StatusCreated, addr)
+}
+
+// validatePools checks the IPAM pools of a topology
+func validatePools(topo models.Topology) error {
+	// Fixed order, so the same body always reports the same error
+	pools := []struct{ name, pool string }{
+		{"p2p_pool_v4", topo.P2PPoolV4},
+		{"p2p_pool_v6", topo.P2PPoolV6},
+		{"loopback_pool", topo.LoopbackPool},
+	}
+	for _, entry := range pools {
+		name, pool := entry.name, entry.pool
+		if pool == "" {
+			continue
+		}
+		p, err := netip.ParsePrefix(pool)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+		if (name == "p2p_pool_v6") == p.Addr().Is4() {
+			return fmt.Errorf("invalid %s: wrong address family", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+
+	"github.com/gin-gonic/gin"
+)
+
+// nodeLifecycle builds the handler of POST /nodes/:id/<action>
+func (s *Server) nodeLifecycle(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+
+		node, found := s.repo.GetNode(c.Param("id"))
+		if !found {
+			c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+			return
+		}
+		if node.Type == models.SWITCH {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "switch nodes have no container lifecycle"})
+			return
+		}
+		if node.ContainerID == "" {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("node is %s, redeploy it first", node.Status)})
+			return
+		}
+
+		var err error
+		switch action {
+		case "start":
+			err = s.runtime.StartNode(ctx, node.ContainerID)
+		case "stop":
+			err = s.runtime.StopNode(ctx, node.ContainerID)
+		case "restart":
+			err = s.runtime.RestartNode(ctx, node.ContainerID)
+		case "pause":
+			err = s.runtime.PauseNode(ctx, node.ContainerID)
+		case "unpause":
+			err = s.runtime.UnpauseNode(ctx, node.ContainerID)
+		}
+		if err != nil {
+			// The stored status stays: a refused action leaves the node as it was
+			code := http.StatusInternalServerError
+			if errors.Is(err, orchestrator.ErrNodeState) {
+				code = http.StatusConflict
+			}
+			c.JSON(code, gin.H{"error": err.Error()})
+			return
+		}
+
+		var update func(*models.Node)
+		switch action {
+		case "start", "restart":
+			// Fresh namespace: refresh the PID and re-plumb every link
+			report := s.restoreNode(ctx, node)
+			node, _ = s.repo.GetNode(node.ID)
+			if report.Failed() {
+				c.JSON(http.StatusMultiStatus, gin.H{"node": node, "report": report})
+				return
+			}
+			c.JSON(http.StatusOK, node)
+			return
+		case "stop":
+			s.unwatchNodeKernel(node.ID)
+			update = func(n *models.Node) { n.PID, n.Status = 0, models.StatusStopped }
+		case "pause":
+			update = func(n *models.Node) { n.Status = models.StatusPaused }
+		case "unpause":
+			update = func(n *models.Node) { n.Status = models.StatusRunning }
+		}
+
+		// Saved like a container event: the runtime call may have raced with one
+		updated, found, err := s.updateStoredNode(node.ID, node.ContainerID, update)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !found {
+			c.JSON(http.StatusConflict, gin.H{"error": "node was removed or recreated meanwhile"})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+	}
+}
@@ -0,0 +1,402 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// PlanAction is the operation needed to converge an element to the lab file
+type PlanAction string
+
+const (
+	ActionAdd      PlanAction = "add"
+	ActionRemove   PlanAction = "remove"
+	ActionRecreate PlanAction = "recreate" // Container or veth must be rebuilt
+	ActionUpdate   PlanAction = "update"   // Metadata only (e.g. canvas position)
+)
+
+// NodeChange is a planned operation on a node
+type NodeChange struct {
+	Action  PlanAction   `json:"action"`
+	Node    models.Node  `json:"node"`
+	Current *models.Node `json:"current,omitempty"` // Stored state being replaced
+	Reason  string       `json:"reason,omitempty"`
+}
+
+// LinkChange is a planned operation on a link
+type LinkChange struct {
+	Action PlanAction  `json:"action"`
+	Link   models.Link `json:"link"`
+	Reason string      `json:"reason,omitempty"`
+}
+
+// Plan is the diff between a desired topology and the current state
+type Plan struct {
+	TopologyID string       `json:"topology_id"`
+	Nodes      []NodeChange `json:"nodes"`
+	Links      []LinkChange `json:"links"`
+}
+
+// Empty reports whether the lab already matches the desired topology
+func (p Plan) Empty() bool {
+	return len(p.Nodes) == 0 && len(p.Links) == 0
+}
+
+// computePlan diffs the desired topology against the stored nodes and links.
+// running tells which stored nodes Docker reports as alive (by node ID).
+func computePlan(desired models.Topology, nodes []models.Node, links []models.Link, running map[string]bool) Plan {
+	plan := Plan{
+		TopologyID: desired.ID,
+		Nodes:      []NodeChange{},
+		Links:      []LinkChange{},
+	}
+
+	current := make(map[string]models.Node, len(nodes))
+	for _, n := range nodes {
+		current[n.ID] = n
+	}
+
+	// 1. Nodes
+	rebuilt := make(map[string]bool) // Nodes that get a new network namespace
+	wanted := make(map[string]bool, len(desired.Nodes))
+	for _, node := range desired.Nodes {
+		node.TopologyID = desired.ID
+		wanted[node.ID] = true
+
+		cur, exists := current[node.ID]
+		switch {
+		case !exists:
+			plan.Nodes = append(plan.Nodes, NodeChange{Action: ActionAdd, Node: node})
+			rebuilt[node.ID] = true
+		case !running[node.ID]:
+			plan.Nodes = append(plan.Nodes, NodeChange{Action: ActionRecreate, Node: node, Current: &cur, Reason: "container is not running"})
+			rebuilt[node.ID] = true
+		default:
+			if reason := nodeDrift(cur, node); reason != "" {
+				plan.Nodes = append(plan.Nodes, NodeChange{Action: ActionRecreate, Node: node, Current: &cur, Reason: reason})
+				rebuilt[node.ID] = true
+			} else if cur.X != node.X || cur.Y != node.Y {
+				plan.Nodes = append(plan.Nodes, NodeChange{Action: ActionUpdate, Node: node, Current: &cur, Reason: "position changed"})
+			}
+		}
+	}
+	for _, cur := range nodes {
+		if !wanted[cur.ID] {
+			plan.Nodes = append(plan.Nodes, NodeChange{Action: ActionRemove, Node: cur, Reason: "not in lab file"})
+		}
+	}
+
+	// 2. Links
+	stored := make(map[string]models.Link, len(links))
+	for _, l := range links {
+		stored[l.ID] = l
+	}
+
+	wantedLinks := make(map[string]bool, len(desired.Links))
+	for _, link := range desired.Links {
+		link.TopologyID = desired.ID
+		wantedLinks[link.ID] = true
+
+		cur, exists := stored[link.ID]
+		switch {
+		case !exists:
+			plan.Links = append(plan.Links, LinkChange{Action: ActionAdd, Link: link})
+		case linkDrift(cur, link):
+			plan.Links = append(plan.Links, LinkChange{Action: ActionRecreate, Link: link, Reason: "endpoints or addressing changed"})
+		case rebuilt[link.SourceID] || rebuilt[link.TargetID]:
+			plan.Links = append(plan.Links, LinkChange{Action: ActionRecreate, Link: link, Reason: "endpoint node is recreated"})
+		case cur.Impairment != link.Impairment:
+			plan.Links = append(plan.Links, LinkChange{Action: ActionUpdate, Link: link, Reason: "impairment changed"})
+		}
+	}
+	for _, cur := range links {
+		if !wantedLinks[cur.ID] {
+			plan.Links = append(plan.Links, LinkChange{Action: ActionRemove, Link: cur, Reason: "not in lab file"})
+		}
+	}
+
+	return plan
+}
+
+// nodeDrift returns why a running node no longer matches its desired spec
+func nodeDrift(cur, want models.Node) string {
+	switch {
+	case cur.Name != want.Name:
+		return "name changed"
+	case cur.Type != want.Type:
+		return "type changed"
+	case cur.Image != want.Image:
+		return "image changed"
+	case cur.RuntimeKind() != want.RuntimeKind() || !slices.Equal(cur.Command, want.Command):
+		return "runtime changed"
+	case cur.CPURequest != want.CPURequest || cur.RAMLimit != want.RAMLimit:
+		return "resources changed"
+	case !cur.Startup.Equal(want.Startup):
+		return "startup config changed"
+	}
+	return ""
+}
+
+// linkDrift reports whether a stored link is wired differently than desired
+func linkDrift(cur, want models.Link) bool {
+	return cur.SourceID != want.SourceID || cur.TargetID != want.TargetID ||
+		cur.SourceInt != want.SourceInt || cur.TargetInt != want.TargetInt ||
+		cur.IPAM != want.IPAM
+}
+
+// --- Handlers ---
+
+// planTopology returns the changes needed to converge a lab to the posted lab file
+func (s *Server) planTopology(c *gin.Context) {
+	desired, err := bindLabFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.checkLabIDs(desired); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, s.plan(c.Request.Context(), desired))
+}
+
+// applyTopology computes the plan for a lab file and executes it
+func (s *Server) applyTopology(c *gin.Context) {
+	desired, err := bindLabFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.checkLabIDs(desired); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	// The whole lab file must be valid and fit the budget before anything is touched:
+	// a recreate destroys the old node before provisioning the new one
+	meta, err := s.mergeTopologyMeta(desired)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.validateLabNodes(desired); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := checkBudget(meta, desired.Nodes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.repo.SaveTopology(meta); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("error saving topology %s: %v", meta.ID, err)})
+		return
+	}
+
+	plan := s.plan(c.Request.Context(), desired)
+	report := s.apply(c.Request.Context(), plan)
+
+	status := http.StatusOK
+	if report.Failed() {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, gin.H{"plan": plan, "result": report})
+}
+
+// validateLabNodes runs the checks of provisionNode over every node of a lab file
+func (s *Server) validateLabNodes(topo models.Topology) error {
+	for _, node := range topo.Nodes {
+		node.TopologyID = topo.ID
+		if err := checkNodeName(node); err != nil {
+			return err
+		}
+		if err := node.Startup.Validate(node.Type); err != nil {
+			return fmt.Errorf("node %s: %v", node.Name, err)
+		}
+		if err := s.checkRuntime(node); err != nil {
+			return fmt.Errorf("node %s: %v", node.Name, err)
+		}
+		if node.Type == models.SWITCH || node.RuntimeKind() == models.RuntimeNamespace {
+			continue
+		}
+		if _, _, err := orchestrator.NodeResources(node); err != nil {
+			return fmt.Errorf("node %s: %v", node.Name, err)
+		}
+	}
+	return nil
+}
+
+// bindLabFile decodes a lab file (JSON or YAML) and checks it targets the lab in the URL
+func bindLabFile(c *gin.Context) (models.Topology, error) {
+	var topo models.Topology
+
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return topo, fmt.Errorf("error reading lab file: %v", err)
+	}
+
+	if strings.Contains(c.ContentType(), "yaml") {
+		// YAML is converted to JSON so the models keep a single set of tags
+		var doc interface{}
+		if err := yaml.Unmarshal(body, &doc); err != nil {
+			return topo, fmt.Errorf("invalid yaml lab file: %v", err)
+		}
+		if body, err = json.Marshal(doc); err != nil {
+			return topo, fmt.Errorf("invalid yaml lab file: %v", err)
+		}
+	}
+
+	if err := json.Unmarshal(body, &topo); err != nil {
+		return topo, fmt.Errorf("invalid lab file: %v", err)
+	}
+
+	id := c.Param("id")
+	if topo.ID == "" {
+		topo.ID = id
+	}
+	if topo.ID != id {
+		return topo, fmt.Errorf("lab file targets topology %s, not %s", topo.ID, id)
+	}
+	if !topologyIDPattern.MatchString(topo.ID) {
+		return topo, fmt.Errorf("invalid topology id")
+	}
+	return topo, nil
+}
+
+// plan gathers the current state of a lab from storage and Docker and diffs it
+func (s *Server) plan(ctx context.Context, desired models.Topology) Plan {
+	nodes, _ := s.repo.ListNodesByTopology(desired.ID)
+	links, _ := s.repo.ListLinksByTopology(desired.ID)
+
+	running := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		running[n.ID] = s.nodeAlive(ctx, n)
+	}
+
+	return computePlan(desired, nodes, links, running)
+}
+
+// apply executes a plan: removals first, then node changes, then new links
+func (s *Server) apply(ctx context.Context, plan Plan) DeployReport {
+	report := DeployReport{
+		TopologyID: plan.TopologyID,
+		Nodes:      []DeployResult{},
+		Links:      []DeployResult{},
+	}
+
+	linkResult := func(change LinkChange, err error) {
+		res := DeployResult{ID: change.Link.ID, Kind: "link", Action: string(change.Action), Status: "ok"}
+		if err != nil {
+			res.Status = "error"
+			res.Error = err.Error()
+		}
+		report.Links = append(report.Links, res)
+		s.publishProgress(plan.TopologyID, res)
+	}
+
+	// 1. Drop links that are going away or being rebuilt (kernel first, then storage)
+	stuck := make(map[string]bool) // Old wiring could not be removed
+	for _, change := range plan.Links {
+		switch change.Action {
+		case ActionRemove:
+			if err := s.teardownLink(ctx, change.Link); err != nil {
+				linkResult(change, err)
+				continue
+			}
+			if err := s.repo.DeleteLinkAddresses(change.Link.ID); err != nil {
+				linkResult(change, err)
+				continue
+			}
+			linkResult(change, s.repo.DeleteLink(change.Link.ID))
+		case ActionRecreate:
+			// The stored row still describes the old wiring
+			if old, found := s.repo.GetLink(change.Link.ID); found {
+				if err := s.teardownLink(ctx, old); err != nil {
+					linkResult(change, err)
+					stuck[change.Link.ID] = true
+					continue
+				}
+			}
+			if err := s.repo.DeleteLink(change.Link.ID); err != nil {
+				linkResult(change, err)
+				stuck[change.Link.ID] = true
+				continue
+			}
+		}
+	}
+
+	// 2. Nodes
+	ready := make(map[string]models.Node)
+	for _, change := range plan.Nodes {
+		res := DeployResult{ID: change.Node.ID, Kind: "node", Action: string(change.Action), Status: "ok"}
+
+		var err error
+		switch change.Action {
+		case ActionRemove:
+			err = s.destroyNode(ctx, change.Node)
+			if err == nil {
+				err = s.repo.DeleteNode(change.Node.ID)
+			}
+		case ActionRecreate:
+			err = s.destroyNode(ctx, *change.Current)
+			if err == nil {
+				var node models.Node
+				if node, err = s.provisionNode(ctx, change.Node); err == nil {
+					ready[node.ID] = node
+				}
+			}
+		case ActionAdd:
+			var node models.Node
+			if node, err = s.provisionNode(ctx, change.Node); err == nil {
+				ready[node.ID] = node
+			}
+		case ActionUpdate:
+			// Only the position: runtime state may have changed since the plan was built
+			var found bool
+			_, found, err = s.updateStoredNode(change.Node.ID, change.Current.ContainerID, func(n *models.Node) {
+				n.X, n.Y = change.Node.X, change.Node.Y
+			})
+			if err == nil && !found {
+				err = fmt.Errorf("node was removed or recreated meanwhile")
+			}
+		}
+
+		if err != nil {
+			res.Status = "error"
+			res.Error = err.Error()
+		}
+		report.Nodes = append(report.Nodes, res)
+		s.publishProgress(plan.TopologyID, res)
+	}
+
+	// 3. Wire new and rebuilt links, retune impaired ones
+	for _, change := range plan.Links {
+		if change.Action == ActionUpdate {
+			linkResult(change, s.applyImpairment(ctx, change.Link))
+			continue
+		}
+		if change.Action != ActionAdd && change.Action != ActionRecreate || stuck[change.Link.ID] {
+			continue
+		}
+
+		source, okS := s.lookupNode(ready, plan.TopologyID, change.Link.SourceID)
+		target, okT := s.lookupNode(ready, plan.TopologyID, change.Link.TargetID)
+		if !okS || !okT {
+			linkResult(change, fmt.Errorf("source or target node not available"))
+			continue
+		}
+		linkResult(change, s.provisionLink(change.Link, source, target))
+	}
+
+	return report
+}
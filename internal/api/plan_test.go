@@ -0,0 +1,118 @@
+package api
+
+import (
+	"open-veth/internal/models"
+	"testing"
+)
+
+// TestComputePlan verifica el diff entre un lab file y el estado almacenado
+func TestComputePlan(t *testing.T) {
+	stored := []models.Node{
+		{ID: "r1", TopologyID: "lab", Name: "r1", Image: "openveth/router:latest", ContainerID: "c1"},
+		{ID: "r2", TopologyID: "lab", Name: "r2", Image: "openveth/router:latest", ContainerID: "c2"},
+		{ID: "h1", TopologyID: "lab", Name: "h1", Image: "openveth/host:latest", ContainerID: "c3"},
+	}
+	links := []models.Link{
+		{ID: "l1", TopologyID: "lab", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1"},
+		{ID: "l2", TopologyID: "lab", SourceID: "r2", TargetID: "h1", SourceInt: "eth2", TargetInt: "eth1"},
+	}
+	// r2 está caído en Docker
+	running := map[string]bool{"r1": true, "h1": true}
+
+	desired := models.Topology{
+		ID: "lab",
+		Nodes: []models.Node{
+			{ID: "r1", Name: "r1", Image: "openveth/router:latest", X: 10}, // Solo se movió
+			{ID: "r2", Name: "r2", Image: "openveth/router:latest"},        // Caído -> recreate
+			{ID: "r3", Name: "r3", Image: "openveth/router:latest"},        // Nuevo
+		},
+		Links: []models.Link{
+			{ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1"},
+			{ID: "l3", SourceID: "r2", TargetID: "r3", SourceInt: "eth2", TargetInt: "eth1"},
+		},
+	}
+
+	plan := computePlan(desired, stored, links, running)
+
+	nodeActions := map[string]PlanAction{}
+	for _, ch := range plan.Nodes {
+		nodeActions[ch.Node.ID] = ch.Action
+		if ch.Node.TopologyID != "lab" {
+			t.Errorf("nodo %s sin topology_id asignado", ch.Node.ID)
+		}
+	}
+	expectedNodes := map[string]PlanAction{
+		"r1": ActionUpdate,
+		"r2": ActionRecreate,
+		"r3": ActionAdd,
+		"h1": ActionRemove,
+	}
+	for id, want := range expectedNodes {
+		if got := nodeActions[id]; got != want {
+			t.Errorf("nodo %s: se esperaba %q, se obtuvo %q", id, want, got)
+		}
+	}
+
+	linkActions := map[string]PlanAction{}
+	for _, ch := range plan.Links {
+		linkActions[ch.Link.ID] = ch.Action
+	}
+	expectedLinks := map[string]PlanAction{
+		"l1": ActionRecreate, // r2 obtiene un namespace nuevo
+		"l2": ActionRemove,
+		"l3": ActionAdd,
+	}
+	for id, want := range expectedLinks {
+		if got := linkActions[id]; got != want {
+			t.Errorf("link %s: se esperaba %q, se obtuvo %q", id, want, got)
+		}
+	}
+}
+
+// TestComputePlanNoChanges verifica que un lab convergido produce un plan vacío
+func TestComputePlanNoChanges(t *testing.T) {
+	node := models.Node{ID: "r1", TopologyID: "lab", Name: "r1", Image: "alpine:latest", ContainerID: "c1"}
+	desired := models.Topology{ID: "lab", Nodes: []models.Node{node}}
+
+	plan := computePlan(desired, []models.Node{node}, nil, map[string]bool{"r1": true})
+	if !plan.Empty() {
+		t.Fatalf("se esperaba un plan vacío, se obtuvo %+v", plan)
+	}
+}
+
+// TestComputePlanImpairment verifica que cambiar la emulación WAN no recrea el link
+func TestComputePlanImpairment(t *testing.T) {
+	nodes := []models.Node{
+		{ID: "r1", TopologyID: "lab", Name: "r1", ContainerID: "c1"},
+		{ID: "r2", TopologyID: "lab", Name: "r2", ContainerID: "c2"},
+	}
+	link := models.Link{ID: "l1", TopologyID: "lab", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1"}
+
+	wanted := link
+	wanted.Impairment = models.Impairment{Delay: 40, Loss: 1}
+	desired := models.Topology{ID: "lab", Nodes: []models.Node{nodes[0], nodes[1]}, Links: []models.Link{wanted}}
+
+	plan := computePlan(desired, nodes, []models.Link{link}, map[string]bool{"r1": true, "r2": true})
+	if len(plan.Links) != 1 || plan.Links[0].Action != ActionUpdate {
+		t.Fatalf("se esperaba un único update del link, se obtuvo %+v", plan.Links)
+	}
+}
+
+func TestComputePlanStartupConfig(t *testing.T) {
+	cur := models.Node{ID: "r1", TopologyID: "lab", Name: "r1", Type: models.ROUTER, ContainerID: "c1"}
+
+	// Un mapa vacío equivale a no tener configuración
+	same := cur
+	same.Startup = models.StartupConfig{Files: map[string]string{}}
+	plan := computePlan(models.Topology{ID: "lab", Nodes: []models.Node{same}}, []models.Node{cur}, nil, map[string]bool{"r1": true})
+	if len(plan.Nodes) != 0 {
+		t.Fatalf("no se esperaban cambios, se obtuvo %+v", plan.Nodes)
+	}
+
+	wanted := cur
+	wanted.Startup = models.StartupConfig{FRRConf: "router ospf\n"}
+	plan = computePlan(models.Topology{ID: "lab", Nodes: []models.Node{wanted}}, []models.Node{cur}, nil, map[string]bool{"r1": true})
+	if len(plan.Nodes) != 1 || plan.Nodes[0].Action != ActionRecreate {
+		t.Fatalf("se esperaba recrear el router, se obtuvo %+v", plan.Nodes)
+	}
+}
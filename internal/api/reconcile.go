@@ -0,0 +1,196 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleReconcile repairs drift between storage, Docker and the kernel on demand
+func (s *Server) handleReconcile(c *gin.Context) {
+	report := s.reconcile(c.Request.Context(), c.Query("topology"))
+
+	status := http.StatusOK
+	if report.Failed() {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, report)
+}
+
+// reconcile refreshes every stored node from Docker (container ID, PID, status)
+// and re-creates the links whose veth ends vanished. An empty topologyID covers all labs.
+func (s *Server) reconcile(ctx context.Context, topologyID string) DeployReport {
+	s.plumbMu.Lock()
+	defer s.plumbMu.Unlock()
+
+	report := DeployReport{
+		TopologyID: topologyID,
+		Nodes:      []DeployResult{},
+		Links:      []DeployResult{},
+	}
+
+	var nodes []models.Node
+	var links []models.Link
+	if topologyID != "" {
+		nodes, _ = s.repo.ListNodesByTopology(topologyID)
+		links, _ = s.repo.ListLinksByTopology(topologyID)
+	} else {
+		nodes, _ = s.repo.ListNodes()
+		links, _ = s.repo.ListLinks()
+	}
+
+	// 1. Nodes
+	refreshed := make(map[string]models.Node, len(nodes))
+	moved := make(map[string]bool) // Nodes whose namespace or bridge changed (all veths lost)
+	for _, node := range nodes {
+		res := DeployResult{ID: node.ID, Kind: "node", Action: "refresh", Status: "ok"}
+
+		updated, replaced, err := s.refreshNode(ctx, node)
+		if err != nil {
+			res.Status = "error"
+			res.Error = err.Error()
+		} else if !nodeUp(updated) {
+			res.Status = "skipped"
+			res.Error = fmt.Sprintf("node is %s", updated.Status)
+		}
+		if replaced {
+			moved[node.ID] = true
+		}
+
+		refreshed[node.ID] = updated
+		report.Nodes = append(report.Nodes, res)
+	}
+
+	// 2. Links
+	for _, link := range links {
+		res := DeployResult{ID: link.ID, Kind: "link", Action: "verify", Status: "ok"}
+
+		source, okS := refreshed[link.SourceID]
+		target, okT := refreshed[link.TargetID]
+		if !okS || !okT || !nodeUp(source) || !nodeUp(target) {
+			res.Status = "skipped"
+			res.Error = "source or target node not running"
+			report.Links = append(report.Links, res)
+			continue
+		}
+
+		intact := !moved[source.ID] && !moved[target.ID] && s.linkIntact(link, source, target)
+		if !intact {
+			res.Action = "recreate"
+			if err := s.replumbLink(ctx, link, source, target); err != nil {
+				res.Status = "error"
+				res.Error = err.Error()
+			}
+		}
+		report.Links = append(report.Links, res)
+	}
+
+	return report
+}
+
+// nodeUp reports whether a node keeps its namespace, so its links can be checked.
+// Paused processes are frozen but their interfaces stay in place.
+func nodeUp(node models.Node) bool {
+	return node.Status == models.StatusRunning || node.Status == models.StatusPaused
+}
+
+// refreshNode aligns a stored node with what Docker (or the host, for switches) reports.
+// replaced is true when the namespace or bridge behind the node is a new one, so every
+// link attached to it has to be plumbed again.
+func (s *Server) refreshNode(ctx context.Context, node models.Node) (models.Node, bool, error) {
+	if node.Type == models.SWITCH {
+		node.Status = models.StatusRunning
+		replaced := false
+		if !s.nodeAlive(ctx, node) {
+			// A bridge holds no state of its own: bring it back and re-attach its ports
+			if err := s.network.CreateBridge(orchestrator.BridgeName(node)); err != nil {
+				node.Status = models.StatusError
+			} else {
+				replaced = true
+			}
+		}
+		return node, replaced, s.repo.SaveNode(node)
+	}
+	storedID := node.ContainerID
+
+	// Prefer the stored container, fall back to the openveth labels
+	pid, err := 0, fmt.Errorf("no container")
+	if node.ContainerID != "" {
+		pid, err = s.runtime.GetNodePID(ctx, node.ContainerID)
+	}
+	if err != nil {
+		id, running, errFind := s.runtime.FindNodeContainer(ctx, node)
+		switch {
+		case errFind != nil:
+			return node, false, errFind
+		case id == "":
+			node.ContainerID, node.PID, node.Status = "", 0, models.StatusMissing
+			return node, true, s.repo.SaveNode(node)
+		case !running:
+			node.ContainerID, node.PID, node.Status = id, 0, models.StatusStopped
+			return node, true, s.repo.SaveNode(node)
+		}
+
+		node.ContainerID = id
+		if pid, err = s.runtime.GetNodePID(ctx, id); err != nil {
+			return node, false, err
+		}
+	}
+
+	replaced := pid != node.PID || node.ContainerID != storedID
+	// After an API restart no watcher exists yet, even if the PID is unchanged
+	if !s.watchingKernel(node.ID, pid) {
+		s.watchNodeKernel(models.Node{ID: node.ID, TopologyID: node.TopologyID, Type: node.Type, PID: pid})
+	}
+
+	// A paused container still reports a PID: keep it paused
+	paused, err := s.runtime.NodePaused(ctx, node.ContainerID)
+	if err != nil {
+		return node, false, err
+	}
+
+	node.PID = pid
+	node.Status = models.StatusRunning
+	if paused {
+		node.Status = models.StatusPaused
+	}
+	return node, replaced, s.repo.SaveNode(node)
+}
+
+// linkIntact checks that the container ends of a link still exist in their namespaces
+func (s *Server) linkIntact(link models.Link, source, target models.Node) bool {
+	ends := []struct {
+		node  models.Node
+		iface string
+	}{{source, link.SourceInt}, {target, link.TargetInt}}
+
+	for _, end := range ends {
+		if end.node.Type == models.SWITCH {
+			continue
+		}
+		if ok, err := s.network.InterfaceExists(end.node.PID, end.iface); err != nil || !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// replumbLink removes whatever half of a link survived and wires it again. Requires s.plumbMu.
+func (s *Server) replumbLink(ctx context.Context, link models.Link, source, target models.Node) error {
+	for _, end := range []struct {
+		node  models.Node
+		iface string
+	}{{source, link.SourceInt}, {target, link.TargetInt}} {
+		if end.node.Type == models.SWITCH {
+			continue
+		}
+		if err := s.network.DeleteLink(end.node.PID, end.iface); err != nil {
+			return err
+		}
+	}
+	return s.plumbLink(link, source, target)
+}
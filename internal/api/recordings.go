@@ -0,0 +1,344 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"open-veth/internal/models"
+	"open-veth/internal/recording"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// Replay limits
+const (
+	defaultReplayIdle = 2 * time.Second // Long pauses are shortened, like asciinema's idle_time_limit
+	maxReplaySpeed    = 16.0
+)
+
+// activeRecording is a terminal session being written to disk
+type activeRecording struct {
+	*recording.Writer
+	meta models.TerminalRecording
+	file *os.File
+}
+
+// requestUser identifies who opened a terminal. Browsers cannot set headers on a
+// WebSocket, so ?user= wins over X-OpenVeth-User.
+func requestUser(c *gin.Context) string {
+	if user := c.Query("user"); user != "" {
+		return user
+	}
+	if user := c.GetHeader("X-OpenVeth-User"); user != "" {
+		return user
+	}
+	return "anonymous"
+}
+
+// startRecording creates the recording row and its asciicast file (<dir>/<topology>/<node>/<id>.cast)
+func (s *Server) startRecording(node models.Node, user, shell string, cols, rows uint) (*activeRecording, error) {
+	if cols == 0 || rows == 0 {
+		cols, rows = 80, 24
+	}
+	dir, err := s.recordingDir(node)
+	if err != nil {
+		return nil, err
+	}
+
+	meta, err := s.repo.AddRecording(models.TerminalRecording{
+		TopologyID: node.TopologyID,
+		NodeID:     node.ID,
+		NodeName:   node.Name,
+		User:       user,
+		Shell:      shell,
+		Cols:       cols,
+		Rows:       rows,
+		StartedAt:  time.Now(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error saving recording: %v", err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		_ = s.repo.DeleteRecording(meta.ID)
+		return nil, fmt.Errorf("error creating recordings dir: %v", err)
+	}
+	meta.File = filepath.Join(dir, fmt.Sprintf("%d.cast", meta.ID))
+
+	f, err := os.Create(meta.File)
+	if err != nil {
+		_ = s.repo.DeleteRecording(meta.ID)
+		return nil, fmt.Errorf("error creating recording file: %v", err)
+	}
+
+	w, err := recording.NewWriter(f, recording.Header{
+		Width:  cols,
+		Height: rows,
+		Title:  fmt.Sprintf("%s (%s) - %s", node.Name, node.TopologyID, user),
+		Env:    map[string]string{"SHELL": shell, "TERM": "xterm-256color"},
+	})
+	if err != nil {
+		f.Close()
+		_ = s.repo.DeleteRecording(meta.ID)
+		return nil, fmt.Errorf("error writing recording header: %v", err)
+	}
+
+	if err := s.repo.SaveRecording(meta); err != nil {
+		log.Printf("Warning: could not save recording %d: %v", meta.ID, err)
+	}
+	return &activeRecording{Writer: w, meta: meta, file: f}, nil
+}
+
+// recordingDir is <dir>/<topology>/<node>. Both IDs become path elements, so anything
+// that is not a plain file name (empty, "." or "..", separators) is refused.
+func (s *Server) recordingDir(node models.Node) (string, error) {
+	if !topologyIDPattern.MatchString(node.TopologyID) {
+		return "", fmt.Errorf("cannot record node %s: invalid topology id %q", node.Name, node.TopologyID)
+	}
+	if node.ID == "" || node.ID == "." || node.ID == ".." || strings.ContainsAny(node.ID, `/\`) {
+		return "", fmt.Errorf("cannot record node %s: invalid node id %q", node.Name, node.ID)
+	}
+	return filepath.Join(s.recordingsDir, node.TopologyID, node.ID), nil
+}
+
+// finishRecording closes the file and stores the final size and duration
+func (s *Server) finishRecording(rec *activeRecording) {
+	rec.file.Close()
+
+	now := time.Now()
+	rec.meta.EndedAt = &now
+	rec.meta.DurationSec = rec.Duration().Seconds()
+	if info, err := os.Stat(rec.meta.File); err == nil {
+		rec.meta.Size = info.Size()
+	}
+	if err := s.repo.SaveRecording(rec.meta); err != nil {
+		log.Printf("Warning: could not save recording %d: %v", rec.meta.ID, err)
+	}
+}
+
+// recoverRecordings closes the recordings a previous run left open (the server stopped
+// or crashed mid-session): size and duration come from what reached the .cast file
+func (s *Server) recoverRecordings() {
+	recs, err := s.repo.ListRecordings("", "")
+	if err != nil {
+		return
+	}
+	for _, rec := range recs {
+		if rec.EndedAt != nil {
+			continue
+		}
+		ended := time.Now()
+		if info, err := os.Stat(rec.File); err == nil {
+			rec.Size = info.Size()
+			ended = info.ModTime()
+		}
+		rec.DurationSec = castDuration(rec.File)
+		rec.EndedAt = &ended
+		if err := s.repo.SaveRecording(rec); err != nil {
+			log.Printf("Warning: could not save recording %d: %v", rec.ID, err)
+		}
+	}
+}
+
+// castDuration returns the time of the last complete event of an asciicast file
+func castDuration(path string) float64 {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	r, err := recording.NewReader(f)
+	if err != nil {
+		return 0
+	}
+	last := 0.0
+	for {
+		e, err := r.Next()
+		if err != nil {
+			return last // io.EOF, or a line cut short by the crash
+		}
+		last = e.Time
+	}
+}
+
+// --- Recording Handlers ---
+
+// listRecordings returns recordings, optionally filtered by ?node= and ?user=
+func (s *Server) listRecordings(c *gin.Context) {
+	recs, err := s.repo.ListRecordings(c.Query("node"), c.Query("user"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, recs)
+}
+
+func (s *Server) getRecording(c *gin.Context) {
+	rec, ok := s.recordingParam(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, rec)
+}
+
+// downloadRecording serves the .cast file (playable with 'asciinema play')
+func (s *Server) downloadRecording(c *gin.Context) {
+	rec, ok := s.recordingParam(c)
+	if !ok {
+		return
+	}
+	name := fmt.Sprintf("%s-%s-%d.cast", rec.NodeName, rec.User, rec.ID)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name))
+	c.Header("Content-Type", "application/x-asciicast")
+	c.File(rec.File)
+}
+
+// replayRecording plays a recording over a WebSocket with its original timing,
+// using the terminal protocol: binary frames for output and resize control messages.
+// ?speed= multiplies the pace and ?max_idle= (seconds) caps pauses.
+func (s *Server) replayRecording(c *gin.Context) {
+	rec, ok := s.recordingParam(c)
+	if !ok {
+		return
+	}
+
+	speed := 1.0
+	if v := c.Query("speed"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 || f > maxReplaySpeed {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("speed must be between 0 and %g", maxReplaySpeed)})
+			return
+		}
+		speed = f
+	}
+	maxIdle := defaultReplayIdle
+	if v := c.Query("max_idle"); v != "" {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil || f <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_idle must be a positive number of seconds"})
+			return
+		}
+		maxIdle = time.Duration(f * float64(time.Second))
+	}
+
+	f, err := os.Open(rec.File)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording file not found"})
+		return
+	}
+	defer f.Close()
+
+	cast, err := recording.NewReader(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Error upgrading to websocket: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	// The client only sends a close: stop the replay when it does
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	sendResize := func(cols, rows uint) error {
+		msg, _ := json.Marshal(termControl{Type: "resize", Cols: cols, Rows: rows})
+		return ws.WriteMessage(websocket.TextMessage, msg)
+	}
+	if err := sendResize(cast.Header.Width, cast.Header.Height); err != nil {
+		return
+	}
+
+	last := 0.0
+	for {
+		ev, err := cast.Next()
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("Error reading recording %d: %v", rec.ID, err)
+			}
+			break
+		}
+
+		wait := min(time.Duration((ev.Time-last)/speed*float64(time.Second)), maxIdle)
+		last = ev.Time
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		switch ev.Type {
+		case recording.EventOutput:
+			err = ws.WriteMessage(websocket.BinaryMessage, []byte(ev.Data))
+		case recording.EventResize:
+			if cols, rows, perr := recording.ParseSize(ev.Data); perr == nil {
+				err = sendResize(cols, rows)
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "replay finished")
+	_ = ws.WriteMessage(websocket.CloseMessage, msg)
+}
+
+// deleteRecording removes a recording and its file
+func (s *Server) deleteRecording(c *gin.Context) {
+	rec, ok := s.recordingParam(c)
+	if !ok {
+		return
+	}
+	if rec.EndedAt == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "recording is still in progress"})
+		return
+	}
+	if err := os.Remove(rec.File); err != nil && !os.IsNotExist(err) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.repo.DeleteRecording(rec.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// recordingParam loads the recording of the URL. It writes the error response itself.
+func (s *Server) recordingParam(c *gin.Context) (models.TerminalRecording, bool) {
+	id, err := strconv.ParseUint(c.Param("recId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid recording id"})
+		return models.TerminalRecording{}, false
+	}
+	rec, found := s.repo.GetRecording(uint(id))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "recording not found"})
+		return rec, false
+	}
+	return rec, true
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+)
+
+// validateBudget checks the resource budget of a topology
+func validateBudget(topo models.Topology) error {
+	if _, err := orchestrator.ParseCPU(topo.CPUBudget); err != nil {
+		return fmt.Errorf("invalid cpu_budget %v", err)
+	}
+	if _, err := orchestrator.ParseMemory(topo.RAMBudget); err != nil {
+		return fmt.Errorf("invalid ram_budget %v", err)
+	}
+	return nil
+}
+
+// checkNodeBudget validates the limits of a node and makes sure the lab stays
+// within its budget once the node is added (or replaces its stored version).
+func (s *Server) checkNodeBudget(node models.Node) error {
+	if node.Type == models.SWITCH || node.RuntimeKind() == models.RuntimeNamespace {
+		return nil
+	}
+	if _, _, err := orchestrator.NodeResources(node); err != nil {
+		return err
+	}
+
+	others, _ := s.repo.ListNodesByTopology(node.TopologyID)
+	nodes := []models.Node{node}
+	for _, n := range others {
+		if n.ID != node.ID {
+			nodes = append(nodes, n)
+		}
+	}
+
+	topo, _ := s.repo.GetTopology(node.TopologyID)
+	return checkBudget(topo, nodes)
+}
+
+// checkBudget sums the limits of nodes and compares them with the lab budget.
+// In a budgeted lab every container must declare the limited resource; namespace
+// nodes have no cgroup limits and are not counted.
+func checkBudget(topo models.Topology, nodes []models.Node) error {
+	if err := validateBudget(topo); err != nil {
+		return err
+	}
+	cpuBudget, _ := orchestrator.ParseCPU(topo.CPUBudget)
+	ramBudget, _ := orchestrator.ParseMemory(topo.RAMBudget)
+	if cpuBudget == 0 && ramBudget == 0 {
+		return nil
+	}
+
+	var cpuTotal, ramTotal int64
+	for _, n := range nodes {
+		if n.Type == models.SWITCH || n.RuntimeKind() == models.RuntimeNamespace {
+			continue
+		}
+		cpu, ram, err := orchestrator.NodeResources(n)
+		if err != nil {
+			return fmt.Errorf("node %s: %v", n.Name, err)
+		}
+		if cpuBudget > 0 && cpu == 0 {
+			return fmt.Errorf("node %s: cpu_request is required, lab %s has a cpu budget", n.Name, topo.ID)
+		}
+		if ramBudget > 0 && ram == 0 {
+			return fmt.Errorf("node %s: ram_limit is required, lab %s has a ram budget", n.Name, topo.ID)
+		}
+		cpuTotal += cpu
+		ramTotal += ram
+	}
+
+	if cpuBudget > 0 && cpuTotal > cpuBudget {
+		return fmt.Errorf("lab %s exceeds its cpu budget: %.2f of %s CPUs requested", topo.ID, float64(cpuTotal)/1e9, topo.CPUBudget)
+	}
+	if ramBudget > 0 && ramTotal > ramBudget {
+		return fmt.Errorf("lab %s exceeds its ram budget: %dMiB of %s requested", topo.ID, ramTotal/(1024*1024), topo.RAMBudget)
+	}
+	return nil
+}
@@ -1,14 +1,18 @@
 package api
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
+	"open-veth/internal/events"
 	"open-veth/internal/models"
 	"open-veth/internal/orchestrator"
 	"open-veth/internal/storage"
 
-	"github.com/docker/docker/api/types/container"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
@@ -16,21 +20,34 @@ import (
 // Server encapsulates the HTTP router and dependencies
 type Server struct {
 	router  *gin.Engine
-	manager *orchestrator.Manager
+	runtime orchestrator.NodeRuntime
+	network *orchestrator.NetworkManager
 	repo    storage.Repository
+	ipamMu  sync.Mutex // Serializes pool allocations
+
+	netnsNodes bool       // Namespace-only nodes (host root processes) are opt-in
+	plumbMu    sync.Mutex // Serializes link wiring (API, reconcile and event watcher)
+
+	// Live events (SSE) and per-node netlink watchers
+	events     *events.Bus
+	nsWatchers map[string]*kernelWatch // Key: node ID
+	watchMu    sync.Mutex
+
+	// Terminal recordings (asciicast files) and shared terminal sessions
+	recordingsDir   string
+	sessions        map[string]*termSession   // Key: node ID + "/" + session name
+	sessionsOpening map[string]chan struct{}  // Names reserved while their exec is created
+	liveSessions    map[*termSession]struct{} // Every running session, named or private
+	sessionsMu      sync.Mutex
+
+	// Capture sessions (rotating pcapng files)
+	capturesDir string
+	captures    map[uint]*captureRun
+	capturesMu  sync.Mutex
 }
 
 // NewServer creates and configures the API server instance
-func NewServer(mgr *orchestrator.Manager) *Server {
-	r := gin.Default()
-
-	// CORS configuration
-	config := cors.DefaultConfig()
-	config.AllowAllOrigins = true
-	config.AllowCredentials = true
-	config.AddAllowHeaders("Authorization")
-	r.Use(cors.New(config))
-
+func NewServer(rt orchestrator.NodeRuntime) *Server {
 	// Database Configuration from Environment
 	dbDriver := os.Getenv("DB_DRIVER")
 	if dbDriver == "" {
@@ -51,10 +68,61 @@ func NewServer(mgr *orchestrator.Manager) *Server {
 		repo = dbRepo
 	}
 
+	// Namespace-only nodes run host binaries as root, and exec/terminal on them is a
+	// root shell on the host: they stay off unless NETNS_NODES=true
+	netnsNodes, _ := strconv.ParseBool(os.Getenv("NETNS_NODES"))
+	if netnsNodes {
+		nsDir := os.Getenv("NETNS_DIR")
+		if nsDir == "" {
+			nsDir = "netns"
+		}
+		if namespaces, err := orchestrator.NewNamespaceRuntime(nsDir); err != nil {
+			fmt.Printf("Warning: netns nodes disabled: %v\n", err)
+			netnsNodes = false
+		} else {
+			fmt.Println("Warning: netns nodes enabled, their exec and terminal are root shells on the host")
+			rt = orchestrator.NewMultiRuntime(rt, namespaces)
+		}
+	}
+
+	s := newServer(rt, orchestrator.NewNetworkManager(), repo)
+	s.netnsNodes = netnsNodes
+	if dir := os.Getenv("RECORDINGS_DIR"); dir != "" {
+		s.recordingsDir = dir
+	}
+	if dir := os.Getenv("CAPTURES_DIR"); dir != "" {
+		s.capturesDir = dir
+	}
+	return s
+}
+
+// newServer wires the router around a runtime, the kernel network and a repository
+// (tests use the fakes)
+func newServer(rt orchestrator.NodeRuntime, network *orchestrator.NetworkManager, repo storage.Repository) *Server {
+	r := gin.Default()
+
+	// CORS configuration
+	config := cors.DefaultConfig()
+	config.AllowAllOrigins = true
+	config.AllowCredentials = true
+	config.AddAllowHeaders("Authorization")
+	r.Use(cors.New(config))
+
 	s := &Server{
-		router:  r,
-		manager: mgr,
-		repo:    repo,
+		router:     r,
+		runtime:    rt,
+		network:    network,
+		repo:       repo,
+		events:     events.NewBus(),
+		nsWatchers: make(map[string]*kernelWatch),
+
+		recordingsDir:   "recordings",
+		sessions:        make(map[string]*termSession),
+		sessionsOpening: make(map[string]chan struct{}),
+		liveSessions:    make(map[*termSession]struct{}),
+
+		capturesDir: "captures",
+		captures:    make(map[uint]*captureRun),
 	}
 
 	s.setupRoutes()
@@ -71,39 +139,116 @@ func (s *Server) setupRoutes() {
 	api := s.router.Group("/api/v1")
 	{
 		// Terminal (Websocket)
-		api.GET("/terminal", s.handleTerminal)
+		// ?record=true&user=<name> records it, ?session=<name>&mode=read|write shares it
+		api.GET("/terminal", s.handleTerminal) // Legacy: ?node=<id|name>
+		api.GET("/nodes/:id/terminal", s.handleTerminal)
+		api.GET("/terminal/sessions", s.listTermSessions) // Shared sessions (?node=)
+
+		// Terminal recordings (asciicast v2)
+		api.GET("/recordings", s.listRecordings) // ?node=&user=
+		api.GET("/recordings/:recId", s.getRecording)
+		api.GET("/recordings/:recId/download", s.downloadRecording)
+		api.GET("/recordings/:recId/replay", s.replayRecording) // WebSocket, same frames as the terminal
+		api.DELETE("/recordings/:recId", s.deleteRecording)
+
+		// Packet capture sessions (pcapng ring buffers)
+		api.GET("/captures", s.listCaptures) // ?topology=
+		api.POST("/captures", s.createCapture)
+		api.GET("/captures/:capId", s.getCapture)
+		api.POST("/captures/:capId/stop", s.stopCapture)
+		api.DELETE("/captures/:capId", s.deleteCapture)
+		api.GET("/captures/:capId/files", s.listCaptureFiles)
+		api.GET("/captures/:capId/files/:file", s.downloadCaptureFile)
+		api.GET("/captures/:capId/summary", s.captureSummary) // Decoded in Go (?protocol=&offset=&limit=)
+
+		// Live events (Server-Sent Events)
+		api.GET("/events", s.handleEvents)
+
+		// Topologies (Labs)
+		api.GET("/topologies", s.listTopologies)
+		api.POST("/topologies", s.createTopology)
+		api.GET("/topologies/:id", s.getTopology)
+		api.PUT("/topologies/:id", s.updateTopology)
+		api.DELETE("/topologies/:id", s.deleteTopology)
+		api.POST("/topologies/:id/plan", s.planTopology)   // Lab file (JSON/YAML) diff
+		api.POST("/topologies/:id/apply", s.applyTopology) // Execute the diff
 
 		// Nodes
 		api.GET("/nodes", s.listNodes)
 		api.POST("/nodes", s.createNode)
 		api.DELETE("/nodes/:id", s.deleteNode)
 		api.GET("/nodes/:id/interfaces", s.getNodeInterfaces) // New Real-Time endpoint
+		api.GET("/nodes/:id/interfaces/:ifname/addresses", s.listInterfaceAddresses)
+		api.POST("/nodes/:id/interfaces/:ifname/addresses", s.addInterfaceAddress)
+		api.DELETE("/nodes/:id/interfaces/:ifname/addresses/:addrId", s.deleteInterfaceAddress)
+		api.POST("/nodes/:id/loopback", s.allocateLoopback)  // IPAM /32 for routers
+		api.PUT("/nodes/:id/startup", s.updateStartupConfig) // frr.conf, files and commands
+		api.POST("/nodes/:id/exec", s.execNode)
+		api.POST("/nodes/:id/exec/stream", s.execNodeStream) // Chunked NDJSON output
+
+		// Router config snapshots (running-config history)
+		api.GET("/nodes/:id/snapshots", s.listSnapshots)
+		api.POST("/nodes/:id/snapshots", s.saveSnapshot)
+		api.GET("/nodes/:id/snapshots/diff", s.diffSnapshots) // ?from=<id>&to=<id|running>
+		api.POST("/nodes/:id/snapshots/:snapId/restore", s.restoreSnapshot)
+		api.DELETE("/nodes/:id/snapshots/:snapId", s.deleteSnapshot)
+
+		// Node lifecycle
+		for _, action := range []string{"start", "stop", "restart", "pause", "unpause"} {
+			api.POST("/nodes/:id/"+action, s.nodeLifecycle(action))
+		}
 		
 		// Links
 		api.GET("/links", s.listLinks)
 		api.POST("/links", s.createLink)
 		api.DELETE("/links/:id", s.deleteLink)
+		api.PATCH("/links/:id/impairment", s.updateLinkImpairment) // Live netem/tbf changes
+		api.GET("/links/:id/capture", s.captureLink)               // pcapng stream (?end=&filter=)
+
+		// Topology (Batch)
+		api.POST("/topology/deploy", s.deployTopology)
 
 		// Global Cleanup
 		api.DELETE("/system/cleanup", s.handleCleanup)
+		api.POST("/system/reconcile", s.handleReconcile)
 	}
 }
 
-// Run starts the server
+// Run reconciles stored labs with Docker and the kernel, starts the container
+// event watcher and then serves HTTP
 func (s *Server) Run(addr string) error {
+	report := s.reconcile(context.Background(), "")
+	fmt.Printf("Startup reconciliation: %d nodes, %d links checked\n", len(report.Nodes), len(report.Links))
+	for _, res := range append(report.Nodes, report.Links...) {
+		if res.Status != "ok" {
+			fmt.Printf("Warning: %s %s %s: %s\n", res.Kind, res.ID, res.Status, res.Error)
+		}
+	}
+
+	s.recoverCaptures()
+	s.recoverRecordings()
+
+	// Re-plumb links whenever a lab container restarts
+	go s.watchContainers(context.Background())
+
 	return s.router.Run(addr)
 }
 
 // --- Node Handlers ---
 
 func (s *Server) listNodes(c *gin.Context) {
-	nodes, _ := s.repo.ListNodes()
+	var nodes []models.Node
+	if topologyID := c.Query("topology"); topologyID != "" {
+		nodes, _ = s.repo.ListNodesByTopology(topologyID)
+	} else {
+		nodes, _ = s.repo.ListNodes()
+	}
 
 	// If real-time info is requested
 	if c.Query("live") == "true" {
 		for i := range nodes {
 			if nodes[i].ContainerID != "" {
-				if ifaces, err := s.manager.GetNodeInterfaces(c.Request.Context(), nodes[i].ContainerID); err == nil {
+				if ifaces, err := s.runtime.GetNodeInterfaces(c.Request.Context(), nodes[i].ContainerID); err == nil {
 					nodes[i].Interfaces = ifaces
 				}
 			}
@@ -120,25 +265,54 @@ func (s *Server) createNode(c *gin.Context) {
 		return
 	}
 
-	containerID, err := s.manager.CreateNode(c.Request.Context(), node)
+	topologyID, err := s.ensureTopology(node.TopologyID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	node.TopologyID = topologyID
 
-	pid, err := s.manager.GetNodePID(c.Request.Context(), containerID)
+	if err := checkNodeName(node); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := node.Startup.Validate(node.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.checkRuntime(node); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.checkNodeBudget(node); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.checkNodeID(node); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
+	node, err = s.provisionNode(c.Request.Context(), node)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	node.ContainerID = containerID
-	node.PID = pid
-	s.repo.SaveNode(node)
-
 	c.JSON(http.StatusCreated, node)
 }
 
+// checkRuntime validates the runtime of a node and that this server offers it
+func (s *Server) checkRuntime(node models.Node) error {
+	if err := node.ValidateRuntime(); err != nil {
+		return err
+	}
+	if node.RuntimeKind() == models.RuntimeNamespace && !s.netnsNodes {
+		return fmt.Errorf("netns nodes are disabled on this server (NETNS_NODES=true enables them)")
+	}
+	return nil
+}
+
 func (s *Server) deleteNode(c *gin.Context) {
 	id := c.Param("id")
 	node, found := s.repo.GetNode(id)
@@ -147,11 +321,60 @@ func (s *Server) deleteNode(c *gin.Context) {
 		return
 	}
 
-	_ = s.manager.DeleteNode(c.Request.Context(), node.Name)
-	s.repo.DeleteNode(id)
+	report := s.removeNode(c.Request.Context(), node)
+	if report.Failed() {
+		c.JSON(http.StatusMultiStatus, report)
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
+// removeNode deletes every link attached to a node (kernel and storage) and then the node.
+// Failures are collected per element; the node row is kept if its container survives.
+func (s *Server) removeNode(ctx context.Context, node models.Node) DeployReport {
+	report := DeployReport{
+		TopologyID: node.TopologyID,
+		Nodes:      []DeployResult{},
+		Links:      []DeployResult{},
+	}
+
+	links, _ := s.repo.ListLinks()
+	for _, link := range links {
+		if link.SourceID != node.ID && link.TargetID != node.ID {
+			continue
+		}
+
+		res := DeployResult{ID: link.ID, Kind: "link", Action: string(ActionRemove), Status: "ok"}
+		err := s.teardownLink(ctx, link)
+		if err == nil {
+			err = s.repo.DeleteLinkAddresses(link.ID)
+		}
+		if err == nil {
+			err = s.repo.DeleteLink(link.ID)
+		}
+		if err != nil {
+			res.Status = "error"
+			res.Error = err.Error()
+		}
+		report.Links = append(report.Links, res)
+	}
+
+	res := DeployResult{ID: node.ID, Kind: "node", Action: string(ActionRemove), Status: "ok"}
+	err := s.destroyNode(ctx, node)
+	if err == nil {
+		err = s.repo.DeleteNode(node.ID)
+	}
+	if err != nil {
+		res.Status = "error"
+		res.Error = err.Error()
+	} else {
+		s.publish(events.Event{Type: events.NodeDeleted, TopologyID: node.TopologyID, NodeID: node.ID})
+	}
+	report.Nodes = append(report.Nodes, res)
+
+	return report
+}
+
 func (s *Server) getNodeInterfaces(c *gin.Context) {
 	id := c.Param("id")
 	node, found := s.repo.GetNode(id)
@@ -165,7 +388,7 @@ func (s *Server) getNodeInterfaces(c *gin.Context) {
 		return
 	}
 
-	interfaces, err := s.manager.GetNodeInterfaces(c.Request.Context(), node.ContainerID)
+	interfaces, err := s.runtime.GetNodeInterfaces(c.Request.Context(), node.ContainerID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -177,7 +400,12 @@ func (s *Server) getNodeInterfaces(c *gin.Context) {
 // --- Handlers de Links ---
 
 func (s *Server) listLinks(c *gin.Context) {
-	links, _ := s.repo.ListLinks()
+	var links []models.Link
+	if topologyID := c.Query("topology"); topologyID != "" {
+		links, _ = s.repo.ListLinksByTopology(topologyID)
+	} else {
+		links, _ = s.repo.ListLinks()
+	}
 	c.JSON(http.StatusOK, links)
 }
 
@@ -188,6 +416,15 @@ func (s *Server) createLink(c *gin.Context) {
 		return
 	}
 
+	if err := link.Impairment.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, ok := link.IPAM.PrefixLen(); !ok && link.IPAM != models.IPAMNone {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid ipam mode (ipv4/30, ipv4/31, ipv6/127)"})
+		return
+	}
+
 	source, okS := s.repo.GetNode(link.SourceID)
 	target, okT := s.repo.GetNode(link.TargetID)
 
@@ -196,41 +433,160 @@ func (s *Server) createLink(c *gin.Context) {
 		return
 	}
 
+	// Links never cross labs
+	if source.TopologyID != target.TopologyID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source and target belong to different topologies"})
+		return
+	}
+	link.TopologyID = source.TopologyID
+	if err := s.checkLinkID(link); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Validation: Check for existing link between these nodes
+	if s.linkExists(link) {
+		c.JSON(http.StatusConflict, gin.H{"error": "link already exists between these nodes"})
+		return
+	}
+
+	if err := s.provisionLink(link, source, target); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, link)
+}
+
+// linkExists checks for a stored link between the same nodes (both directions)
+func (s *Server) linkExists(link models.Link) bool {
 	existingLinks, _ := s.repo.ListLinks()
 	for _, l := range existingLinks {
-		// Check both directions
 		if (l.SourceID == link.SourceID && l.TargetID == link.TargetID) ||
-		   (l.SourceID == link.TargetID && l.TargetID == link.SourceID) {
-			c.JSON(http.StatusConflict, gin.H{"error": "link already exists between these nodes"})
-			return
+			(l.SourceID == link.TargetID && l.TargetID == link.SourceID) {
+			return true
 		}
 	}
+	return false
+}
 
-	nm := orchestrator.NewNetworkManager()
-	if err := nm.CreateLink(link, source.PID, target.PID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// updateLinkImpairment replaces the impairment settings of a running link
+func (s *Server) updateLinkImpairment(c *gin.Context) {
+	link, found := s.repo.GetLink(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
 		return
 	}
 
-	s.repo.SaveLink(link)
-	c.JSON(http.StatusCreated, link)
+	var imp models.Impairment
+	if err := c.ShouldBindJSON(&imp); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := imp.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link.Impairment = imp
+	if err := s.applyImpairment(c.Request.Context(), link); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, errNotRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, link)
+}
+
+// applyImpairment reconfigures tc on both ends of a link and persists the settings.
+// The stored PIDs may be stale after a restart, so each end is looked up in the runtime.
+func (s *Server) applyImpairment(ctx context.Context, link models.Link) error {
+	s.plumbMu.Lock()
+	defer s.plumbMu.Unlock()
+
+	source, okS := s.repo.GetNode(link.SourceID)
+	target, okT := s.repo.GetNode(link.TargetID)
+	if !okS || !okT {
+		return fmt.Errorf("source or target node not found")
+	}
+
+	// Bridge ports of switches live on the host: only node ends are shaped
+	ends := []struct {
+		node  models.Node
+		iface string
+		pid   int
+	}{{node: source, iface: link.SourceInt}, {node: target, iface: link.TargetInt}}
+	for i, end := range ends {
+		if end.node.Type == models.SWITCH {
+			continue
+		}
+		if err := requireNamespace(end.node); err != nil {
+			return err
+		}
+		pid, ok := s.nodePID(ctx, end.node)
+		if !ok {
+			return fmt.Errorf("%w: %s has no process", errNotRunning, end.node.Name)
+		}
+		ends[i].pid = pid
+	}
+	for _, end := range ends {
+		if end.node.Type == models.SWITCH {
+			continue
+		}
+		if err := s.network.ApplyImpairment(end.pid, end.iface, link.Impairment); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.SaveLink(link); err != nil {
+		return fmt.Errorf("error saving link %s: %v", link.ID, err)
+	}
+	return nil
 }
 
+// deleteLink removes the veth pair from the kernel and then the stored link.
+// ?force=true drops the stored link even if the kernel teardown fails.
 func (s *Server) deleteLink(c *gin.Context) {
 	id := c.Param("id")
-	s.repo.DeleteLink(id)
+	link, found := s.repo.GetLink(id)
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "link not found"})
+		return
+	}
+
+	if err := s.teardownLink(c.Request.Context(), link); err != nil && c.Query("force") != "true" {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Addresses first: orphaned rows would keep their IPAM entries reserved for good
+	if err := s.repo.DeleteLinkAddresses(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := s.repo.DeleteLink(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 	c.Status(http.StatusNoContent)
 }
 
 // handleCleanup elimina todos los contenedores con label openveth=true
 func (s *Server) handleCleanup(c *gin.Context) {
 	ctx := c.Request.Context()
-	containers, _ := s.manager.GetDockerClient().ContainerList(ctx, container.ListOptions{All: true})
-
+	containers, _ := s.runtime.ListNodes(ctx)
 	for _, ct := range containers {
-		if ct.Labels["openveth"] == "true" {
-			_ = s.manager.GetDockerClient().ContainerRemove(ctx, ct.ID, container.RemoveOptions{Force: true})
+		_ = s.runtime.DeleteNode(ctx, ct.ID)
+	}
+
+	// Switches are host bridges, not containers
+	nodes, _ := s.repo.ListNodes()
+	for _, node := range nodes {
+		if node.Type == models.SWITCH {
+			_ = s.network.DeleteBridge(orchestrator.BridgeName(node))
 		}
 	}
 
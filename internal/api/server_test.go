@@ -0,0 +1,1427 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"open-veth/internal/capture"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+	"open-veth/internal/storage"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+// newTestServer arma el servidor con el runtime y el kernel falsos y el repositorio
+// en memoria. El kernel queda en rt.Kernel.
+func newTestServer(t *testing.T) (*Server, *orchestrator.FakeRuntime) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	rt := orchestrator.NewFakeRuntime()
+	rt.Kernel = orchestrator.NewFakeKernel()
+	s := newServer(rt, orchestrator.NewNetworkManagerWithKernel(rt.Kernel), storage.NewMemoryRepository())
+	s.recordingsDir = t.TempDir()
+	s.capturesDir = t.TempDir()
+	return s, rt
+}
+
+// request hace una llamada a la API y devuelve la respuesta
+func request(t *testing.T, s *Server, method, path string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, "/api/v1"+path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeBody interpreta la respuesta JSON y verifica el código
+func decodeBody[T any](t *testing.T, w *httptest.ResponseRecorder, status int) T {
+	t.Helper()
+	var v T
+	if w.Code != status {
+		t.Fatalf("se esperaba %d, se obtuvo %d: %s", status, w.Code, w.Body.String())
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("respuesta inválida: %v (%s)", err, w.Body.String())
+	}
+	return v
+}
+
+// createRouter crea un router con una frr.conf inicial
+func createRouter(t *testing.T, s *Server, name string) models.Node {
+	t.Helper()
+	return decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{
+		ID:      name,
+		Name:    name,
+		Type:    models.ROUTER,
+		Image:   "frrouting/frr:latest",
+		Startup: models.StartupConfig{FRRConf: "hostname " + name + "\n"},
+	}), http.StatusCreated)
+}
+
+func TestNodeLifecycleWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+
+	node := createRouter(t, s, "r1")
+	if node.ContainerID == "" || node.PID == 0 || node.Status != models.StatusRunning {
+		t.Fatalf("nodo inesperado: %+v", node)
+	}
+	if _, config, ok := rt.Node(node.ContainerID); !ok || config != "hostname r1\n" {
+		t.Errorf("el runtime debería tener el contenedor con su frr.conf, se obtuvo %q", config)
+	}
+
+	stopped := decodeBody[models.Node](t, request(t, s, "POST", "/nodes/"+node.ID+"/stop", nil), http.StatusOK)
+	if stopped.Status != models.StatusStopped || stopped.PID != 0 {
+		t.Errorf("nodo detenido inesperado: %+v", stopped)
+	}
+	started := decodeBody[models.Node](t, request(t, s, "POST", "/nodes/"+node.ID+"/start", nil), http.StatusOK)
+	if started.Status != models.StatusRunning || started.PID == 0 || started.PID == node.PID {
+		t.Errorf("al arrancar debería tener un PID nuevo: %+v", started)
+	}
+
+	if w := request(t, s, "POST", "/nodes/"+node.ID+"/unpause", nil); w.Code != http.StatusConflict {
+		t.Errorf("unpause de un nodo sin pausar debería dar 409, se obtuvo %d", w.Code)
+	}
+	if got, _ := s.repo.GetNode(node.ID); got.Status != models.StatusRunning {
+		t.Errorf("un unpause rechazado no debería cambiar el estado: %s", got.Status)
+	}
+
+	// Un error del runtime tampoco marca el nodo como roto
+	rt.Fail("PauseNode", errors.New("daemon timeout"))
+	if w := request(t, s, "POST", "/nodes/"+node.ID+"/pause", nil); w.Code != http.StatusInternalServerError {
+		t.Errorf("se esperaba 500, se obtuvo %d", w.Code)
+	}
+	if got, _ := s.repo.GetNode(node.ID); got.Status != models.StatusRunning {
+		t.Errorf("un error del runtime no debería cambiar el estado: %s", got.Status)
+	}
+	rt.Fail("PauseNode", nil)
+
+	if w := request(t, s, "DELETE", "/nodes/"+node.ID, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if _, _, ok := rt.Node(node.ContainerID); ok {
+		t.Errorf("el contenedor debería haberse borrado")
+	}
+}
+
+func TestCreateNodeRuntimeError(t *testing.T) {
+	s, rt := newTestServer(t)
+	rt.Fail("CreateNode", errors.New("no space left on device"))
+
+	w := request(t, s, "POST", "/nodes", models.Node{ID: "h1", Name: "h1", Type: models.HOST, Image: "alpine"})
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "no space left") {
+		t.Errorf("se esperaba el error del runtime, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCreateNodePIDErrorRemovesContainer(t *testing.T) {
+	s, rt := newTestServer(t)
+	rt.Fail("GetNodePID", errors.New("container exited"))
+
+	if w := request(t, s, "POST", "/nodes", models.Node{ID: "h1", Name: "h1", Type: models.HOST, Image: "alpine"}); w.Code != http.StatusInternalServerError {
+		t.Fatalf("se esperaba 500, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if left, _ := rt.ListNodes(context.Background()); len(left) != 0 {
+		t.Errorf("no debería quedar un contenedor huérfano: %+v", left)
+	}
+}
+
+func TestCreateNodeValidatesRuntime(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	tests := []struct {
+		name string
+		node models.Node
+	}{
+		{"runtime desconocido", models.Node{Runtime: "lxc"}},
+		{"comando en un contenedor", models.Node{Image: "alpine", Command: []string{"sleep", "infinity"}}},
+		{"netns con imagen", models.Node{Runtime: models.RuntimeNamespace, Image: "alpine"}},
+		{"netns con frr.conf", models.Node{Runtime: models.RuntimeNamespace, Startup: models.StartupConfig{FRRConf: "hostname r1\n"}}},
+		{"netns sin NETNS_NODES", models.Node{Runtime: models.RuntimeNamespace, Command: []string{"bird", "-f"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.node.ID, tt.node.Name, tt.node.Type = "n1", "n1", models.ROUTER
+			if w := request(t, s, "POST", "/nodes", tt.node); w.Code != http.StatusBadRequest {
+				t.Errorf("se esperaba 400, se obtuvo %d: %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateNodeValidatesName(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	for _, name := range []string{"", "r_1", "r 1", "-r1", "r1-", "r1.lab", strings.Repeat("r", 64)} {
+		w := request(t, s, "POST", "/nodes", models.Node{ID: "n1", Name: name, Type: models.HOST, Image: "alpine"})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("el nombre %q debería dar 400, se obtuvo %d", name, w.Code)
+		}
+	}
+	decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{ID: "n1", Name: "R1-core", Type: models.HOST, Image: "alpine"}), http.StatusCreated)
+}
+
+func TestExecWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+	rt.ExecHandler = func(id string, cmd []string) orchestrator.ExecResult {
+		return orchestrator.ExecResult{Stdout: strings.Join(cmd, " ") + "\n", ExitCode: 3}
+	}
+	node := createRouter(t, s, "r1")
+
+	res := decodeBody[orchestrator.ExecResult](t, request(t, s, "POST", "/nodes/"+node.ID+"/exec",
+		gin.H{"cmd": []string{"ip", "route"}}), http.StatusOK)
+	if res.Stdout != "ip route\n" || res.ExitCode != 3 {
+		t.Errorf("resultado inesperado: %+v", res)
+	}
+
+	// Streaming: una línea por chunk y el código de salida al final
+	w := request(t, s, "POST", "/nodes/"+node.ID+"/exec/stream", gin.H{"shell": "ping -c1 10.0.0.2"})
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	var last struct {
+		ExitCode *int `json:"exit_code"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil || last.ExitCode == nil || *last.ExitCode != 3 {
+		t.Errorf("última línea inesperada: %q", lines[len(lines)-1])
+	}
+}
+
+func TestSnapshotsWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+	node := createRouter(t, s, "r1")
+	base := "/nodes/" + node.ID + "/snapshots"
+
+	first := decodeBody[models.ConfigSnapshot](t, request(t, s, "POST", base, gin.H{"note": "inicial"}), http.StatusCreated)
+	if first.Version != 1 || first.Config != "hostname r1\n" {
+		t.Fatalf("snapshot inesperado: %+v", first)
+	}
+
+	_ = rt.RestoreFRRConfig(context.Background(), node.ContainerID, "hostname r1\nrouter ospf\n")
+	diff := decodeBody[struct {
+		Diff string `json:"diff"`
+	}](t, request(t, s, "GET", base+"/diff?from=1&to=running", nil), http.StatusOK)
+	if !strings.Contains(diff.Diff, "+router ospf") {
+		t.Errorf("diff inesperado:\n%s", diff.Diff)
+	}
+
+	// Guardar no toca la config de arranque: aplicar el mismo lab file no recrea el router
+	decodeBody[models.ConfigSnapshot](t, request(t, s, "POST", base, nil), http.StatusCreated)
+	if stored, _ := s.repo.GetNode(node.ID); stored.Startup.FRRConf != "hostname r1\n" {
+		t.Errorf("la frr.conf de arranque no debería cambiar: %q", stored.Startup.FRRConf)
+	}
+	lab := models.Topology{ID: node.TopologyID, Nodes: []models.Node{{
+		ID: "r1", Name: "r1", Type: models.ROUTER, Image: "frrouting/frr:latest",
+		Startup: models.StartupConfig{FRRConf: "hostname r1\n"},
+	}}}
+	if plan := decodeBody[Plan](t, request(t, s, "POST", "/topologies/"+node.TopologyID+"/plan", lab), http.StatusOK); !plan.Empty() {
+		t.Errorf("el plan debería estar vacío: %+v", plan)
+	}
+
+	if w := request(t, s, "POST", base+"/1/restore", nil); w.Code != http.StatusOK {
+		t.Fatalf("restore falló: %d %s", w.Code, w.Body.String())
+	}
+	if _, config, _ := rt.Node(node.ContainerID); config != "hostname r1\n" {
+		t.Errorf("el restore debería volver a la config guardada, quedó %q", config)
+	}
+}
+
+func TestTerminalWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+	node := createRouter(t, s, "r1")
+
+	srv := httptest.NewServer(s.router)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/nodes/" + node.ID + "/terminal?cols=80&rows=24"
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("error conectando: %v", err)
+	}
+	defer ws.Close()
+
+	// Primero llega el tamaño, después el eco de lo que escribimos
+	_ = ws.WriteMessage(websocket.TextMessage, []byte(`{"type":"resize","cols":132,"rows":43}`))
+	_ = ws.WriteMessage(websocket.BinaryMessage, []byte("show version\r"))
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var got bytes.Buffer
+	for !strings.Contains(got.String(), "show version") {
+		kind, msg, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("error leyendo: %v (recibido %q)", err, got.String())
+		}
+		if kind == websocket.BinaryMessage {
+			got.Write(msg)
+		}
+	}
+
+	execID, _ := rt.LastExecID(node.ContainerID)
+	if cols, rows, ok := rt.TerminalSize(execID); !ok || cols != 132 || rows != 43 {
+		t.Errorf("el resize debería llegar al runtime, se obtuvo %dx%d", cols, rows)
+	}
+}
+
+func TestLegacyTerminalRouteByName(t *testing.T) {
+	s, _ := newTestServer(t)
+	decodeBody[models.Topology](t, request(t, s, "POST", "/topologies", models.Topology{ID: "lab-b"}), http.StatusCreated)
+	decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{
+		ID: "b-r1", Name: "r1", TopologyID: "lab-b", Type: models.HOST, Image: "alpine",
+	}), http.StatusCreated)
+	createRouter(t, s, "r1")
+
+	srv := httptest.NewServer(s.router)
+	defer srv.Close()
+	base := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/terminal?node=r1"
+
+	// El nombre se resuelve al nodo del laboratorio pedido y se abre en su contenedor
+	for _, query := range []string{"&topology=lab-b", "&topology=default"} {
+		ws, resp, err := websocket.DefaultDialer.Dial(base+query, nil)
+		if err != nil {
+			t.Errorf("%s: error conectando: %v (%v)", query, err, resp)
+			continue
+		}
+		ws.Close()
+	}
+	if _, resp, err := websocket.DefaultDialer.Dial(base+"&topology=lab-c", nil); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("un laboratorio sin ese nodo debería dar 404: %v", resp)
+	}
+}
+
+func TestCleanupWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+	createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+
+	if w := request(t, s, "DELETE", "/system/cleanup", nil); w.Code != http.StatusOK {
+		t.Fatalf("cleanup falló: %d %s", w.Code, w.Body.String())
+	}
+	if left, _ := rt.ListNodes(context.Background()); len(left) != 0 {
+		t.Errorf("quedaron contenedores: %+v", left)
+	}
+	nodes := decodeBody[[]models.Node](t, request(t, s, "GET", "/nodes", nil), http.StatusOK)
+	if len(nodes) != 0 {
+		t.Errorf("quedaron nodos guardados: %+v", nodes)
+	}
+}
+
+func TestLinksAndAddressesWithFakeKernel(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	r2 := createRouter(t, s, "r2")
+	sw := decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{ID: "sw1", Name: "sw1", Type: models.SWITCH}), http.StatusCreated)
+
+	// Router a router: un veth con un extremo en cada namespace
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: r1.ID, TargetID: r2.ID, SourceInt: "eth1", TargetInt: "eth1",
+		Impairment: models.Impairment{Delay: 20},
+	}), http.StatusCreated)
+	if pid, name, ok := rt.Kernel.Peer(r1.PID, "eth1"); !ok || pid != r2.PID || name != "eth1" {
+		t.Fatalf("r1:eth1 debería estar conectada a r2:eth1, se obtuvo pid %d %q", pid, name)
+	}
+	if imp, _ := rt.Kernel.Impairment(r2.PID, "eth1"); imp.Delay != 20 {
+		t.Errorf("el impairment debería aplicarse en ambos extremos: %+v", imp)
+	}
+
+	// Router a switch: el extremo del host queda como puerto del bridge
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l2", SourceID: r1.ID, TargetID: sw.ID, SourceInt: "eth2", TargetInt: "p1",
+	}), http.StatusCreated)
+	_, port, ok := rt.Kernel.Peer(r1.PID, "eth2")
+	bridge, _ := rt.Kernel.LinkByName(orchestrator.HostPID, orchestrator.BridgeName(sw))
+	if hostEnd, _ := rt.Kernel.LinkByName(orchestrator.HostPID, port); !ok || hostEnd.MasterIndex != bridge.Index {
+		t.Errorf("r1:eth2 debería terminar en un puerto de %s", bridge.Name)
+	}
+
+	addr := decodeBody[models.InterfaceAddress](t, request(t, s, "POST", "/nodes/r1/interfaces/eth1/addresses",
+		gin.H{"address": "10.0.0.1/30"}), http.StatusCreated)
+	if cidrs, _ := rt.Kernel.AddrList(r1.PID, "eth1"); len(cidrs) != 1 || cidrs[0] != "10.0.0.1/30" {
+		t.Errorf("la dirección debería estar en el kernel: %v", cidrs)
+	}
+	if w := request(t, s, "POST", "/nodes/r1/interfaces/eth9/addresses", gin.H{"address": "10.9.0.1/24"}); w.Code != http.StatusInternalServerError {
+		t.Errorf("una interfaz inexistente debería fallar, se obtuvo %d", w.Code)
+	}
+	if w := request(t, s, "DELETE", "/nodes/r1/interfaces/eth1/addresses/"+strconv.Itoa(int(addr.ID)), nil); w.Code != http.StatusNoContent {
+		t.Errorf("se esperaba 204 al quitar la dirección, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+
+	// Borrar el link quita ambos extremos
+	if w := request(t, s, "DELETE", "/links/l1", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := rt.Kernel.LinkByName(r2.PID, "eth1"); !errors.Is(err, orchestrator.ErrLinkNotFound) {
+		t.Errorf("r2:eth1 debería haber desaparecido: %v", err)
+	}
+}
+
+// addressFailRepo falla al liberar las direcciones de un link
+type addressFailRepo struct {
+	storage.Repository
+}
+
+func (addressFailRepo) DeleteLinkAddresses(string) error {
+	return errors.New("database is locked")
+}
+
+func TestDeleteLinkAddressError(t *testing.T) {
+	s, _ := newTestServer(t)
+	createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1", IPAM: models.IPAMv4Net31,
+	}), http.StatusCreated)
+
+	repo := s.repo
+	s.repo = addressFailRepo{repo}
+	if w := request(t, s, "DELETE", "/links/l1", nil); w.Code != http.StatusInternalServerError {
+		t.Fatalf("se esperaba 500, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	// El link queda para reintentar: sus direcciones no pueden quedar huérfanas
+	if _, found := repo.GetLink("l1"); !found {
+		t.Error("el link debería seguir guardado")
+	}
+
+	s.repo = repo
+	if w := request(t, s, "DELETE", "/links/l1", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if addrs, _ := repo.ListAddresses("r1"); len(addrs) != 0 {
+		t.Errorf("las direcciones deberían liberarse: %+v", addrs)
+	}
+}
+
+func TestManualAddressIPAMConflict(t *testing.T) {
+	s, _ := newTestServer(t)
+	createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	createRouter(t, s, "r3")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1", IPAM: models.IPAMv4Net31,
+	}), http.StatusCreated)
+	addrs, _ := s.repo.ListAddresses("r1")
+	if len(addrs) != 1 {
+		t.Fatalf("se esperaba una dirección de IPAM: %+v", addrs)
+	}
+	allocated := netip.MustParsePrefix(addrs[0].Address)
+
+	// Una dirección manual no puede pisar el pool ya asignado, ni en otro nodo
+	overlap := netip.PrefixFrom(allocated.Addr(), 24).String()
+	if w := request(t, s, "POST", "/nodes/r3/interfaces/eth9/addresses", gin.H{"address": overlap}); w.Code != http.StatusConflict {
+		t.Errorf("%s se superpone con %s, se esperaba 409, se obtuvo %d", overlap, allocated, w.Code)
+	}
+
+	// La siguiente subred libre, tomada a mano, queda reservada para el IPAM
+	manual := netip.PrefixFrom(allocated.Masked().Addr().Next().Next(), 31)
+	decodeBody[models.InterfaceAddress](t, request(t, s, "POST", "/nodes/r2/interfaces/eth1/addresses", gin.H{"address": manual.String()}), http.StatusCreated)
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l2", SourceID: "r1", TargetID: "r3", SourceInt: "eth2", TargetInt: "eth1", IPAM: models.IPAMv4Net31,
+	}), http.StatusCreated)
+	addrs, _ = s.repo.ListAddresses("r3")
+	for _, a := range addrs {
+		if netip.MustParsePrefix(a.Address).Overlaps(manual) {
+			t.Errorf("el IPAM asignó %s, que ya estaba tomada a mano (%s)", a.Address, manual)
+		}
+	}
+}
+
+func TestDeleteLinkStalePID(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	r2 := createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: r1.ID, TargetID: r2.ID, SourceInt: "eth1", TargetInt: "eth1",
+	}), http.StatusCreated)
+
+	// El PID guardado quedó viejo: el link se borra en el namespace actual
+	stale, _ := s.repo.GetNode(r1.ID)
+	stale.PID = r2.PID + 100
+	if err := s.repo.SaveNode(stale); err != nil {
+		t.Fatal(err)
+	}
+	if w := request(t, s, "DELETE", "/links/l1", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := rt.Kernel.LinkByName(r1.PID, "eth1"); !errors.Is(err, orchestrator.ErrLinkNotFound) {
+		t.Errorf("r1:eth1 debería haber desaparecido: %v", err)
+	}
+}
+
+func TestImpairmentStalePID(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	r2 := createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: r1.ID, TargetID: r2.ID, SourceInt: "eth1", TargetInt: "eth1",
+	}), http.StatusCreated)
+
+	// Con un PID guardado viejo, tc tiene que ir al namespace actual
+	stale, _ := s.repo.GetNode(r1.ID)
+	stale.PID = r2.PID + 100
+	if err := s.repo.SaveNode(stale); err != nil {
+		t.Fatal(err)
+	}
+	if w := request(t, s, "PATCH", "/links/l1/impairment", models.Impairment{Delay: 15}); w.Code != http.StatusOK {
+		t.Fatalf("se esperaba 200, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if imp, _ := rt.Kernel.Impairment(r1.PID, "eth1"); imp.Delay != 15 {
+		t.Errorf("el impairment debería aplicarse en r1:eth1: %+v", imp)
+	}
+}
+
+func TestCreateLinkKernelError(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	rt.Kernel.Fail("AddVeth", syscall.ENOMEM)
+
+	w := request(t, s, "POST", "/links", models.Link{ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1"})
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("se esperaba 500, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if links := decodeBody[[]models.Link](t, request(t, s, "GET", "/links", nil), http.StatusOK); len(links) != 0 {
+		t.Errorf("un link fallido no debería guardarse: %+v", links)
+	}
+	if ok, _ := orchestrator.NewNetworkManagerWithKernel(rt.Kernel).InterfaceExists(r1.PID, "eth1"); ok {
+		t.Errorf("no debería quedar eth1 en r1")
+	}
+}
+
+func TestCreateLinkAddressError(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	rt.Kernel.Fail("AddrReplace", syscall.EINVAL)
+
+	link := models.Link{ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1", IPAM: models.IPAMv4Net30}
+	if w := request(t, s, "POST", "/links", link); w.Code != http.StatusInternalServerError {
+		t.Fatalf("se esperaba 500, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if _, found := s.repo.GetLink("l1"); found {
+		t.Error("un link sin direcciones no debería guardarse")
+	}
+	if addrs, _ := s.repo.ListAddresses(r1.ID); len(addrs) != 0 {
+		t.Errorf("las direcciones de IPAM deberían liberarse: %+v", addrs)
+	}
+	if ok, _ := orchestrator.NewNetworkManagerWithKernel(rt.Kernel).InterfaceExists(r1.PID, "eth1"); ok {
+		t.Errorf("no debería quedar eth1 en r1")
+	}
+
+	// Reintentar funciona
+	rt.Kernel.Fail("AddrReplace", nil)
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", link), http.StatusCreated)
+}
+
+func TestSwitchLinkImpairmentError(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	sw := decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{ID: "sw1", Name: "sw1", Type: models.SWITCH}), http.StatusCreated)
+	rt.Kernel.Fail("SetImpairment", syscall.EINVAL)
+
+	w := request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: r1.ID, TargetID: sw.ID, SourceInt: "eth1", TargetInt: "p1",
+		Impairment: models.Impairment{Delay: 10},
+	})
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("se esperaba 500, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if ok, _ := orchestrator.NewNetworkManagerWithKernel(rt.Kernel).InterfaceExists(r1.PID, "eth1"); ok {
+		t.Errorf("no debería quedar eth1 en r1")
+	}
+	port := orchestrator.BridgePortName(orchestrator.BridgeName(sw), r1.PID, "eth1")
+	if _, err := rt.Kernel.LinkByName(orchestrator.HostPID, port); !errors.Is(err, orchestrator.ErrLinkNotFound) {
+		t.Errorf("no debería quedar el puerto %s en el bridge: %v", port, err)
+	}
+}
+
+func TestKernelCallsOnStoppedNode(t *testing.T) {
+	s, rt := newTestServer(t)
+	createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1",
+	}), http.StatusCreated)
+
+	if w := request(t, s, "POST", "/nodes/r1/stop", nil); w.Code != http.StatusOK {
+		t.Fatalf("stop falló: %d %s", w.Code, w.Body.String())
+	}
+
+	// Un nodo parado tiene PID 0: nada debe caer en el namespace del host
+	for _, call := range []struct {
+		method, path string
+		body         any
+	}{
+		{"POST", "/nodes/r1/interfaces/eth1/addresses", gin.H{"address": "10.0.0.1/30"}},
+		{"POST", "/nodes/r1/loopback", nil},
+		{"PATCH", "/links/l1/impairment", models.Impairment{Delay: 10}},
+	} {
+		if w := request(t, s, call.method, call.path, call.body); w.Code != http.StatusConflict {
+			t.Errorf("%s %s: se esperaba 409, se obtuvo %d: %s", call.method, call.path, w.Code, w.Body.String())
+		}
+	}
+	if cidrs, _ := rt.Kernel.AddrList(orchestrator.HostPID, "lo"); len(cidrs) != 0 {
+		t.Errorf("el lo del host no debería tener direcciones: %v", cidrs)
+	}
+}
+
+func TestNodeIDsDoNotCrossLabs(t *testing.T) {
+	s, _ := newTestServer(t)
+	a := createRouter(t, s, "r1") // Laboratorio default
+	decodeBody[models.Topology](t, request(t, s, "POST", "/topologies", models.Topology{ID: "lab-b"}), http.StatusCreated)
+
+	// El mismo ID en otro laboratorio no puede pisar al nodo del primero
+	w := request(t, s, "POST", "/nodes", models.Node{ID: "r1", Name: "r1", TopologyID: "lab-b", Type: models.HOST, Image: "alpine"})
+	if w.Code != http.StatusConflict {
+		t.Errorf("se esperaba 409, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	lab := models.Topology{ID: "lab-b", Nodes: []models.Node{{ID: "r1", Name: "r1", Type: models.HOST, Image: "alpine"}}}
+	if w := request(t, s, "POST", "/topology/deploy", lab); w.Code != http.StatusMultiStatus {
+		t.Errorf("se esperaba 207, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(t, s, "POST", "/topologies/lab-b/apply", lab); w.Code != http.StatusConflict {
+		t.Errorf("se esperaba 409, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+
+	// Con IDs propios, los dos laboratorios tienen su r1
+	lab.Nodes[0].ID = "lab-b-r1"
+	if w := request(t, s, "POST", "/topology/deploy", lab); w.Code != http.StatusOK {
+		t.Fatalf("se esperaba 200, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if n, found := s.repo.GetNode("r1"); !found || n.TopologyID != a.TopologyID || n.ContainerID != a.ContainerID {
+		t.Errorf("el r1 del primer laboratorio no debería cambiar: %+v", n)
+	}
+}
+
+func TestReconcileRecreatesSwitchBridge(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	sw := decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{ID: "sw1", Name: "sw1", Type: models.SWITCH}), http.StatusCreated)
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: r1.ID, TargetID: sw.ID, SourceInt: "eth1", TargetInt: "p1",
+	}), http.StatusCreated)
+
+	// Sin el bridge el puerto queda suelto en el host, pero eth1 sigue en r1
+	if err := rt.Kernel.LinkDel(orchestrator.HostPID, orchestrator.BridgeName(sw)); err != nil {
+		t.Fatal(err)
+	}
+
+	report := decodeBody[DeployReport](t, request(t, s, "POST", "/system/reconcile", nil), http.StatusOK)
+	if len(report.Links) != 1 || report.Links[0].Action != "recreate" {
+		t.Errorf("el link del switch debería recrearse: %+v", report.Links)
+	}
+	bridge, err := rt.Kernel.LinkByName(orchestrator.HostPID, orchestrator.BridgeName(sw))
+	if err != nil {
+		t.Fatalf("el bridge debería existir de nuevo: %v", err)
+	}
+	_, port, ok := rt.Kernel.Peer(r1.PID, "eth1")
+	if hostEnd, _ := rt.Kernel.LinkByName(orchestrator.HostPID, port); !ok || hostEnd.MasterIndex != bridge.Index {
+		t.Errorf("r1:eth1 debería volver a ser un puerto de %s", bridge.Name)
+	}
+}
+
+func TestCreateLinkIPAMExhausted(t *testing.T) {
+	s, rt := newTestServer(t)
+	decodeBody[models.Topology](t, request(t, s, "POST", "/topologies", models.Topology{ID: "lab", P2PPoolV4: "10.0.0.0/30"}), http.StatusCreated)
+	for _, name := range []string{"r1", "r2", "r3"} {
+		decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{ID: name, Name: name, TopologyID: "lab", Type: models.ROUTER, Image: "frr"}), http.StatusCreated)
+	}
+
+	// El pool solo tiene una /30: el segundo link no cabe
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1", IPAM: models.IPAMv4Net30,
+	}), http.StatusCreated)
+	w := request(t, s, "POST", "/links", models.Link{ID: "l2", SourceID: "r1", TargetID: "r3", SourceInt: "eth2", TargetInt: "eth1", IPAM: models.IPAMv4Net30})
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("se esperaba 500, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+
+	if _, found := s.repo.GetLink("l2"); found {
+		t.Error("un link sin direcciones no debería guardarse")
+	}
+	r1, _ := s.repo.GetNode("r1")
+	if ok, _ := orchestrator.NewNetworkManagerWithKernel(rt.Kernel).InterfaceExists(r1.PID, "eth2"); ok {
+		t.Error("no debería quedar eth2 en r1")
+	}
+	addrs, _ := s.repo.ListAddresses("r3")
+	if len(addrs) != 0 {
+		t.Errorf("r3 no debería tener direcciones: %+v", addrs)
+	}
+}
+
+func TestTopologyCRUD(t *testing.T) {
+	s, rt := newTestServer(t)
+
+	lab := decodeBody[models.Topology](t, request(t, s, "POST", "/topologies", models.Topology{ID: "lab", Name: "Lab"}), http.StatusCreated)
+	if lab.Nodes == nil || lab.Links == nil {
+		t.Errorf("un laboratorio nuevo debería traer listas vacías: %+v", lab)
+	}
+	if w := request(t, s, "POST", "/topologies", models.Topology{ID: "lab"}); w.Code != http.StatusConflict {
+		t.Errorf("un ID repetido debería dar 409, se obtuvo %d", w.Code)
+	}
+	if w := request(t, s, "POST", "/topologies", models.Topology{ID: "mal id"}); w.Code != http.StatusBadRequest {
+		t.Errorf("un ID inválido debería dar 400, se obtuvo %d", w.Code)
+	}
+
+	updated := decodeBody[models.Topology](t, request(t, s, "PUT", "/topologies/lab",
+		gin.H{"name": "Lab OSPF", "p2p_pool_v4": "10.10.0.0/16"}), http.StatusOK)
+	if updated.Name != "Lab OSPF" || updated.P2PPoolV4 != "10.10.0.0/16" {
+		t.Errorf("actualización inesperada: %+v", updated)
+	}
+	if w := request(t, s, "PUT", "/topologies/lab", gin.H{"p2p_pool_v4": "no-es-un-prefijo"}); w.Code != http.StatusBadRequest {
+		t.Errorf("un pool inválido debería dar 400, se obtuvo %d", w.Code)
+	}
+	if w := request(t, s, "PUT", "/topologies/nada", gin.H{"name": "x"}); w.Code != http.StatusNotFound {
+		t.Errorf("se esperaba 404, se obtuvo %d", w.Code)
+	}
+
+	node := decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{
+		ID: "h1", Name: "h1", TopologyID: "lab", Type: models.HOST, Image: "alpine",
+	}), http.StatusCreated)
+	got := decodeBody[models.Topology](t, request(t, s, "GET", "/topologies/lab", nil), http.StatusOK)
+	if len(got.Nodes) != 1 || got.Nodes[0].ID != "h1" {
+		t.Errorf("el laboratorio debería incluir a h1: %+v", got.Nodes)
+	}
+	if list := decodeBody[[]models.Topology](t, request(t, s, "GET", "/topologies", nil), http.StatusOK); len(list) != 1 {
+		t.Errorf("se esperaba un laboratorio: %+v", list)
+	}
+
+	// Borrar el laboratorio destruye sus contenedores
+	if w := request(t, s, "DELETE", "/topologies/lab", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if _, _, ok := rt.Node(node.ContainerID); ok {
+		t.Error("el contenedor de h1 debería haberse borrado")
+	}
+	if w := request(t, s, "GET", "/topologies/lab", nil); w.Code != http.StatusNotFound {
+		t.Errorf("se esperaba 404, se obtuvo %d", w.Code)
+	}
+}
+
+func TestDeleteTopologyPartialFailure(t *testing.T) {
+	s, rt := newTestServer(t)
+	lab := models.Topology{ID: "lab", Nodes: []models.Node{
+		{ID: "h1", Name: "h1", Type: models.HOST, Image: "alpine"},
+		{ID: "sw1", Name: "sw1", Type: models.SWITCH},
+	}}
+	decodeBody[DeployReport](t, request(t, s, "POST", "/topology/deploy", lab), http.StatusOK)
+
+	// Una terminal grabada abierta en el lab
+	srv := httptest.NewServer(s.router)
+	defer srv.Close()
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/v1/nodes/h1/terminal?shell=sh&record=true", nil)
+	if err != nil {
+		t.Fatalf("error conectando: %v", err)
+	}
+	defer ws.Close()
+
+	// El contenedor no se puede borrar: el switch se borra igual y el lab queda
+	rt.Fail("DeleteNode", errors.New("daemon timeout"))
+	report := decodeBody[DeployReport](t, request(t, s, "DELETE", "/topologies/lab", nil), http.StatusMultiStatus)
+	if len(report.Nodes) != 2 {
+		t.Errorf("se esperaba un resultado por nodo: %+v", report.Nodes)
+	}
+	if _, found := s.repo.GetNode("sw1"); found {
+		t.Error("sw1 debería haberse borrado")
+	}
+	if _, found := s.repo.GetTopology("lab"); !found {
+		t.Error("el lab debería seguir con h1")
+	}
+	recs := decodeBody[[]models.TerminalRecording](t, request(t, s, "GET", "/recordings", nil), http.StatusOK)
+	if len(recs) != 1 || recs[0].EndedAt == nil {
+		t.Errorf("la grabación debería cerrarse antes de borrar el lab: %+v", recs)
+	}
+
+	rt.Fail("DeleteNode", nil)
+	if w := request(t, s, "DELETE", "/topologies/lab", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestDeployWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+
+	lab := models.Topology{
+		ID: "lab",
+		Nodes: []models.Node{
+			{ID: "r1", Name: "r1", Type: models.ROUTER, Image: "frr"},
+			{ID: "r2", Name: "r2", Type: models.ROUTER, Image: "frr"},
+			{ID: "sw1", Name: "sw1", Type: models.SWITCH},
+		},
+		Links: []models.Link{
+			{ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1"},
+			{ID: "l2", SourceID: "r2", TargetID: "sw1", SourceInt: "eth2", TargetInt: "p1"},
+		},
+	}
+	report := decodeBody[DeployReport](t, request(t, s, "POST", "/topology/deploy", lab), http.StatusOK)
+	if len(report.Nodes) != 3 || len(report.Links) != 2 {
+		t.Errorf("reporte inesperado: %+v", report)
+	}
+	r1, _ := s.repo.GetNode("r1")
+	r2, _ := s.repo.GetNode("r2")
+	if pid, _, ok := rt.Kernel.Peer(r1.PID, "eth1"); !ok || pid != r2.PID {
+		t.Errorf("r1:eth1 debería estar conectada a r2")
+	}
+
+	// Un link roto no aborta el resto: 207 con el error en su elemento
+	lab.ID = "lab2"
+	for i := range lab.Nodes {
+		lab.Nodes[i].ID = "lab2-" + lab.Nodes[i].ID
+	}
+	lab.Links = []models.Link{{ID: "lab2-l1", SourceID: "lab2-r1", TargetID: "no-existe", SourceInt: "eth1", TargetInt: "eth1"}}
+	report = decodeBody[DeployReport](t, request(t, s, "POST", "/topology/deploy", lab), http.StatusMultiStatus)
+	if report.Links[0].Status == "ok" {
+		t.Errorf("el link no debería crearse: %+v", report.Links)
+	}
+	for _, res := range report.Nodes {
+		if res.Status != "ok" {
+			t.Errorf("los nodos deberían crearse igual: %+v", res)
+		}
+	}
+}
+
+func TestPlanApplyWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+
+	lab := models.Topology{
+		ID: "lab",
+		Nodes: []models.Node{
+			{ID: "r1", Name: "r1", Type: models.ROUTER, Image: "frr"},
+			{ID: "r2", Name: "r2", Type: models.ROUTER, Image: "frr"},
+		},
+		Links: []models.Link{{ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1"}},
+	}
+	plan := decodeBody[Plan](t, request(t, s, "POST", "/topologies/lab/plan", lab), http.StatusOK)
+	if len(plan.Nodes) != 2 || len(plan.Links) != 1 || plan.Nodes[0].Action != ActionAdd {
+		t.Errorf("plan inesperado: %+v", plan)
+	}
+	if nodes, _ := rt.ListNodes(context.Background()); len(nodes) != 0 {
+		t.Errorf("el plan no debería crear nada: %+v", nodes)
+	}
+
+	decodeBody[gin.H](t, request(t, s, "POST", "/topologies/lab/apply", lab), http.StatusOK)
+	if plan := decodeBody[Plan](t, request(t, s, "POST", "/topologies/lab/plan", lab), http.StatusOK); !plan.Empty() {
+		t.Errorf("después de aplicar el plan debería estar vacío: %+v", plan)
+	}
+
+	// Sacar r2 del lab file lo borra junto con su link
+	lab.Nodes, lab.Links = lab.Nodes[:1], nil
+	decodeBody[gin.H](t, request(t, s, "POST", "/topologies/lab/apply", lab), http.StatusOK)
+	if _, found := s.repo.GetNode("r2"); found {
+		t.Error("r2 debería haberse borrado")
+	}
+	if _, found := s.repo.GetLink("l1"); found {
+		t.Error("l1 debería haberse borrado")
+	}
+	r1, _ := s.repo.GetNode("r1")
+	if _, err := rt.Kernel.LinkByName(r1.PID, "eth1"); !errors.Is(err, orchestrator.ErrLinkNotFound) {
+		t.Errorf("r1:eth1 debería haber desaparecido: %v", err)
+	}
+
+	if w := request(t, s, "POST", "/topologies/otro/apply", lab); w.Code != http.StatusBadRequest {
+		t.Errorf("un lab file de otro laboratorio debería dar 400, se obtuvo %d", w.Code)
+	}
+}
+
+// TestApplyPositionKeepsRuntimeState mueve un nodo sin pisar el estado que el watcher guardó después del plan
+func TestApplyPositionKeepsRuntimeState(t *testing.T) {
+	s, _ := newTestServer(t)
+	lab := models.Topology{ID: "lab", Nodes: []models.Node{{ID: "r1", Name: "r1", Type: models.ROUTER, Image: "frr"}}}
+	decodeBody[gin.H](t, request(t, s, "POST", "/topologies/lab/apply", lab), http.StatusOK)
+
+	lab.Nodes[0].X, lab.Nodes[0].Y = 120, 80
+	plan := s.plan(context.Background(), lab)
+	if len(plan.Nodes) != 1 || plan.Nodes[0].Action != ActionUpdate {
+		t.Fatalf("plan inesperado: %+v", plan)
+	}
+
+	node, _ := s.repo.GetNode("r1")
+	node.Status, node.PID = models.StatusPaused, node.PID+1
+	if err := s.repo.SaveNode(node); err != nil {
+		t.Fatal(err)
+	}
+	if report := s.apply(context.Background(), plan); report.Nodes[0].Status != "ok" {
+		t.Fatalf("reporte inesperado: %+v", report.Nodes)
+	}
+	got, _ := s.repo.GetNode("r1")
+	if got.X != 120 || got.Y != 80 || got.Status != models.StatusPaused || got.PID != node.PID {
+		t.Errorf("solo debería cambiar la posición: %+v", got)
+	}
+}
+
+// linkDeleteFailRepo fails to delete link rows
+type linkDeleteFailRepo struct {
+	storage.Repository
+}
+
+func (linkDeleteFailRepo) DeleteLink(string) error {
+	return errors.New("database is locked")
+}
+
+func TestApplyRecreateLinkDeleteError(t *testing.T) {
+	s, rt := newTestServer(t)
+	lab := models.Topology{
+		ID: "lab",
+		Nodes: []models.Node{
+			{ID: "r1", Name: "r1", Type: models.ROUTER, Image: "frr"},
+			{ID: "r2", Name: "r2", Type: models.ROUTER, Image: "frr"},
+		},
+		Links: []models.Link{{ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1"}},
+	}
+	decodeBody[gin.H](t, request(t, s, "POST", "/topologies/lab/apply", lab), http.StatusOK)
+
+	// Si la fila vieja no se puede borrar, el link no se vuelve a crear encima
+	lab.Links[0].TargetInt = "eth2"
+	plan := s.plan(context.Background(), lab)
+	s.repo = linkDeleteFailRepo{s.repo}
+	report := s.apply(context.Background(), plan)
+	if len(report.Links) != 1 || report.Links[0].Status != "error" {
+		t.Fatalf("el link debería fallar: %+v", report.Links)
+	}
+	r2, _ := s.repo.GetNode("r2")
+	if _, err := rt.Kernel.LinkByName(r2.PID, "eth2"); !errors.Is(err, orchestrator.ErrLinkNotFound) {
+		t.Errorf("r2:eth2 no debería crearse: %v", err)
+	}
+}
+
+func TestApplyRejectsInvalidLabFile(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	lab := models.Topology{ID: "lab", Nodes: []models.Node{{ID: "r1", Name: "r1", Type: models.ROUTER, Image: "frr"}}}
+	decodeBody[gin.H](t, request(t, s, "POST", "/topologies/lab/apply", lab), http.StatusOK)
+	r1, _ := s.repo.GetNode("r1")
+
+	// Un recreate inválido no puede borrar el nodo que funciona
+	bad := map[string]func(n *models.Node){
+		"startup":  func(n *models.Node) { n.Startup.Files = map[string]string{"relativa": "x"} },
+		"runtime":  func(n *models.Node) { n.Runtime = "lxc" },
+		"recursos": func(n *models.Node) { n.CPURequest = "mucho" },
+		"nombre":   func(n *models.Node) { n.Name = "r_1" },
+	}
+	for name, change := range bad {
+		l := lab
+		l.Nodes = []models.Node{lab.Nodes[0]}
+		l.Nodes[0].Image = "frr:9"
+		change(&l.Nodes[0])
+		if w := request(t, s, "POST", "/topologies/lab/apply", l); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: se esperaba 400, se obtuvo %d: %s", name, w.Code, w.Body.String())
+		}
+		if got, _ := s.repo.GetNode("r1"); got.ContainerID != r1.ContainerID || got.Image != "frr" {
+			t.Errorf("%s: r1 no debería cambiar: %+v", name, got)
+		}
+	}
+
+	// Un presupuesto que no alcanza no se guarda
+	over := lab
+	over.CPUBudget = "1"
+	if w := request(t, s, "POST", "/topologies/lab/apply", over); w.Code != http.StatusBadRequest {
+		t.Errorf("se esperaba 400, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if topo, _ := s.repo.GetTopology("lab"); topo.CPUBudget != "" {
+		t.Errorf("un apply rechazado no debería guardar el presupuesto: %+v", topo)
+	}
+}
+
+func TestContainerEventUsesStoredNode(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+	r1 := createRouter(t, s, "r1")
+
+	// El contenedor viejo de un nodo ya recreado muere después: no toca al nodo
+	s.handleContainerEvent(ctx, orchestrator.ContainerEvent{ContainerID: "viejo", NodeName: "r1", TopologyID: r1.TopologyID, Action: "die"})
+	if got, _ := s.repo.GetNode(r1.ID); got.Status != models.StatusRunning || got.PID != r1.PID {
+		t.Errorf("un evento de otro contenedor no debería cambiar el nodo: %+v", got)
+	}
+
+	// El del contenedor actual sí lo detiene
+	s.handleContainerEvent(ctx, orchestrator.ContainerEvent{ContainerID: r1.ContainerID, Action: "die"})
+	got, _ := s.repo.GetNode(r1.ID)
+	if got.Status != models.StatusStopped || got.PID != 0 || got.ContainerID != r1.ContainerID {
+		t.Errorf("el nodo debería quedar detenido: %+v", got)
+	}
+}
+
+func TestReconcileWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+	ctx := context.Background()
+	r1 := createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1", IPAM: models.IPAMv4Net31,
+	}), http.StatusCreated)
+
+	// Docker para el contenedor sin pasar por la API
+	if err := rt.StopNode(ctx, r1.ContainerID); err != nil {
+		t.Fatal(err)
+	}
+	report := decodeBody[DeployReport](t, request(t, s, "POST", "/system/reconcile", nil), http.StatusMultiStatus)
+	if stored, _ := s.repo.GetNode("r1"); stored.Status != models.StatusStopped || stored.PID != 0 {
+		t.Errorf("r1 debería figurar parado: %+v", stored)
+	}
+	if report.Links[0].Status != "skipped" {
+		t.Errorf("el link debería saltarse: %+v", report.Links)
+	}
+
+	// Al volver tiene otro namespace: el link y sus direcciones se recrean
+	if err := rt.StartNode(ctx, r1.ContainerID); err != nil {
+		t.Fatal(err)
+	}
+	report = decodeBody[DeployReport](t, request(t, s, "POST", "/system/reconcile?topology="+r1.TopologyID, nil), http.StatusOK)
+	if report.Links[0].Action != "recreate" {
+		t.Errorf("el link debería recrearse: %+v", report.Links)
+	}
+	stored, _ := s.repo.GetNode("r1")
+	if stored.PID == r1.PID {
+		t.Errorf("r1 debería tener el PID nuevo")
+	}
+	if cidrs, _ := rt.Kernel.AddrList(stored.PID, "eth1"); len(cidrs) != 1 {
+		t.Errorf("eth1 debería recuperar su dirección: %v", cidrs)
+	}
+}
+
+func TestReconcilePausedNode(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1",
+	}), http.StatusCreated)
+	decodeBody[models.Node](t, request(t, s, "POST", "/nodes/"+r1.ID+"/pause", nil), http.StatusOK)
+
+	// Pausado el contenedor sigue "corriendo" para Docker: el nodo no debe despausarse
+	report := decodeBody[DeployReport](t, request(t, s, "POST", "/system/reconcile", nil), http.StatusOK)
+	if stored, _ := s.repo.GetNode(r1.ID); stored.Status != models.StatusPaused || stored.PID != r1.PID {
+		t.Errorf("r1 debería seguir pausado: %+v", stored)
+	}
+	if report.Links[0].Status != "ok" || report.Links[0].Action != "verify" {
+		t.Errorf("el link de un nodo pausado sigue intacto: %+v", report.Links)
+	}
+
+	// Su namespace sigue ahí: un link roto se vuelve a cablear
+	if err := orchestrator.NewNetworkManagerWithKernel(rt.Kernel).DeleteLink(r1.PID, "eth1"); err != nil {
+		t.Fatal(err)
+	}
+	report = decodeBody[DeployReport](t, request(t, s, "POST", "/system/reconcile", nil), http.StatusOK)
+	if report.Links[0].Status != "ok" || report.Links[0].Action != "recreate" {
+		t.Errorf("el link debería recrearse: %+v", report.Links)
+	}
+}
+
+func TestRestartNextToPausedPeer(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1",
+	}), http.StatusCreated)
+	decodeBody[models.Node](t, request(t, s, "POST", "/nodes/"+r1.ID+"/pause", nil), http.StatusOK)
+
+	// r2 vuelve con otro namespace: el link hacia el par pausado se recablea solo
+	r2 := decodeBody[models.Node](t, request(t, s, "POST", "/nodes/r2/restart", nil), http.StatusOK)
+	if pid, name, ok := rt.Kernel.Peer(r1.PID, "eth1"); !ok || pid != r2.PID || name != "eth1" {
+		t.Errorf("r1:eth1 debería volver a estar conectada a r2:eth1, se obtuvo pid %d %q", pid, name)
+	}
+}
+
+// staleReadRepo returns an old copy of a node on the first read, as if the watcher
+// refreshed it right after the handler loaded it
+type staleReadRepo struct {
+	storage.Repository
+	stale *models.Node
+}
+
+func (r *staleReadRepo) GetNode(id string) (models.Node, bool) {
+	if r.stale != nil && r.stale.ID == id {
+		node := *r.stale
+		r.stale = nil
+		return node, true
+	}
+	return r.Repository.GetNode(id)
+}
+
+func TestStartupConfigKeepsRuntimeState(t *testing.T) {
+	s, _ := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+
+	old := r1
+	old.PID, old.Status = 0, models.StatusStopped
+	s.repo = &staleReadRepo{Repository: s.repo, stale: &old}
+	cfg := models.StartupConfig{FRRConf: "hostname r1-nuevo\n"}
+	if w := request(t, s, "PUT", "/nodes/r1/startup", cfg); w.Code != http.StatusOK {
+		t.Fatalf("se esperaba 200, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	got, _ := s.repo.GetNode("r1")
+	if got.Startup.FRRConf != cfg.FRRConf || got.PID != r1.PID || got.Status != models.StatusRunning {
+		t.Errorf("solo debería cambiar el startup: %+v", got)
+	}
+}
+
+func TestDeleteNodeCascades(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	r2 := createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1", IPAM: models.IPAMv4Net30,
+	}), http.StatusCreated)
+
+	if w := request(t, s, "DELETE", "/nodes/r2", nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if _, _, ok := rt.Node(r2.ContainerID); ok {
+		t.Error("el contenedor de r2 debería haberse borrado")
+	}
+	if links := decodeBody[[]models.Link](t, request(t, s, "GET", "/links", nil), http.StatusOK); len(links) != 0 {
+		t.Errorf("el link de r2 debería haberse borrado: %+v", links)
+	}
+	if addrs, _ := s.repo.ListAddresses("r1"); len(addrs) != 0 {
+		t.Errorf("las direcciones del link deberían liberarse: %+v", addrs)
+	}
+	if _, err := rt.Kernel.LinkByName(r1.PID, "eth1"); !errors.Is(err, orchestrator.ErrLinkNotFound) {
+		t.Errorf("r1:eth1 debería haber desaparecido: %v", err)
+	}
+	if w := request(t, s, "DELETE", "/nodes/r2", nil); w.Code != http.StatusNotFound {
+		t.Errorf("se esperaba 404, se obtuvo %d", w.Code)
+	}
+}
+
+func TestIPAMWithFakeRuntime(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	r2 := createRouter(t, s, "r2")
+	decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{ID: "h1", Name: "h1", Type: models.HOST, Image: "alpine"}), http.StatusCreated)
+
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1", IPAM: models.IPAMv4Net31,
+	}), http.StatusCreated)
+	a, _ := rt.Kernel.AddrList(r1.PID, "eth1")
+	b, _ := rt.Kernel.AddrList(r2.PID, "eth1")
+	if len(a) != 1 || len(b) != 1 || !strings.HasSuffix(a[0], "/31") || a[0] == b[0] {
+		t.Errorf("cada extremo debería tener su /31: %v %v", a, b)
+	}
+
+	lo := decodeBody[models.InterfaceAddress](t, request(t, s, "POST", "/nodes/r1/loopback", nil), http.StatusCreated)
+	if cidrs, _ := rt.Kernel.AddrList(r1.PID, "lo"); len(cidrs) != 1 || cidrs[0] != lo.Address {
+		t.Errorf("lo debería tener %s: %v", lo.Address, cidrs)
+	}
+	if again := decodeBody[models.InterfaceAddress](t, request(t, s, "POST", "/nodes/r1/loopback", nil), http.StatusOK); again.Address != lo.Address {
+		t.Errorf("pedir dos veces el loopback debería devolver el mismo: %s", again.Address)
+	}
+	if w := request(t, s, "POST", "/nodes/h1/loopback", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("un host no tiene loopback de IPAM, se obtuvo %d", w.Code)
+	}
+	if w := request(t, s, "POST", "/links", models.Link{ID: "l2", SourceID: "r1", TargetID: "h1", SourceInt: "eth2", TargetInt: "eth1", IPAM: "ipv4/29"}); w.Code != http.StatusBadRequest {
+		t.Errorf("un modo de IPAM inválido debería dar 400, se obtuvo %d", w.Code)
+	}
+}
+
+func TestCapturesWithFakeRuntime(t *testing.T) {
+	s, _ := newTestServer(t)
+	createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1",
+	}), http.StatusCreated)
+
+	for _, tt := range []struct {
+		body   gin.H
+		status int
+	}{
+		{gin.H{"name": "c1"}, http.StatusBadRequest},
+		{gin.H{"name": "c1", "max_packets": 10, "targets": []gin.H{{"link_id": "nada"}}}, http.StatusNotFound},
+		{gin.H{"name": "c1", "max_packets": 10, "targets": []gin.H{{"link_id": "l1"}, {"link_id": "l1", "end": "source"}}}, http.StatusBadRequest},
+		{gin.H{"name": "c1", "max_packets": 10, "targets": []gin.H{{"link_id": "l1", "end": "medio"}}}, http.StatusBadRequest},
+		{gin.H{"name": "c1", "max_packets": 10, "filter": "port", "targets": []gin.H{{"link_id": "l1"}}}, http.StatusBadRequest},
+		{gin.H{"name": "c1", "targets": []gin.H{{"link_id": "l1"}}}, http.StatusBadRequest}, // Sin límite
+	} {
+		if w := request(t, s, "POST", "/captures", tt.body); w.Code != tt.status {
+			t.Errorf("%v: se esperaba %d, se obtuvo %d: %s", tt.body, tt.status, w.Code, w.Body.String())
+		}
+	}
+
+	// Una sesión terminada con un paquete ICMP en disco
+	cs, err := s.repo.AddCapture(models.CaptureSession{
+		TopologyID: models.DefaultTopologyID, Name: "c1", Snaplen: 262144, Status: models.CaptureStopped,
+		Targets: []models.CaptureTarget{{LinkID: "l1", End: "source", NodeName: "r1", Interface: "eth1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cs.Dir = filepath.Join(s.capturesDir, strconv.Itoa(int(cs.ID)))
+	if err := s.repo.SaveCapture(cs); err != nil {
+		t.Fatal(err)
+	}
+	ring, err := capture.NewRing(cs.Dir, "l1-source", "r1:eth1", 262144, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := append(make([]byte, 12), 0x08, 0x00,
+		0x45, 0, 0, 28, 0, 0, 0, 0, 64, 1, 0, 0, 10, 0, 0, 1, 10, 0, 0, 2, // IPv4, ICMP
+		8, 0, 0, 0, 0, 0, 0, 0) // Echo request
+	if err := ring.WritePacket(time.Now(), frame, len(frame), capture.DirOutbound); err != nil {
+		t.Fatal(err)
+	}
+	ring.Close()
+
+	base := "/captures/" + strconv.Itoa(int(cs.ID))
+	if list := decodeBody[[]models.CaptureSession](t, request(t, s, "GET", "/captures?topology="+models.DefaultTopologyID, nil), http.StatusOK); len(list) != 1 {
+		t.Errorf("se esperaba una captura: %+v", list)
+	}
+	files := decodeBody[[]captureFile](t, request(t, s, "GET", base+"/files", nil), http.StatusOK)
+	if len(files) != 1 || files[0].Source != "l1-source" {
+		t.Fatalf("archivos inesperados: %+v", files)
+	}
+	if w := request(t, s, "GET", base+"/files/"+files[0].Name, nil); w.Code != http.StatusOK || w.Body.Len() != int(files[0].Size) {
+		t.Errorf("descarga inesperada: %d (%d bytes)", w.Code, w.Body.Len())
+	}
+	if w := request(t, s, "GET", base+"/files/..%2Fx.pcapng", nil); w.Code != http.StatusBadRequest && w.Code != http.StatusNotFound {
+		t.Errorf("un nombre con ruta debería rechazarse, se obtuvo %d", w.Code)
+	}
+	summary := decodeBody[struct {
+		Total   int                  `json:"total"`
+		Packets []capture.PacketInfo `json:"packets"`
+	}](t, request(t, s, "GET", base+"/summary", nil), http.StatusOK)
+	if summary.Total != 1 || len(summary.Packets) != 1 {
+		t.Errorf("resumen inesperado: %+v", summary)
+	}
+
+	if w := request(t, s, "POST", base+"/stop", nil); w.Code != http.StatusConflict {
+		t.Errorf("parar una captura terminada debería dar 409, se obtuvo %d", w.Code)
+	}
+	if w := request(t, s, "DELETE", base, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(cs.Dir); !os.IsNotExist(err) {
+		t.Errorf("los archivos deberían haberse borrado: %v", err)
+	}
+	if w := request(t, s, "GET", base, nil); w.Code != http.StatusNotFound {
+		t.Errorf("se esperaba 404, se obtuvo %d", w.Code)
+	}
+}
+
+// TestLiveCaptureWithFakeKernel graba paquetes inyectados en el kernel falso
+func TestLiveCaptureWithFakeKernel(t *testing.T) {
+	s, rt := newTestServer(t)
+	createRouter(t, s, "r1")
+	r2 := createRouter(t, s, "r2")
+	decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1",
+	}), http.StatusCreated)
+
+	waitCapture := func(id uint) models.CaptureSession {
+		t.Helper()
+		var cs models.CaptureSession
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+			cs = decodeBody[models.CaptureSession](t, request(t, s, "GET", "/captures/"+strconv.Itoa(int(id)), nil), http.StatusOK)
+			if cs.Status != models.CaptureRunning {
+				break
+			}
+		}
+		return cs
+	}
+
+	cs := decodeBody[models.CaptureSession](t, request(t, s, "POST", "/captures", gin.H{
+		"name": "c1", "max_packets": 1, "targets": []gin.H{{"link_id": "l1", "end": "source"}},
+	}), http.StatusCreated)
+	frame := append(make([]byte, 12), 0x08, 0x00,
+		0x45, 0, 0, 28, 0, 0, 0, 0, 64, 1, 0, 0, 10, 0, 0, 2, 10, 0, 0, 1, // IPv4, ICMP
+		0, 0, 0, 0, 0, 0, 0, 0) // Echo reply
+	if err := rt.Kernel.InjectPacket(r2.PID, "eth1", frame); err != nil {
+		t.Fatal(err)
+	}
+	if cs = waitCapture(cs.ID); cs.Status != models.CaptureStopped || cs.Targets[0].Packets != 1 || cs.Targets[0].Bytes != uint64(len(frame)) {
+		t.Errorf("la captura debería terminar con un paquete: %+v", cs)
+	}
+
+	// Borrar el link corta la captura con error
+	cs = decodeBody[models.CaptureSession](t, request(t, s, "POST", "/captures", gin.H{
+		"name": "c2", "duration_sec": 60, "targets": []gin.H{{"link_id": "l1", "end": "source"}},
+	}), http.StatusCreated)
+	if w := request(t, s, "DELETE", "/links/l1", nil); w.Code != http.StatusNoContent && w.Code != http.StatusOK {
+		t.Fatalf("error borrando el link: %d %s", w.Code, w.Body.String())
+	}
+	if cs = waitCapture(cs.ID); cs.Status != models.CaptureFailed || cs.Targets[0].Error == "" {
+		t.Errorf("la captura debería fallar al desaparecer la interfaz: %+v", cs)
+	}
+}
+
+func TestRecordingRejectsUnsafePaths(t *testing.T) {
+	s, _ := newTestServer(t)
+	for _, node := range []models.Node{
+		{ID: "..", Name: "r1", TopologyID: models.DefaultTopologyID},
+		{ID: "a/../../b", Name: "r1", TopologyID: models.DefaultTopologyID},
+		{ID: "r1", Name: "r1", TopologyID: ".."},
+		{ID: "r1", Name: "r1"},
+	} {
+		if rec, err := s.startRecording(node, "ana", "sh", 80, 24); err == nil {
+			s.finishRecording(rec)
+			t.Errorf("%q/%q no debería poder grabarse", node.TopologyID, node.ID)
+		}
+	}
+	if recs, _ := s.repo.ListRecordings("", ""); len(recs) != 0 {
+		t.Errorf("no debería quedar ninguna grabación: %+v", recs)
+	}
+}
+
+func TestRecordingsWithFakeRuntime(t *testing.T) {
+	s, _ := newTestServer(t)
+	node := createRouter(t, s, "r1")
+
+	srv := httptest.NewServer(s.router)
+	defer srv.Close()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/v1/nodes/" + node.ID + "/terminal?record=true&user=ana"
+	ws, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("error conectando: %v", err)
+	}
+	_ = ws.WriteMessage(websocket.BinaryMessage, []byte("show ip route\r"))
+	_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for got := ""; !strings.Contains(got, "show ip route"); {
+		_, msg, err := ws.ReadMessage()
+		if err != nil {
+			t.Fatalf("error leyendo: %v", err)
+		}
+		got += string(msg)
+	}
+	ws.Close()
+
+	// La grabación se cierra cuando se va el último cliente
+	var rec models.TerminalRecording
+	for deadline := time.Now().Add(2 * time.Second); rec.EndedAt == nil && time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		recs := decodeBody[[]models.TerminalRecording](t, request(t, s, "GET", "/recordings?user=ana", nil), http.StatusOK)
+		if len(recs) == 1 {
+			rec = recs[0]
+		}
+	}
+	if rec.EndedAt == nil || rec.NodeID != node.ID || rec.Shell != "vtysh" {
+		t.Fatalf("grabación inesperada: %+v", rec)
+	}
+	if recs := decodeBody[[]models.TerminalRecording](t, request(t, s, "GET", "/recordings?user=otro", nil), http.StatusOK); len(recs) != 0 {
+		t.Errorf("el filtro por usuario no debería devolver nada: %+v", recs)
+	}
+
+	base := "/recordings/" + strconv.Itoa(int(rec.ID))
+	decodeBody[models.TerminalRecording](t, request(t, s, "GET", base, nil), http.StatusOK)
+	stored, _ := s.repo.GetRecording(rec.ID)
+	if want := filepath.Join(s.recordingsDir, node.TopologyID, node.ID, strconv.Itoa(int(rec.ID))+".cast"); stored.File != want {
+		t.Errorf("la grabación debería estar en %s, está en %s", want, stored.File)
+	}
+	if w := request(t, s, "GET", base+"/download", nil); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "show ip route") {
+		t.Errorf("el .cast debería tener la salida: %d %q", w.Code, w.Body.String())
+	}
+	if w := request(t, s, "DELETE", base, nil); w.Code != http.StatusNoContent {
+		t.Fatalf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+	if w := request(t, s, "GET", base, nil); w.Code != http.StatusNotFound {
+		t.Errorf("se esperaba 404, se obtuvo %d", w.Code)
+	}
+}
+
+func TestRecoverRecordings(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	// Una grabación que quedó abierta cuando el servidor se cayó
+	rec, err := s.repo.AddRecording(models.TerminalRecording{NodeID: "r1", NodeName: "r1", User: "ana", StartedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec.File = filepath.Join(t.TempDir(), "1.cast")
+	cast := `{"version": 2, "width": 80, "height": 24}` + "\n" + `[0.5, "o", "hola"]` + "\n" + `[2.25, "o", "chau"]` + "\n" + `[3.0, "o", "co`
+	if err := os.WriteFile(rec.File, []byte(cast), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.repo.SaveRecording(rec); err != nil {
+		t.Fatal(err)
+	}
+
+	s.recoverRecordings()
+
+	base := "/recordings/" + strconv.Itoa(int(rec.ID))
+	got := decodeBody[models.TerminalRecording](t, request(t, s, "GET", base, nil), http.StatusOK)
+	if got.EndedAt == nil || got.DurationSec != 2.25 || got.Size != int64(len(cast)) {
+		t.Errorf("la grabación debería quedar cerrada: %+v", got)
+	}
+	if w := request(t, s, "DELETE", base, nil); w.Code != http.StatusNoContent {
+		t.Errorf("se esperaba 204, se obtuvo %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestCaptureSwitchEnd(t *testing.T) {
+	s, rt := newTestServer(t)
+	r1 := createRouter(t, s, "r1")
+	sw := decodeBody[models.Node](t, request(t, s, "POST", "/nodes", models.Node{ID: "sw1", Name: "sw1", Type: models.SWITCH}), http.StatusCreated)
+	link := decodeBody[models.Link](t, request(t, s, "POST", "/links", models.Link{
+		ID: "l1", SourceID: r1.ID, TargetID: sw.ID, SourceInt: "eth1", TargetInt: "p1",
+	}), http.StatusCreated)
+
+	// El extremo del switch se captura en su puerto del bridge, en el host
+	src, status, err := s.captureEnd(link, "target")
+	if err != nil || status != http.StatusOK || src.pid != orchestrator.HostPID {
+		t.Fatalf("extremo inesperado: %+v %d %v", src, status, err)
+	}
+	port, err := rt.Kernel.LinkByName(orchestrator.HostPID, src.iface)
+	bridge, _ := rt.Kernel.LinkByName(orchestrator.HostPID, orchestrator.BridgeName(sw))
+	if err != nil || port.MasterIndex != bridge.Index {
+		t.Errorf("%s debería ser un puerto de %s: %v", src.iface, bridge.Name, err)
+	}
+
+	if w := request(t, s, "POST", "/nodes/r1/stop", nil); w.Code != http.StatusOK {
+		t.Fatalf("stop falló: %d %s", w.Code, w.Body.String())
+	}
+	if _, status, _ := s.captureEnd(link, "target"); status != http.StatusConflict {
+		t.Errorf("sin el nodo del otro lado el puerto no existe, se esperaba 409: %d", status)
+	}
+}
+
+func TestImpairmentErrorIsStable(t *testing.T) {
+	s, _ := newTestServer(t)
+	createRouter(t, s, "r1")
+	createRouter(t, s, "r2")
+
+	// Con varios campos fuera de rango el error siempre nombra el primero
+	link := models.Link{ID: "l1", SourceID: "r1", TargetID: "r2", SourceInt: "eth1", TargetInt: "eth1",
+		Impairment: models.Impairment{Loss: 150, Duplicate: -1, Corrupt: 101}}
+	for i := 0; i < 20; i++ {
+		w := request(t, s, "POST", "/links", link)
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "loss_pct") {
+			t.Fatalf("se esperaba el error de loss_pct, se obtuvo %d: %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestBudgetErrorsNameTheField(t *testing.T) {
+	s, _ := newTestServer(t)
+
+	// Cada error nombra el campo JSON que lo causó
+	for _, tt := range []struct {
+		path  string
+		body  any
+		field string
+	}{
+		{"/topologies", models.Topology{ID: "lab", CPUBudget: "mucho"}, "cpu_budget"},
+		{"/topologies", models.Topology{ID: "lab", RAMBudget: "1k"}, "ram_budget"},
+		{"/nodes", models.Node{ID: "h1", Name: "h1", Type: models.HOST, Image: "alpine", CPURequest: "mucho"}, "cpu_request"},
+		{"/nodes", models.Node{ID: "h1", Name: "h1", Type: models.HOST, Image: "alpine", RAMLimit: "1k"}, "ram_limit"},
+	} {
+		w := request(t, s, "POST", tt.path, tt.body)
+		var body struct {
+			Error string `json:"error"`
+		}
+		_ = json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != http.StatusBadRequest || !strings.HasPrefix(body.Error, "invalid "+tt.field+" ") {
+			t.Errorf("%s: se esperaba un error de %s, se obtuvo %d: %s", tt.path, tt.field, w.Code, body.Error)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	scrollbackSize  = 64 * 1024 // Output replayed to late joiners
+	clientQueueSize = 256       // Frames buffered per client before it is dropped as too slow
+)
+
+var (
+	sessionNamePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,64}$`)
+
+	errWriterTaken   = errors.New("session already has a writer, join with mode=read")
+	errSessionClosed = errors.New("session has ended")
+)
+
+// wsFrame is a message queued for one client
+type wsFrame struct {
+	kind int // websocket.BinaryMessage or websocket.TextMessage
+	data []byte
+}
+
+// termClient is a WebSocket attached to a terminal session
+type termClient struct {
+	out      chan wsFrame // Closed by the session when the client is removed
+	readOnly bool
+	reason   string // Close reason sent when out is closed
+}
+
+// termSession is a TTY exec shared by one writer and any number of read-only viewers.
+// Unnamed sessions belong to a single WebSocket and are not registered.
+type termSession struct {
+	name    string
+	node    models.Node
+	shell   string
+	term    *orchestrator.TermSession
+	rec     *activeRecording
+	created time.Time
+	done    chan struct{} // Closed once the exec ended and the recording is finished
+
+	mu         sync.Mutex
+	cols, rows uint
+	scrollback []byte
+	clients    map[*termClient]struct{}
+	writer     *termClient
+	closed     bool
+}
+
+// termSessionInfo is the public view of a shared session
+type termSessionInfo struct {
+	Name        string    `json:"name"`
+	NodeID      string    `json:"node_id"`
+	NodeName    string    `json:"node_name"`
+	Shell       string    `json:"shell"`
+	Writer      bool      `json:"writer"` // A writer is attached
+	Viewers     int       `json:"viewers"`
+	CreatedAt   time.Time `json:"created_at"`
+	RecordingID uint      `json:"recording_id,omitempty"`
+}
+
+func sessionKey(nodeID, name string) string {
+	return nodeID + "/" + name
+}
+
+// attach registers a client and queues the scrollback (and the current size) so a
+// late joiner sees the screen before any new output.
+func (ts *termSession) attach(readOnly bool) (*termClient, error) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	if ts.closed {
+		return nil, errSessionClosed
+	}
+	if !readOnly && ts.writer != nil {
+		return nil, errWriterTaken
+	}
+
+	c := &termClient{out: make(chan wsFrame, clientQueueSize), readOnly: readOnly}
+	if ts.cols > 0 && ts.rows > 0 {
+		c.out <- resizeFrame(ts.cols, ts.rows)
+	}
+	if len(ts.scrollback) > 0 {
+		c.out <- wsFrame{websocket.BinaryMessage, append([]byte(nil), ts.scrollback...)}
+	}
+
+	ts.clients[c] = struct{}{}
+	if !readOnly {
+		ts.writer = c
+	}
+	return c, nil
+}
+
+// detach removes a client and reports whether the session has no clients left
+func (ts *termSession) detach(c *termClient) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.removeClient(c, "")
+	return len(ts.clients) == 0
+}
+
+// removeClient closes the queue of a client once. Requires ts.mu.
+func (ts *termSession) removeClient(c *termClient, reason string) {
+	if _, ok := ts.clients[c]; !ok {
+		return
+	}
+	delete(ts.clients, c)
+	if ts.writer == c {
+		ts.writer = nil
+	}
+	c.reason = reason
+	close(c.out)
+}
+
+// broadcast queues a frame for every client except skip. Clients that cannot keep
+// up are dropped instead of stalling the shared stream.
+func (ts *termSession) broadcast(f wsFrame, skip *termClient) {
+	for c := range ts.clients {
+		if c == skip {
+			continue
+		}
+		select {
+		case c.out <- f:
+		default:
+			ts.removeClient(c, "client too slow")
+		}
+	}
+}
+
+// pump copies the TTY output to every client until the exec ends
+func (ts *termSession) pump(onEnd func()) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := ts.term.Reader.Read(buf)
+		if n > 0 {
+			data := append([]byte(nil), buf[:n]...)
+			if ts.rec != nil {
+				_ = ts.rec.Output(data)
+			}
+
+			ts.mu.Lock()
+			ts.scrollback = appendScrollback(ts.scrollback, data)
+			ts.broadcast(wsFrame{websocket.BinaryMessage, data}, nil)
+			ts.mu.Unlock()
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// The shell exited or the last client left
+	ts.mu.Lock()
+	ts.closed = true
+	for c := range ts.clients {
+		ts.removeClient(c, "session ended")
+	}
+	ts.mu.Unlock()
+	onEnd()
+}
+
+// input forwards keystrokes of the writer; viewers are read-only
+func (ts *termSession) input(c *termClient, data []byte) error {
+	ts.mu.Lock()
+	isWriter := ts.writer == c
+	ts.mu.Unlock()
+	if !isWriter {
+		return nil
+	}
+
+	if ts.rec != nil {
+		_ = ts.rec.Input(data)
+	}
+	_, err := ts.term.Conn.Write(data)
+	return err
+}
+
+// resizeSession applies a resize of the writer and mirrors it to the viewers
+func (s *Server) resizeSession(ts *termSession, c *termClient, cols, rows uint) {
+	ts.mu.Lock()
+	isWriter := ts.writer == c
+	ts.mu.Unlock()
+	if !isWriter || cols == 0 || rows == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.runtime.ResizeTerminal(ctx, ts.term.ExecID, cols, rows); err != nil {
+		log.Printf("Error resizing terminal: %v", err)
+		return
+	}
+	if ts.rec != nil {
+		_ = ts.rec.Resize(cols, rows)
+	}
+
+	ts.mu.Lock()
+	ts.cols, ts.rows = cols, rows
+	ts.broadcast(resizeFrame(cols, rows), c)
+	ts.mu.Unlock()
+}
+
+func (ts *termSession) info() termSessionInfo {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	info := termSessionInfo{
+		Name:      ts.name,
+		NodeID:    ts.node.ID,
+		NodeName:  ts.node.Name,
+		Shell:     ts.shell,
+		Writer:    ts.writer != nil,
+		Viewers:   len(ts.clients),
+		CreatedAt: ts.created,
+	}
+	if ts.writer != nil {
+		info.Viewers--
+	}
+	if ts.rec != nil {
+		info.RecordingID = ts.rec.meta.ID
+	}
+	return info
+}
+
+// resizeFrame is the control message that tells a client the TTY size
+func resizeFrame(cols, rows uint) wsFrame {
+	msg, _ := json.Marshal(termControl{Type: "resize", Cols: cols, Rows: rows})
+	return wsFrame{websocket.TextMessage, msg}
+}
+
+// appendScrollback keeps the last scrollbackSize bytes, cut at a character boundary
+func appendScrollback(buf, data []byte) []byte {
+	buf = append(buf, data...)
+	if len(buf) <= scrollbackSize {
+		return buf
+	}
+	cut := len(buf) - scrollbackSize
+	for cut < len(buf) && !utf8.RuneStart(buf[cut]) {
+		cut++
+	}
+	return append([]byte(nil), buf[cut:]...)
+}
+
+// --- Session registry ---
+
+// termSessionOptions describes the exec of a new session
+type termSessionOptions struct {
+	name       string // "" = private session
+	shell      string
+	cmd        []string
+	cols, rows uint
+	record     bool
+	user       string
+}
+
+// openSession returns the named session of a node, creating it if needed.
+// Viewers (readOnly) can only join existing sessions. The name is reserved while the
+// exec is created, without holding s.sessionsMu: others joining it wait for the outcome.
+func (s *Server) openSession(node models.Node, opts termSessionOptions, readOnly bool) (*termSession, int, error) {
+	if opts.name == "" {
+		if readOnly {
+			return nil, http.StatusNotFound, errors.New("session not found")
+		}
+		return s.createSession(node, opts)
+	}
+
+	key := sessionKey(node.ID, opts.name)
+	for {
+		s.sessionsMu.Lock()
+		if ts, ok := s.sessions[key]; ok {
+			s.sessionsMu.Unlock()
+			return ts, http.StatusOK, nil
+		}
+		if pending, ok := s.sessionsOpening[key]; ok {
+			s.sessionsMu.Unlock()
+			<-pending // Then join it, or take over if it failed
+			continue
+		}
+		if readOnly {
+			s.sessionsMu.Unlock()
+			return nil, http.StatusNotFound, errors.New("session not found")
+		}
+		pending := make(chan struct{})
+		s.sessionsOpening[key] = pending
+		s.sessionsMu.Unlock()
+
+		ts, status, err := s.createSession(node, opts)
+
+		s.sessionsMu.Lock()
+		delete(s.sessionsOpening, key)
+		if err == nil {
+			s.sessions[key] = ts
+		}
+		s.sessionsMu.Unlock()
+		close(pending)
+
+		if err == nil {
+			s.runSession(ts)
+		}
+		return ts, status, err
+	}
+}
+
+// createSession opens the exec of a new session and its optional recording.
+// Named sessions are started with runSession once they are published.
+func (s *Server) createSession(node models.Node, opts termSessionOptions) (*termSession, int, error) {
+	term, err := s.runtime.OpenTerminal(context.Background(), node.ContainerID, opts.cmd, opts.cols, opts.rows)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+
+	ts := &termSession{
+		name:    opts.name,
+		node:    node,
+		shell:   opts.shell,
+		term:    term,
+		created: time.Now(),
+		done:    make(chan struct{}),
+		cols:    opts.cols,
+		rows:    opts.rows,
+		clients: make(map[*termClient]struct{}),
+	}
+
+	// Optional recording, owned by the user who opens the session
+	if opts.record {
+		ts.rec, err = s.startRecording(node, opts.user, opts.shell, opts.cols, opts.rows)
+		if err != nil {
+			term.Close()
+			return nil, http.StatusInternalServerError, err
+		}
+	}
+
+	if opts.name == "" {
+		s.runSession(ts)
+	}
+	return ts, http.StatusCreated, nil
+}
+
+// runSession pumps the exec output until it ends, then unregisters the session
+// and closes its recording
+func (s *Server) runSession(ts *termSession) {
+	s.sessionsMu.Lock()
+	s.liveSessions[ts] = struct{}{}
+	s.sessionsMu.Unlock()
+
+	go func() {
+		defer close(ts.done)
+		ts.pump(func() {
+			s.sessionsMu.Lock()
+			delete(s.liveSessions, ts)
+			if ts.name != "" {
+				key := sessionKey(ts.node.ID, ts.name)
+				if s.sessions[key] == ts {
+					delete(s.sessions, key)
+				}
+			}
+			s.sessionsMu.Unlock()
+			if ts.rec != nil {
+				s.finishRecording(ts.rec)
+			}
+		})
+	}()
+}
+
+// endLabSessions closes every terminal session on the nodes of a lab and waits
+// until their recordings are finished
+func (s *Server) endLabSessions(topologyID string) {
+	s.sessionsMu.Lock()
+	var ending []*termSession
+	for ts := range s.liveSessions {
+		if ts.node.TopologyID == topologyID {
+			ending = append(ending, ts)
+		}
+	}
+	s.sessionsMu.Unlock()
+
+	for _, ts := range ending {
+		ts.term.Close()
+		<-ts.done
+	}
+}
+
+// listTermSessions returns the shared terminal sessions, optionally of one node (?node=)
+func (s *Server) listTermSessions(c *gin.Context) {
+	nodeID := c.Query("node")
+
+	s.sessionsMu.Lock()
+	list := make([]termSessionInfo, 0, len(s.sessions))
+	for _, ts := range s.sessions {
+		if nodeID == "" || ts.node.ID == nodeID {
+			list = append(list, ts.info())
+		}
+	}
+	s.sessionsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	c.JSON(http.StatusOK, list)
+}
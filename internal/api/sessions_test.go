@@ -0,0 +1,129 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func newTestSession() *termSession {
+	return &termSession{name: "demo", clients: make(map[*termClient]struct{})}
+}
+
+// TestSessionLateJoiner verifica que un espectador recibe el tamaño y el scrollback al unirse
+func TestSessionLateJoiner(t *testing.T) {
+	ts := newTestSession()
+	writer, err := ts.attach(false)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if _, err := ts.attach(false); err != errWriterTaken {
+		t.Fatalf("se esperaba errWriterTaken, se obtuvo %v", err)
+	}
+
+	ts.mu.Lock()
+	ts.cols, ts.rows = 120, 40
+	ts.scrollback = appendScrollback(ts.scrollback, []byte("r1# show ip route\r\n"))
+	ts.broadcast(wsFrame{websocket.BinaryMessage, []byte("r1# show ip route\r\n")}, nil)
+	ts.mu.Unlock()
+
+	viewer, err := ts.attach(true)
+	if err != nil {
+		t.Fatalf("error inesperado: %v", err)
+	}
+	if f := <-viewer.out; f.kind != websocket.TextMessage {
+		t.Errorf("el primer frame debería ser el resize, se obtuvo %q", f.data)
+	}
+	if f := <-viewer.out; !bytes.Equal(f.data, []byte("r1# show ip route\r\n")) {
+		t.Errorf("scrollback inesperado: %q", f.data)
+	}
+	if got := ts.info(); !got.Writer || got.Viewers != 1 {
+		t.Errorf("info inesperada: %+v", got)
+	}
+
+	// Cuando el escritor se va, otro puede tomar su lugar
+	if ts.detach(writer) {
+		t.Fatalf("la sesión todavía tiene un espectador")
+	}
+	if _, err := ts.attach(false); err != nil {
+		t.Errorf("se esperaba poder tomar el lugar del escritor: %v", err)
+	}
+}
+
+// TestSessionDropsSlowClient verifica que un cliente lento no frena al resto
+func TestSessionDropsSlowClient(t *testing.T) {
+	ts := newTestSession()
+	slow, _ := ts.attach(true)
+
+	ts.mu.Lock()
+	for i := 0; i <= clientQueueSize; i++ {
+		ts.broadcast(wsFrame{websocket.BinaryMessage, []byte("x")}, nil)
+	}
+	ts.mu.Unlock()
+
+	if _, ok := ts.clients[slow]; ok {
+		t.Fatalf("el cliente lento debería haberse descartado")
+	}
+	if slow.reason == "" {
+		t.Errorf("se esperaba un motivo de cierre")
+	}
+}
+
+func TestAppendScrollback(t *testing.T) {
+	// El recorte cae en el segundo byte de la ñ: se descarta el carácter entero
+	data := append([]byte("ñ"), bytes.Repeat([]byte("a"), scrollbackSize-1)...)
+	buf := appendScrollback(nil, data)
+	if len(buf) != scrollbackSize-1 || buf[0] != 'a' {
+		t.Fatalf("scrollback mal recortado: %d bytes, empieza con %q", len(buf), buf[0])
+	}
+
+	buf = appendScrollback(buf, []byte("fin"))
+	if len(buf) > scrollbackSize || !bytes.HasSuffix(buf, []byte("fin")) {
+		t.Errorf("se perdió el final del scrollback")
+	}
+}
+
+// TestOpenSessionConcurrent verifica que varios clientes que piden la misma sesión a
+// la vez comparten un único exec
+func TestOpenSessionConcurrent(t *testing.T) {
+	s, _ := newTestServer(t)
+	node := createRouter(t, s, "r1")
+	opts := termSessionOptions{name: "compartida", shell: "sh", cmd: []string{"sh"}}
+
+	const clients = 8
+	results := make(chan *termSession, clients)
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ts, status, err := s.openSession(node, opts, false)
+			if err != nil {
+				t.Errorf("error inesperado: %v", err)
+				return
+			}
+			if status == http.StatusCreated {
+				created.Add(1)
+			}
+			results <- ts
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	first := <-results
+	for ts := range results {
+		if ts != first {
+			t.Fatal("todos los clientes deberían compartir la misma sesión")
+		}
+	}
+	if created.Load() != 1 {
+		t.Errorf("se esperaba un solo exec, se crearon %d", created.Load())
+	}
+	first.term.Close()
+}
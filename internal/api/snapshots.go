@@ -0,0 +1,176 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"open-veth/internal/models"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// --- Config Snapshot Handlers ---
+
+// listSnapshots returns the saved configs of a node. Snapshots outlive the node,
+// so the history of a deleted router is still reachable by its ID (and ?topology=).
+func (s *Server) listSnapshots(c *gin.Context) {
+	snaps, err := s.repo.ListSnapshots(s.snapshotTopology(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, snaps)
+}
+
+// saveSnapshot stores the running config of a router as a new version. The startup
+// config is left alone: it belongs to the lab file, and changing it would make the
+// next apply recreate the router. PUT /nodes/:id/startup adopts a snapshot for good.
+func (s *Server) saveSnapshot(c *gin.Context) {
+	node, ok := s.runningRouter(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Note string `json:"note"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	config, err := s.runtime.RunningConfig(c.Request.Context(), node.ContainerID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Saving twice without changes does not create a new version
+	snaps, _ := s.repo.ListSnapshots(node.TopologyID, node.ID)
+	if n := len(snaps); n > 0 && snaps[n-1].Config == config {
+		c.JSON(http.StatusOK, snaps[n-1])
+		return
+	}
+
+	snap, err := s.repo.AddSnapshot(models.ConfigSnapshot{
+		TopologyID: node.TopologyID,
+		NodeID:     node.ID,
+		NodeName:   node.Name,
+		Config:     config,
+		Note:       req.Note,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, snap)
+}
+
+// diffSnapshots compares two snapshots, or a snapshot with the live config (?to=running)
+func (s *Server) diffSnapshots(c *gin.Context) {
+	from, ok := s.snapshotByID(c, c.Query("from"))
+	if !ok {
+		return
+	}
+	fromName := fmt.Sprintf("v%d", from.Version)
+
+	var toName, toConfig string
+	if to := c.DefaultQuery("to", "running"); to == "running" {
+		node, ok := s.runningRouter(c)
+		if !ok {
+			return
+		}
+		config, err := s.runtime.RunningConfig(c.Request.Context(), node.ContainerID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		toName, toConfig = "running", config
+	} else {
+		snap, ok := s.snapshotByID(c, to)
+		if !ok {
+			return
+		}
+		toName, toConfig = fmt.Sprintf("v%d", snap.Version), snap.Config
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"from": fromName,
+		"to":   toName,
+		"diff": unifiedDiff(from.Config, toConfig, fromName, toName),
+	})
+}
+
+// restoreSnapshot loads a snapshot into the running FRR of the node. Like saving,
+// it does not touch the startup config.
+func (s *Server) restoreSnapshot(c *gin.Context) {
+	node, ok := s.runningRouter(c)
+	if !ok {
+		return
+	}
+	snap, ok := s.snapshotByID(c, c.Param("snapId"))
+	if !ok {
+		return
+	}
+
+	if err := s.runtime.RestoreFRRConfig(c.Request.Context(), node.ContainerID, snap.Config); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"node": node, "snapshot": snap, "applied": true})
+}
+
+func (s *Server) deleteSnapshot(c *gin.Context) {
+	snap, ok := s.snapshotByID(c, c.Param("snapId"))
+	if !ok {
+		return
+	}
+	if err := s.repo.DeleteSnapshot(snap.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// runningRouter loads the node of the URL and checks vtysh can be reached.
+// It writes the error response itself.
+func (s *Server) runningRouter(c *gin.Context) (models.Node, bool) {
+	node, ok := s.runningContainer(c)
+	if !ok {
+		return node, false
+	}
+	if node.Type != models.ROUTER {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "config snapshots are only available for routers"})
+		return node, false
+	}
+	return node, true
+}
+
+// snapshotByID parses a snapshot ID and checks it belongs to the node of the URL.
+// It writes the error response itself.
+func (s *Server) snapshotByID(c *gin.Context, raw string) (models.ConfigSnapshot, bool) {
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid snapshot id"})
+		return models.ConfigSnapshot{}, false
+	}
+	snap, found := s.repo.GetSnapshot(uint(id))
+	if !found || snap.NodeID != c.Param("id") || snap.TopologyID != s.snapshotTopology(c) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "snapshot not found"})
+		return snap, false
+	}
+	return snap, true
+}
+
+// snapshotTopology is the lab whose history the URL refers to: the lab of the node,
+// or ?topology= (default lab) once the node is gone
+func (s *Server) snapshotTopology(c *gin.Context) string {
+	if node, found := s.repo.GetNode(c.Param("id")); found {
+		return node.TopologyID
+	}
+	return c.DefaultQuery("topology", models.DefaultTopologyID)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"open-veth/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// updateStartupConfig replaces the startup config of a node and pushes it to the
+// running container (files + FRR reload). Stopped nodes pick it up on redeploy.
+func (s *Server) updateStartupConfig(c *gin.Context) {
+	node, found := s.repo.GetNode(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "node not found"})
+		return
+	}
+
+	var cfg models.StartupConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := cfg.Validate(node.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	node.Startup = cfg
+	if err := node.ValidateRuntime(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	// Only Startup changes: PID and status may have been refreshed meanwhile
+	node, found, err := s.updateStoredNode(node.ID, node.ContainerID, func(n *models.Node) { n.Startup = cfg })
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !found {
+		c.JSON(http.StatusConflict, gin.H{"error": "node was removed or recreated meanwhile"})
+		return
+	}
+
+	applied := false
+	if node.ContainerID != "" && node.Status == models.StatusRunning {
+		if err := s.runtime.ApplyStartupConfig(c.Request.Context(), node.ContainerID, node); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "saved but not applied: " + err.Error()})
+			return
+		}
+		applied = true
+	}
+
+	c.JSON(http.StatusOK, gin.H{"node": node, "applied": applied})
+}
@@ -1,11 +1,14 @@
 package api
 
 import (
-	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"open-veth/internal/models"
+	"strconv"
 
-	"github.com/docker/docker/api/types/container"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 )
@@ -16,81 +19,235 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-// handleTerminal maneja la conexión WebSocket para la terminal
+// termControl es un mensaje de control del cliente (frames de texto).
+// Los frames binarios son la entrada del teclado, tal cual.
+type termControl struct {
+	Type string `json:"type"` // "resize"
+	Cols uint   `json:"cols"`
+	Rows uint   `json:"rows"`
+}
+
+// Shells que se pueden pedir con ?shell=
+var terminalShells = map[string]bool{"vtysh": true, "bash": true, "sh": true}
+
+// bashOrSh abre bash si la imagen lo trae (Alpine no) y si no, sh
+var bashOrSh = []string{"sh", "-c", "if command -v bash >/dev/null 2>&1; then exec bash; else exec sh; fi"}
+
+// handleTerminal maneja la conexión WebSocket para la terminal.
+//
+// Protocolo:
+//   - servidor -> cliente: frames binarios con la salida cruda de la TTY y frames
+//     de texto con el tamaño actual ({"type":"resize",...}) para los espectadores
+//   - cliente -> servidor: frames binarios con la entrada del teclado y frames de
+//     texto con mensajes de control ({"type":"resize","cols":120,"rows":40})
+//
+// Los clientes viejos que mandan el teclado como texto siguen funcionando:
+// un frame de texto que no es un mensaje de control se trata como entrada.
+//
+// Con ?session=<nombre> la terminal se comparte: el primero que entra la crea y
+// escribe, los demás se unen con ?mode=read y reciben el scrollback al conectarse.
 func (s *Server) handleTerminal(c *gin.Context) {
-	nodeName := c.Query("node")
-	if nodeName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "node name is required"})
+	node, status, err := s.terminalNode(c)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 1. Upgrade de HTTP a WebSocket
-	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	shell, cmd, err := terminalCommand(node, c.Query("shell"))
 	if err != nil {
-		log.Printf("Error upgrading to websocket: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-	defer ws.Close()
+	cols, _ := strconv.ParseUint(c.Query("cols"), 10, 16)
+	rows, _ := strconv.ParseUint(c.Query("rows"), 10, 16)
+	record, _ := strconv.ParseBool(c.Query("record"))
 
-	// 2. Preparar el comando a ejecutar
-	// Si es un router, entramos directo a vtysh, si no a bash
-	// (Por ahora simplificamos: si el nombre tiene 'router' usamos vtysh, si no bash)
-	shell := "bash"
-	// Podríamos inspeccionar el nodo para saber su tipo real, pero por ahora:
-	// if strings.Contains(nodeName, "router") { shell = "vtysh" }
-
-	execConfig := container.ExecOptions{
-		AttachStdout: true,
-		AttachStderr: true,
-		AttachStdin:  true,
-		Tty:          true,
-		Cmd:          []string{shell},
+	name := c.Query("session")
+	if name != "" && !sessionNamePattern.MatchString(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid session name"})
+		return
+	}
+	readOnly := false
+	switch c.DefaultQuery("mode", "write") {
+	case "read":
+		readOnly = true
+	case "write":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be read or write"})
+		return
+	}
+	if readOnly && name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode=read requires a session name"})
+		return
 	}
 
-	// 3. Crear el proceso de ejecución en el contenedor
-	ctx := context.Background()
-	execID, err := s.manager.GetDockerClient().ContainerExecCreate(ctx, nodeName, execConfig)
+	// 1. Crear (o encontrar) la sesión antes del upgrade, así los errores son HTTP
+	ts, status, err := s.openSession(node, termSessionOptions{
+		name:   name,
+		shell:  shell,
+		cmd:    cmd,
+		cols:   uint(cols),
+		rows:   uint(rows),
+		record: record,
+		user:   requestUser(c),
+	}, readOnly)
 	if err != nil {
-		log.Printf("Error creating exec: %v", err)
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	// 4. Conectarse al proceso (Hijack)
-	resp, err := s.manager.GetDockerClient().ContainerExecAttach(ctx, execID.ID, container.ExecStartOptions{
-		Tty: true,
-	})
+	client, err := ts.attach(readOnly)
 	if err != nil {
-		log.Printf("Error attaching to exec: %v", err)
+		code := http.StatusConflict
+		if errors.Is(err, errSessionClosed) {
+			code = http.StatusGone
+		}
+		c.JSON(code, gin.H{"error": err.Error()})
 		return
 	}
-	defer resp.Close()
+	defer func() {
+		// La sesión termina cuando se va el último cliente
+		if ts.detach(client) {
+			ts.term.Close()
+		}
+	}()
 
-	// 5. Puentes de datos (Bi-direccional)
+	// 2. Upgrade de HTTP a WebSocket
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Error upgrading to websocket: %v", err)
+		return
+	}
+	defer ws.Close()
 
-	// Canal de salida: Docker -> WebSocket
+	// 3. Salida: sesión -> WebSocket (un solo escritor por conexión). Frames binarios:
+	// un carácter UTF-8 partido entre dos lecturas lo reensambla el cliente.
 	go func() {
-		buf := make([]byte, 1024)
-		for {
-			n, err := resp.Reader.Read(buf)
-			if n > 0 {
-				if err := ws.WriteMessage(websocket.TextMessage, buf[:n]); err != nil {
-					return
+		for f := range client.out {
+			if err := ws.WriteMessage(f.kind, f.data); err != nil {
+				ws.Close()
+				for range client.out {
 				}
-			}
-			if err != nil {
 				return
 			}
 		}
+		// La sesión nos sacó (terminó el shell o el cliente es muy lento)
+		if client.reason != "" {
+			msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, client.reason)
+			_ = ws.WriteMessage(websocket.CloseMessage, msg)
+		}
+		ws.Close()
 	}()
 
-	// Canal de entrada: WebSocket -> Docker
+	// 4. Entrada: WebSocket -> sesión (los espectadores solo pueden cerrar)
 	for {
-		_, msg, err := ws.ReadMessage()
+		messageType, msg, err := ws.ReadMessage()
 		if err != nil {
 			break
 		}
-		if _, err := resp.Conn.Write(msg); err != nil {
+
+		if messageType == websocket.TextMessage {
+			var ctl termControl
+			if json.Unmarshal(msg, &ctl) == nil && ctl.Type != "" {
+				s.handleTermControl(ts, client, ctl)
+				continue
+			}
+		}
+
+		if err := ts.input(client, msg); err != nil {
 			break
 		}
 	}
 }
+
+// handleTermControl aplica un mensaje de control a la sesión
+func (s *Server) handleTermControl(ts *termSession, client *termClient, ctl termControl) {
+	switch ctl.Type {
+	case "resize":
+		s.resizeSession(ts, client, ctl.Cols, ctl.Rows)
+	default:
+		log.Printf("Unknown terminal control message: %q", ctl.Type)
+	}
+}
+
+// terminalNode busca el nodo por ID (/nodes/:id/terminal). La ruta vieja
+// /terminal?node= acepta un ID o, por compatibilidad, el nombre del nodo.
+func (s *Server) terminalNode(c *gin.Context) (models.Node, int, error) {
+	id := c.Param("id")
+	if id == "" {
+		id = c.Query("node")
+	}
+	if id == "" {
+		return models.Node{}, http.StatusBadRequest, fmt.Errorf("node id is required")
+	}
+
+	// El runtime solo conoce el contenedor: ID o nombre se resuelven al nodo guardado,
+	// y ?topology= descarta un ID que sea de otro laboratorio
+	topologyID := c.Query("topology")
+	node, found := s.repo.GetNode(id)
+	if found && topologyID != "" && node.TopologyID != topologyID {
+		found = false
+	}
+	if !found {
+		var err error
+		node, found, err = s.nodeByName(id, topologyID)
+		if err != nil {
+			return node, http.StatusBadRequest, err
+		}
+	}
+	if !found {
+		return node, http.StatusNotFound, fmt.Errorf("node not found")
+	}
+
+	if node.Type == models.SWITCH {
+		return node, http.StatusBadRequest, fmt.Errorf("switch nodes have no terminal")
+	}
+	if node.ContainerID == "" || node.Status != models.StatusRunning {
+		return node, http.StatusConflict, fmt.Errorf("node is %s", node.Status)
+	}
+	return node, http.StatusOK, nil
+}
+
+// nodeByName resuelve un nombre de nodo (opcionalmente dentro de un laboratorio)
+func (s *Server) nodeByName(name, topologyID string) (models.Node, bool, error) {
+	nodes, err := s.repo.ListNodes()
+	if err != nil {
+		return models.Node{}, false, err
+	}
+
+	var match models.Node
+	found := false
+	for _, n := range nodes {
+		if n.Name != name || (topologyID != "" && n.TopologyID != topologyID) {
+			continue
+		}
+		if found {
+			return match, false, fmt.Errorf("node name %s is used in several labs, use the node id", name)
+		}
+		match, found = n, true
+	}
+	return match, found, nil
+}
+
+// terminalCommand elige el shell según el tipo de nodo: vtysh en routers y
+// bash (o sh si la imagen no lo trae) en el resto. Devuelve el shell elegido y el comando.
+// Los nodos netns no tienen FRR propio: vtysh hablaría con el del host.
+func terminalCommand(node models.Node, shell string) (string, []string, error) {
+	namespace := node.RuntimeKind() == models.RuntimeNamespace
+	if shell == "" {
+		shell = "bash"
+		if node.Type == models.ROUTER && !namespace {
+			shell = "vtysh"
+		}
+	}
+	if !terminalShells[shell] {
+		return "", nil, fmt.Errorf("unsupported shell %q", shell)
+	}
+	if shell == "vtysh" && namespace {
+		return "", nil, fmt.Errorf("vtysh is not available on netns nodes")
+	}
+	if shell == "bash" {
+		return shell, bashOrSh, nil
+	}
+	return shell, []string{shell}, nil
+}
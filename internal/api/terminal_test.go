@@ -0,0 +1,34 @@
+package api
+
+import (
+	"open-veth/internal/models"
+	"slices"
+	"testing"
+)
+
+func TestTerminalCommand(t *testing.T) {
+	router := models.Node{Type: models.ROUTER}
+	host := models.Node{Type: models.HOST}
+
+	if _, cmd, _ := terminalCommand(router, ""); !slices.Equal(cmd, []string{"vtysh"}) {
+		t.Errorf("un router debería abrir vtysh, se obtuvo %v", cmd)
+	}
+	if _, cmd, _ := terminalCommand(host, ""); !slices.Equal(cmd, bashOrSh) {
+		t.Errorf("un host debería abrir bash con fallback a sh, se obtuvo %v", cmd)
+	}
+	if _, cmd, _ := terminalCommand(router, "sh"); !slices.Equal(cmd, []string{"sh"}) {
+		t.Errorf("?shell=sh debería respetarse, se obtuvo %v", cmd)
+	}
+	if _, _, err := terminalCommand(host, "python3"); err == nil {
+		t.Errorf("se esperaba error para un shell no soportado")
+	}
+
+	// Un router netns no tiene FRR propio: nada de vtysh del host
+	nsRouter := models.Node{Type: models.ROUTER, Runtime: models.RuntimeNamespace}
+	if _, cmd, _ := terminalCommand(nsRouter, ""); !slices.Equal(cmd, bashOrSh) {
+		t.Errorf("un router netns debería abrir bash con fallback a sh, se obtuvo %v", cmd)
+	}
+	if _, _, err := terminalCommand(nsRouter, "vtysh"); err == nil {
+		t.Errorf("se esperaba error para vtysh en un nodo netns")
+	}
+}
@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"open-veth/internal/models"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// topologyIDPattern restricts lab IDs to characters valid in Docker container names
+var topologyIDPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,31}$`)
+
+// nodeNamePattern keeps node names DNS-safe: they end up in container and namespace names
+var nodeNamePattern = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+// checkNodeName rejects node names that are not a DNS label
+func checkNodeName(node models.Node) error {
+	if !nodeNamePattern.MatchString(node.Name) {
+		return fmt.Errorf("invalid node name %q: use letters, digits and '-' (max 63)", node.Name)
+	}
+	return nil
+}
+
+// errIDTaken rejects a node or link ID already stored in another lab
+var errIDTaken = errors.New("id already used in another topology")
+
+// checkNodeID makes sure a node ID is free or already belongs to the node's lab.
+// IDs are global (they address /nodes/:id), so labs sharing a lab file must
+// prefix them; container and veth names are the ones scoped per lab.
+func (s *Server) checkNodeID(node models.Node) error {
+	if cur, found := s.repo.GetNode(node.ID); found && cur.TopologyID != node.TopologyID {
+		return fmt.Errorf("%w: node %s belongs to %s", errIDTaken, node.ID, cur.TopologyID)
+	}
+	return nil
+}
+
+// checkLinkID is checkNodeID for links
+func (s *Server) checkLinkID(link models.Link) error {
+	if cur, found := s.repo.GetLink(link.ID); found && cur.TopologyID != link.TopologyID {
+		return fmt.Errorf("%w: link %s belongs to %s", errIDTaken, link.ID, cur.TopologyID)
+	}
+	return nil
+}
+
+// checkLabIDs runs checkNodeID and checkLinkID over a whole lab file
+func (s *Server) checkLabIDs(topo models.Topology) error {
+	for _, node := range topo.Nodes {
+		node.TopologyID = topo.ID
+		if err := s.checkNodeID(node); err != nil {
+			return err
+		}
+	}
+	for _, link := range topo.Links {
+		link.TopologyID = topo.ID
+		if err := s.checkLinkID(link); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// --- Topology Handlers ---
+
+func (s *Server) listTopologies(c *gin.Context) {
+	topologies, err := s.repo.ListTopologies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, topologies)
+}
+
+func (s *Server) createTopology(c *gin.Context) {
+	var topo models.Topology
+	if err := c.ShouldBindJSON(&topo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !topologyIDPattern.MatchString(topo.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid topology id"})
+		return
+	}
+	if err := validatePools(topo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateBudget(topo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if _, exists := s.repo.GetTopology(topo.ID); exists {
+		c.JSON(http.StatusConflict, gin.H{"error": "topology already exists"})
+		return
+	}
+
+	topo.CreatedAt = time.Now()
+	if err := s.repo.SaveTopology(topo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	topo.Nodes, topo.Links = []models.Node{}, []models.Link{}
+	c.JSON(http.StatusCreated, topo)
+}
+
+func (s *Server) getTopology(c *gin.Context) {
+	topo, found := s.loadTopology(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "topology not found"})
+		return
+	}
+	c.JSON(http.StatusOK, topo)
+}
+
+func (s *Server) updateTopology(c *gin.Context) {
+	topo, found := s.repo.GetTopology(c.Param("id"))
+	if !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "topology not found"})
+		return
+	}
+
+	var req struct {
+		Name         string `json:"name"`
+		P2PPoolV4    string `json:"p2p_pool_v4"`
+		P2PPoolV6    string `json:"p2p_pool_v6"`
+		LoopbackPool string `json:"loopback_pool"`
+		CPUBudget    string `json:"cpu_budget"`
+		RAMBudget    string `json:"ram_budget"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	topo.Name = req.Name
+	topo.P2PPoolV4, topo.P2PPoolV6, topo.LoopbackPool = req.P2PPoolV4, req.P2PPoolV6, req.LoopbackPool
+	topo.CPUBudget, topo.RAMBudget = req.CPUBudget, req.RAMBudget
+	if err := validatePools(topo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := validateBudget(topo); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// A new budget must still cover the nodes already running
+	nodes, _ := s.repo.ListNodesByTopology(topo.ID)
+	if err := checkBudget(topo, nodes); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.repo.SaveTopology(topo); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, topo)
+}
+
+// deleteTopology removes every node of the lab (links first, as deleteNode does) and
+// then the lab itself. A node that cannot be removed keeps the lab: the report (207)
+// tells what is left, and the request can be retried.
+func (s *Server) deleteTopology(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("id")
+	if _, found := s.repo.GetTopology(id); !found {
+		c.JSON(http.StatusNotFound, gin.H{"error": "topology not found"})
+		return
+	}
+
+	// Terminals and captures hold execs and sockets in the namespaces going away
+	s.endLabSessions(id)
+	s.stopLabCaptures(id)
+
+	report := DeployReport{TopologyID: id, Nodes: []DeployResult{}, Links: []DeployResult{}}
+	nodes, _ := s.repo.ListNodesByTopology(id)
+	for _, node := range nodes {
+		removed := s.removeNode(ctx, node)
+		for _, res := range append(removed.Links, removed.Nodes...) {
+			s.publishProgress(id, res)
+		}
+		report.Nodes = append(report.Nodes, removed.Nodes...)
+		report.Links = append(report.Links, removed.Links...)
+	}
+	if report.Failed() {
+		c.JSON(http.StatusMultiStatus, report)
+		return
+	}
+
+	if err := s.repo.DeleteTopology(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// loadTopology returns a stored topology together with its nodes and links
+func (s *Server) loadTopology(id string) (models.Topology, bool) {
+	topo, found := s.repo.GetTopology(id)
+	if !found {
+		return models.Topology{}, false
+	}
+
+	topo.Nodes, _ = s.repo.ListNodesByTopology(id)
+	topo.Links, _ = s.repo.ListLinksByTopology(id)
+	return topo, true
+}
+
+// ensureTopology resolves the lab a new node or link belongs to.
+// An empty ID maps to the default lab, which is created on first use.
+func (s *Server) ensureTopology(id string) (string, error) {
+	if id == "" {
+		id = models.DefaultTopologyID
+	}
+	if _, found := s.repo.GetTopology(id); found {
+		return id, nil
+	}
+	if id != models.DefaultTopologyID {
+		return "", fmt.Errorf("topology %s not found", id)
+	}
+
+	topo := models.Topology{ID: id, Name: "Default Laboratory", CreatedAt: time.Now()}
+	if err := s.repo.SaveTopology(topo); err != nil {
+		return "", fmt.Errorf("error saving topology %s: %v", id, err)
+	}
+	return id, nil
+}
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"open-veth/internal/events"
+	"open-veth/internal/models"
+	"open-veth/internal/orchestrator"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// watchContainers follows Docker events for lab containers until ctx is cancelled.
+// A container that starts again comes back with a fresh network namespace, so its
+// PID is refreshed and every link and bridge port is re-created from storage.
+func (s *Server) watchContainers(ctx context.Context) {
+	for {
+		events, errs := s.runtime.WatchContainers(ctx)
+		for ev := range events {
+			s.handleContainerEvent(ctx, ev)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case err := <-errs:
+			fmt.Printf("Warning: Docker event stream interrupted: %v. Retrying...\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+// handleContainerEvent updates the stored node a Docker event refers to
+func (s *Server) handleContainerEvent(ctx context.Context, ev orchestrator.ContainerEvent) {
+	node, found := s.nodeForContainer(ev)
+	if !found {
+		return // Not (or no longer) part of a stored lab
+	}
+
+	s.publish(events.Event{
+		Type:       events.NodeState,
+		Source:     events.SourceDocker,
+		TopologyID: node.TopologyID,
+		NodeID:     node.ID,
+		Data:       gin.H{"action": ev.Action, "container_id": ev.ContainerID},
+	})
+
+	switch ev.Action {
+	case "start":
+		fmt.Printf("Node %s started, restoring links...\n", node.Name)
+		report := s.restoreNode(ctx, node)
+		for _, res := range append(report.Nodes, report.Links...) {
+			if res.Status == "error" {
+				fmt.Printf("Warning: restoring %s %s: %s\n", res.Kind, res.ID, res.Error)
+			}
+		}
+	case "die", "stop":
+		s.updateStoredNode(node.ID, ev.ContainerID, func(n *models.Node) {
+			s.unwatchNodeKernel(n.ID)
+			n.PID = 0
+			n.Status = models.StatusStopped
+		})
+	case "pause":
+		s.updateStoredNode(node.ID, ev.ContainerID, func(n *models.Node) { n.Status = models.StatusPaused })
+	case "unpause":
+		s.updateStoredNode(node.ID, ev.ContainerID, func(n *models.Node) { n.Status = models.StatusRunning })
+	case "destroy":
+		s.updateStoredNode(node.ID, ev.ContainerID, func(n *models.Node) {
+			n.ContainerID, n.PID, n.Status = "", 0, models.StatusMissing
+		})
+	}
+}
+
+// updateStoredNode re-reads the node under s.plumbMu, as restoreNode does, so a
+// concurrent restore, lifecycle call or container event is not overwritten with stale
+// data. Changes for a container the node no longer uses (it was recreated) are
+// dropped and found is false.
+func (s *Server) updateStoredNode(nodeID, containerID string, update func(*models.Node)) (node models.Node, found bool, err error) {
+	s.plumbMu.Lock()
+	defer s.plumbMu.Unlock()
+
+	node, found = s.repo.GetNode(nodeID)
+	if !found || node.ContainerID != containerID {
+		return node, false, nil
+	}
+	update(&node)
+	return node, true, s.repo.SaveNode(node)
+}
+
+// nodeForContainer finds the stored node of a container, by ID first and then by labels
+func (s *Server) nodeForContainer(ev orchestrator.ContainerEvent) (models.Node, bool) {
+	nodes, _ := s.repo.ListNodes()
+	for _, n := range nodes {
+		if n.ContainerID == ev.ContainerID {
+			return n, true
+		}
+	}
+	for _, n := range nodes {
+		if ev.NodeName != "" && n.Name == ev.NodeName && n.TopologyID == ev.TopologyID {
+			return n, true
+		}
+	}
+	return models.Node{}, false
+}
+
+// restoreNode refreshes a single node and re-plumbs the links attached to it
+func (s *Server) restoreNode(ctx context.Context, node models.Node) DeployReport {
+	s.plumbMu.Lock()
+	defer s.plumbMu.Unlock()
+
+	// Re-read: a concurrent restore may already have refreshed the node
+	if stored, found := s.repo.GetNode(node.ID); found {
+		node = stored
+	}
+
+	report := DeployReport{
+		TopologyID: node.TopologyID,
+		Nodes:      []DeployResult{},
+		Links:      []DeployResult{},
+	}
+
+	res := DeployResult{ID: node.ID, Kind: "node", Action: "refresh", Status: "ok"}
+	updated, replaced, err := s.refreshNode(ctx, node)
+	if err != nil {
+		res.Status = "error"
+		res.Error = err.Error()
+	}
+	report.Nodes = append(report.Nodes, res)
+	if err != nil || !nodeUp(updated) {
+		return report
+	}
+
+	// Loopback addresses are lost with the namespace too
+	if err := s.restoreAddresses(updated, "lo"); err != nil {
+		fmt.Printf("Warning: %v\n", err)
+	}
+
+	links, _ := s.repo.ListLinksByTopology(node.TopologyID)
+	for _, link := range links {
+		if link.SourceID != node.ID && link.TargetID != node.ID {
+			continue
+		}
+
+		res := DeployResult{ID: link.ID, Kind: "link", Action: "verify", Status: "ok"}
+		source, okS := s.repo.GetNode(link.SourceID)
+		target, okT := s.repo.GetNode(link.TargetID)
+		if !okS || !okT || !nodeUp(source) || !nodeUp(target) {
+			res.Status = "skipped"
+			res.Error = "source or target node not running"
+			report.Links = append(report.Links, res)
+			continue
+		}
+
+		if replaced || !s.linkIntact(link, source, target) {
+			res.Action = "recreate"
+			if err := s.replumbLink(ctx, link, source, target); err != nil {
+				res.Status = "error"
+				res.Error = err.Error()
+			}
+		}
+		report.Links = append(report.Links, res)
+	}
+
+	return report
+}
@@ -0,0 +1,152 @@
+package capture
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+// EtherTypes y protocolos IP que reconoce el decodificador
+const (
+	EtherTypeIPv4 = 0x0800
+	EtherTypeARP  = 0x0806
+	EtherTypeVLAN = 0x8100
+	EtherTypeIPv6 = 0x86DD
+	EtherTypeLLDP = 0x88CC
+
+	ProtoICMP   = 1
+	ProtoIGMP   = 2
+	ProtoTCP    = 6
+	ProtoUDP    = 17
+	ProtoICMPv6 = 58
+	ProtoOSPF   = 89
+	ProtoVRRP   = 112
+)
+
+// Packet son los campos de un frame Ethernet que usan los filtros y el resumen.
+// Solo se decodifica lo que hace falta: Ethernet (con un tag 802.1Q), ARP,
+// IPv4/IPv6 y los puertos de TCP/UDP o el tipo de ICMP.
+type Packet struct {
+	EtherType uint16
+	VLAN      uint16 // 0 = sin tag
+	ARPOp     uint16 // 1 = request, 2 = reply
+
+	// Capa 3 (IPv4 o IPv6)
+	IPVersion uint8
+	Src, Dst  netip.Addr
+	Proto     uint8 // Protocolo IP (next header en IPv6, sin extensiones)
+	Fragment  bool  // Fragmento que no es el primero: no hay cabecera de capa 4
+
+	// Capa 4
+	SrcPort, DstPort   uint16
+	ICMPType, ICMPCode uint8
+	TCPFlags           uint8
+
+	// Payload es lo que sigue a la última cabecera decodificada (puede estar truncado por el snaplen)
+	Payload []byte
+}
+
+// Decode interpreta un frame Ethernet. Un frame truncado devuelve lo que se pudo leer.
+func Decode(frame []byte) Packet {
+	var p Packet
+	if len(frame) < 14 {
+		return p
+	}
+	p.EtherType = binary.BigEndian.Uint16(frame[12:14])
+	data := frame[14:]
+	if p.EtherType == EtherTypeVLAN && len(data) >= 4 {
+		p.VLAN = binary.BigEndian.Uint16(data[0:2]) & 0x0FFF
+		p.EtherType = binary.BigEndian.Uint16(data[2:4])
+		data = data[4:]
+	}
+
+	switch p.EtherType {
+	case EtherTypeIPv4:
+		data = p.decodeIPv4(data)
+	case EtherTypeIPv6:
+		data = p.decodeIPv6(data)
+	case EtherTypeARP:
+		// Direcciones IPv4 del emisor y del destino (Ethernet/IPv4)
+		if len(data) >= 28 && binary.BigEndian.Uint16(data[2:4]) == EtherTypeIPv4 {
+			p.ARPOp = binary.BigEndian.Uint16(data[6:8])
+			p.Src = netip.AddrFrom4([4]byte(data[14:18]))
+			p.Dst = netip.AddrFrom4([4]byte(data[24:28]))
+			data = data[28:]
+		}
+		p.Payload = data
+		return p
+	default:
+		p.Payload = data
+		return p
+	}
+
+	if data == nil || p.Fragment {
+		p.Payload = data
+		return p
+	}
+	p.Payload = p.decodeTransport(data)
+	return p
+}
+
+// IsIP indica si el paquete tiene una cabecera IPv4 o IPv6 decodificada
+func (p Packet) IsIP() bool {
+	return p.IPVersion != 0
+}
+
+func (p *Packet) decodeIPv4(data []byte) []byte {
+	if len(data) < 20 || data[0]>>4 != 4 {
+		return nil
+	}
+	ihl := int(data[0]&0x0F) * 4
+	if ihl < 20 || len(data) < ihl {
+		return nil
+	}
+	p.IPVersion = 4
+	p.Proto = data[9]
+	p.Src = netip.AddrFrom4([4]byte(data[12:16]))
+	p.Dst = netip.AddrFrom4([4]byte(data[16:20]))
+	p.Fragment = binary.BigEndian.Uint16(data[6:8])&0x1FFF != 0
+	return data[ihl:]
+}
+
+func (p *Packet) decodeIPv6(data []byte) []byte {
+	if len(data) < 40 || data[0]>>4 != 6 {
+		return nil
+	}
+	p.IPVersion = 6
+	p.Proto = data[6]
+	p.Src = netip.AddrFrom16([16]byte(data[8:24]))
+	p.Dst = netip.AddrFrom16([16]byte(data[24:40]))
+	return data[40:]
+}
+
+// decodeTransport lee puertos o tipo de ICMP y devuelve el payload de capa 4
+func (p *Packet) decodeTransport(data []byte) []byte {
+	switch p.Proto {
+	case ProtoTCP:
+		if len(data) < 20 {
+			return data
+		}
+		p.SrcPort = binary.BigEndian.Uint16(data[0:2])
+		p.DstPort = binary.BigEndian.Uint16(data[2:4])
+		p.TCPFlags = data[13]
+		off := int(data[12]>>4) * 4
+		if off < 20 || len(data) < off {
+			return nil
+		}
+		return data[off:]
+	case ProtoUDP:
+		if len(data) < 8 {
+			return data
+		}
+		p.SrcPort = binary.BigEndian.Uint16(data[0:2])
+		p.DstPort = binary.BigEndian.Uint16(data[2:4])
+		return data[8:]
+	case ProtoICMP, ProtoICMPv6:
+		if len(data) < 4 {
+			return data
+		}
+		p.ICMPType, p.ICMPCode = data[0], data[1]
+		return data[4:]
+	}
+	return data
+}
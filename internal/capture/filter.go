@@ -0,0 +1,396 @@
+package capture
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// maxBPFInstructions es el límite del kernel para un filtro clásico (BPF_MAXINSNS)
+const maxBPFInstructions = 4096
+
+// Instruction es una instrucción de BPF clásico, como las imprime 'tcpdump -ddd'
+type Instruction struct {
+	Op     uint16
+	Jt, Jf uint8
+	K      uint32
+}
+
+// Filter decide qué paquetes se capturan. Se arma a partir de:
+//   - una expresión con la sintaxis de pcap-filter (un subconjunto, ver Compile),
+//     que se evalúa en userspace sobre el paquete decodificado
+//   - o el BPF ya compilado de 'tcpdump -ddd' (líneas o separado por comas),
+//     que se carga en el kernel con SO_ATTACH_FILTER
+type Filter struct {
+	Expr  string
+	match matcher
+	bpf   []Instruction
+}
+
+type matcher func(p *Packet) bool
+
+// Match indica si el paquete pasa el filtro. Un filtro nil o de BPF (que ya
+// aplicó el kernel) deja pasar todo.
+func (f *Filter) Match(p *Packet) bool {
+	if f == nil || f.match == nil {
+		return true
+	}
+	return f.match(p)
+}
+
+// Kernel devuelve el programa BPF a cargar en el socket, o nil si el filtro es de userspace
+func (f *Filter) Kernel() []Instruction {
+	if f == nil {
+		return nil
+	}
+	return f.bpf
+}
+
+// Compile arma un filtro. Una expresión vacía devuelve nil (sin filtro).
+//
+// Sintaxis soportada:
+//
+//	expr      := expr (and|&&) expr | expr (or|||) expr | (not|!) expr | "(" expr ")" | primitiva
+//	primitiva := ip | ip6 | arp | icmp | icmp6 | tcp | udp | igmp | ospf | vrrp | bgp | lldp
+//	           | [ip|ip6|arp] [src|dst] host ADDR
+//	           | [ip|ip6] [src|dst] net CIDR
+//	           | [tcp|udp] [src|dst] port N
+//	           | [ip|ip6] proto N|NOMBRE
+//
+// "and" tiene más precedencia que "or", igual que en tcpdump.
+func Compile(expr string) (*Filter, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return nil, nil
+	}
+
+	if prog, ok, err := parseBPF(expr); ok {
+		if err != nil {
+			return nil, err
+		}
+		return &Filter{Expr: expr, bpf: prog}, nil
+	}
+
+	p := &parser{tokens: tokenize(expr)}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != "" {
+		return nil, fmt.Errorf("unexpected %q in filter", tok)
+	}
+	return &Filter{Expr: expr, match: m}, nil
+}
+
+// parseBPF reconoce la salida de 'tcpdump -ddd': la cantidad de instrucciones y
+// después "op jt jf k" por instrucción. ok es false si la expresión no es numérica.
+func parseBPF(expr string) ([]Instruction, bool, error) {
+	fields := strings.FieldsFunc(expr, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+	if len(fields) == 0 {
+		return nil, false, nil
+	}
+	nums := make([]uint64, len(fields))
+	for i, f := range fields {
+		n, err := strconv.ParseUint(f, 10, 32)
+		if err != nil {
+			return nil, false, nil
+		}
+		nums[i] = n
+	}
+
+	count := nums[0]
+	if count == 0 || count > maxBPFInstructions || uint64(len(nums)-1) != count*4 {
+		return nil, true, fmt.Errorf("invalid BPF program: expected the output of 'tcpdump -ddd'")
+	}
+	prog := make([]Instruction, count)
+	for i := range prog {
+		op, jt, jf, k := nums[1+i*4], nums[2+i*4], nums[3+i*4], nums[4+i*4]
+		if op > 0xFFFF || jt > 0xFF || jf > 0xFF {
+			return nil, true, fmt.Errorf("invalid BPF instruction %d", i)
+		}
+		prog[i] = Instruction{Op: uint16(op), Jt: uint8(jt), Jf: uint8(jf), K: uint32(k)}
+	}
+	return prog, true, nil
+}
+
+// tokenize separa palabras y operadores ( ) ! && ||
+func tokenize(expr string) []string {
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			flush()
+		case c == '(' || c == ')' || c == '!':
+			flush()
+			tokens = append(tokens, string(c))
+		case (c == '&' || c == '|') && i+1 < len(expr) && expr[i+1] == c:
+			flush()
+			tokens = append(tokens, expr[i:i+2])
+			i++
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return tokens
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *parser) parseOr() (matcher, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok == "or" || tok == "||"; tok = p.peek() {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(pk *Packet) bool { return l(pk) || right(pk) }
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (matcher, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for tok := p.peek(); tok == "and" || tok == "&&"; tok = p.peek() {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(pk *Packet) bool { return l(pk) && right(pk) }
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (matcher, error) {
+	switch p.peek() {
+	case "not", "!":
+		p.next()
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(pk *Packet) bool { return !m(pk) }, nil
+	case "(":
+		p.next()
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.next() != ")" {
+			return nil, fmt.Errorf("missing ')' in filter")
+		}
+		return m, nil
+	case "":
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	return p.parsePrimitive()
+}
+
+// Protocolos que se pueden usar solos ("ospf") y cuáles sirven de calificador ("tcp port 179")
+var (
+	protoMatchers = map[string]matcher{
+		"ip":    func(p *Packet) bool { return p.IPVersion == 4 },
+		"ip6":   func(p *Packet) bool { return p.IPVersion == 6 },
+		"arp":   func(p *Packet) bool { return p.EtherType == EtherTypeARP },
+		"icmp":  func(p *Packet) bool { return p.IPVersion == 4 && p.Proto == ProtoICMP },
+		"icmp6": func(p *Packet) bool { return p.IPVersion == 6 && p.Proto == ProtoICMPv6 },
+		"tcp":   func(p *Packet) bool { return p.IsIP() && p.Proto == ProtoTCP },
+		"udp":   func(p *Packet) bool { return p.IsIP() && p.Proto == ProtoUDP },
+		"igmp":  func(p *Packet) bool { return p.IPVersion == 4 && p.Proto == ProtoIGMP },
+		"ospf":  func(p *Packet) bool { return p.IsIP() && p.Proto == ProtoOSPF },
+		"vrrp":  func(p *Packet) bool { return p.IsIP() && p.Proto == ProtoVRRP },
+		"bgp":   func(p *Packet) bool { return p.IsIP() && p.Proto == ProtoTCP && (p.SrcPort == 179 || p.DstPort == 179) },
+		"lldp":  func(p *Packet) bool { return p.EtherType == EtherTypeLLDP },
+	}
+	qualifiers = map[string]bool{"ip": true, "ip6": true, "arp": true, "tcp": true, "udp": true}
+
+	protoNumbers = map[string]uint8{
+		"icmp": ProtoICMP, "igmp": ProtoIGMP, "tcp": ProtoTCP, "udp": ProtoUDP,
+		"icmp6": ProtoICMPv6, "ospf": ProtoOSPF, "vrrp": ProtoVRRP,
+	}
+	portNames = map[string]uint16{
+		"ssh": 22, "domain": 53, "bootps": 67, "bootpc": 68, "http": 80, "ntp": 123,
+		"snmp": 161, "bgp": 179, "https": 443, "syslog": 514, "rip": 520,
+	}
+)
+
+func isKeyword(tok string) bool {
+	switch tok {
+	case "src", "dst", "host", "net", "port", "proto":
+		return true
+	}
+	return false
+}
+
+// parsePrimitive lee [calificador] [src|dst] tipo valor, o un protocolo solo
+func (p *parser) parsePrimitive() (matcher, error) {
+	qual := ""
+	if tok := p.peek(); qualifiers[tok] && p.pos+1 < len(p.tokens) && isKeyword(p.tokens[p.pos+1]) {
+		qual = p.next()
+	} else if m, ok := protoMatchers[tok]; ok {
+		p.next()
+		return m, nil
+	}
+
+	dir := ""
+	if tok := p.peek(); tok == "src" || tok == "dst" {
+		dir = p.next()
+	}
+
+	kind := p.next()
+	switch kind {
+	case "host", "net", "port":
+	case "proto":
+		if dir != "" {
+			return nil, fmt.Errorf("'%s proto' is not valid", dir)
+		}
+	default:
+		return nil, fmt.Errorf("unknown filter primitive %q", kind)
+	}
+
+	value := p.next()
+	if value == "" || value == "(" || value == ")" {
+		return nil, fmt.Errorf("%s requires a value", kind)
+	}
+
+	var m matcher
+	var err error
+	switch kind {
+	case "host":
+		m, err = hostMatcher(qual, dir, value)
+	case "net":
+		m, err = netMatcher(qual, dir, value)
+	case "port":
+		m, err = portMatcher(qual, dir, value)
+	case "proto":
+		m, err = protoMatcher(qual, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if qual != "" {
+		q := protoMatchers[qual]
+		inner := m
+		m = func(pk *Packet) bool { return q(pk) && inner(pk) }
+	}
+	return m, nil
+}
+
+// addrMatcher aplica la dirección (src, dst o cualquiera de las dos)
+func addrMatcher(dir string, match func(netip.Addr) bool) matcher {
+	return func(p *Packet) bool {
+		if !p.IsIP() && p.EtherType != EtherTypeARP {
+			return false
+		}
+		switch dir {
+		case "src":
+			return match(p.Src)
+		case "dst":
+			return match(p.Dst)
+		}
+		return match(p.Src) || match(p.Dst)
+	}
+}
+
+func hostMatcher(qual, dir, value string) (matcher, error) {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid host %q", value)
+	}
+	if (qual == "ip" || qual == "arp") && !addr.Is4() || qual == "ip6" && !addr.Is6() {
+		return nil, fmt.Errorf("host %s does not match %s", value, qual)
+	}
+	return addrMatcher(dir, func(a netip.Addr) bool { return a == addr }), nil
+}
+
+func netMatcher(qual, dir, value string) (matcher, error) {
+	prefix, err := netip.ParsePrefix(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid net %q, use CIDR notation", value)
+	}
+	if qual == "ip" && !prefix.Addr().Is4() || qual == "ip6" && !prefix.Addr().Is6() {
+		return nil, fmt.Errorf("net %s does not match %s", value, qual)
+	}
+	prefix = prefix.Masked()
+	return addrMatcher(dir, prefix.Contains), nil
+}
+
+func portMatcher(qual, dir, value string) (matcher, error) {
+	if qual != "" && qual != "tcp" && qual != "udp" {
+		return nil, fmt.Errorf("'%s port' is not valid, use tcp or udp", qual)
+	}
+	port, ok := portNames[value]
+	if !ok {
+		n, err := strconv.ParseUint(value, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", value)
+		}
+		port = uint16(n)
+	}
+	return func(p *Packet) bool {
+		if !p.IsIP() || p.Fragment || p.Proto != ProtoTCP && p.Proto != ProtoUDP {
+			return false
+		}
+		switch dir {
+		case "src":
+			return p.SrcPort == port
+		case "dst":
+			return p.DstPort == port
+		}
+		return p.SrcPort == port || p.DstPort == port
+	}, nil
+}
+
+func protoMatcher(qual, value string) (matcher, error) {
+	if qual != "" && qual != "ip" && qual != "ip6" {
+		return nil, fmt.Errorf("'%s proto' is not valid, use ip or ip6", qual)
+	}
+	proto, ok := protoNumbers[value]
+	if !ok {
+		n, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid protocol %q", value)
+		}
+		proto = uint8(n)
+	}
+	return func(p *Packet) bool { return p.IsIP() && p.Proto == proto }, nil
+}
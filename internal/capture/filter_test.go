@@ -0,0 +1,108 @@
+package capture
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// ipv4Frame arma un frame Ethernet/IPv4 mínimo con cabecera de capa 4
+func ipv4Frame(src, dst [4]byte, proto uint8, l4 []byte) []byte {
+	frame := make([]byte, 14, 14+20+len(l4))
+	binary.BigEndian.PutUint16(frame[12:], EtherTypeIPv4)
+
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(l4)))
+	ip[8] = 64
+	ip[9] = proto
+	copy(ip[12:], src[:])
+	copy(ip[16:], dst[:])
+	frame = append(frame, ip...)
+	return append(frame, l4...)
+}
+
+func tcpHeader(srcPort, dstPort uint16) []byte {
+	h := make([]byte, 20)
+	binary.BigEndian.PutUint16(h[0:], srcPort)
+	binary.BigEndian.PutUint16(h[2:], dstPort)
+	h[12] = 5 << 4
+	h[13] = 0x02 // SYN
+	return h
+}
+
+func TestDecodeTCP(t *testing.T) {
+	p := Decode(ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, ProtoTCP, tcpHeader(40000, 179)))
+	if p.IPVersion != 4 || p.Proto != ProtoTCP || p.SrcPort != 40000 || p.DstPort != 179 || p.TCPFlags != 0x02 {
+		t.Fatalf("paquete mal decodificado: %+v", p)
+	}
+	if p.Src.String() != "10.0.0.1" || p.Dst.String() != "10.0.0.2" {
+		t.Errorf("direcciones inesperadas: %s -> %s", p.Src, p.Dst)
+	}
+}
+
+func TestFilterExpressions(t *testing.T) {
+	bgp := Decode(ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, ProtoTCP, tcpHeader(40000, 179)))
+	ospf := Decode(ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{224, 0, 0, 5}, ProtoOSPF, make([]byte, 24)))
+
+	cases := []struct {
+		expr      string
+		bgp, ospf bool
+	}{
+		{"ospf", false, true},
+		{"bgp", true, false},
+		{"tcp port 179", true, false},
+		{"dst port 179", true, false},
+		{"src port 179", false, false},
+		{"ip proto 89", false, true},
+		{"ip proto ospf", false, true},
+		{"host 10.0.0.1", true, true},
+		{"dst host 10.0.0.2", true, false},
+		{"net 224.0.0.0/4", false, true},
+		{"src net 10.0.0.0/24 and not ospf", true, false},
+		{"icmp or (tcp && dst port 179)", true, false},
+		{"!tcp", false, true},
+		{"udp or ip6", false, false},
+	}
+	for _, tc := range cases {
+		f, err := Compile(tc.expr)
+		if err != nil {
+			t.Errorf("%q: error inesperado: %v", tc.expr, err)
+			continue
+		}
+		if got := f.Match(&bgp); got != tc.bgp {
+			t.Errorf("%q sobre BGP: se esperaba %v", tc.expr, tc.bgp)
+		}
+		if got := f.Match(&ospf); got != tc.ospf {
+			t.Errorf("%q sobre OSPF: se esperaba %v", tc.expr, tc.ospf)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	for _, expr := range []string{"tcp port", "host 300.1.1.1", "(ospf", "ospf and", "foo", "ip6 host 10.0.0.1", "src proto 6"} {
+		if _, err := Compile(expr); err == nil {
+			t.Errorf("%q: se esperaba un error", expr)
+		}
+	}
+	if f, err := Compile("  "); f != nil || err != nil {
+		t.Errorf("un filtro vacío no debería filtrar nada")
+	}
+}
+
+// TestFilterBPF acepta la salida de 'tcpdump -ddd ospf' tal cual y separada por comas
+func TestFilterBPF(t *testing.T) {
+	raw := "6\n40 0 0 12\n21 0 3 2048\n48 0 0 23\n21 0 1 89\n6 0 0 262144\n6 0 0 0\n"
+	for _, expr := range []string{raw, "6,40 0 0 12,21 0 3 2048,48 0 0 23,21 0 1 89,6 0 0 262144,6 0 0 0"} {
+		f, err := Compile(expr)
+		if err != nil {
+			t.Fatalf("error inesperado: %v", err)
+		}
+		prog := f.Kernel()
+		if len(prog) != 6 || prog[1] != (Instruction{Op: 21, Jt: 0, Jf: 3, K: 2048}) {
+			t.Errorf("programa inesperado: %+v", prog)
+		}
+	}
+	if _, err := Compile("2\n40 0 0 12\n"); err == nil {
+		t.Errorf("se esperaba error para un programa incompleto")
+	}
+}
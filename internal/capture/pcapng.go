@@ -0,0 +1,255 @@
+package capture
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Tipos de bloque y opciones de pcapng (https://www.ietf.org/archive/id/draft-ietf-opsawg-pcapng)
+const (
+	blockSHB = 0x0A0D0D0A // Section Header
+	blockIDB = 0x00000001 // Interface Description
+	blockEPB = 0x00000006 // Enhanced Packet
+
+	byteOrderMagic = 0x1A2B3C4D
+
+	optEndOfOpt = 0
+	optIfName   = 2
+	optIfTsRes  = 9
+	optEpbFlags = 2
+	optShbApp   = 4
+
+	linkTypeEthernet = 1
+)
+
+// Direction es el sentido de un paquete respecto de la interfaz capturada
+type Direction uint8
+
+const (
+	DirUnknown  Direction = 0
+	DirInbound  Direction = 1
+	DirOutbound Direction = 2
+)
+
+// Writer escribe un stream pcapng con una única interfaz Ethernet.
+// Cada paquete se escribe completo en una sola llamada a Write, así el stream se
+// puede cortar en cualquier momento y lo que llegó es legible (wireshark -k -i -).
+type Writer struct {
+	w   io.Writer
+	buf []byte
+}
+
+// NewWriter escribe el Section Header y la descripción de la interfaz capturada
+func NewWriter(w io.Writer, ifName string, snaplen uint32) (*Writer, error) {
+	pw := &Writer{w: w}
+
+	// Section Header Block
+	body := le32(nil, byteOrderMagic)
+	body = le16(body, 1) // Versión 1.0
+	body = le16(body, 0)
+	body = le64(body, ^uint64(0)) // Largo de sección desconocido (stream)
+	body = appendOption(body, optShbApp, []byte("open-veth"))
+	body = appendOption(body, optEndOfOpt, nil)
+	if err := pw.writeBlock(blockSHB, body); err != nil {
+		return nil, err
+	}
+
+	// Interface Description Block (timestamps en nanosegundos)
+	body = le16(nil, linkTypeEthernet)
+	body = le16(body, 0)
+	body = le32(body, snaplen)
+	body = appendOption(body, optIfName, []byte(ifName))
+	body = appendOption(body, optIfTsRes, []byte{9})
+	body = appendOption(body, optEndOfOpt, nil)
+	if err := pw.writeBlock(blockIDB, body); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WritePacket agrega un Enhanced Packet Block. origLen es el largo en el cable
+// (puede ser mayor que len(data) si el snaplen lo recortó).
+func (pw *Writer) WritePacket(ts time.Time, data []byte, origLen int, dir Direction) error {
+	nanos := uint64(ts.UnixNano())
+
+	body := le32(pw.buf[:0], 0) // Interfaz 0
+	body = le32(body, uint32(nanos>>32))
+	body = le32(body, uint32(nanos))
+	body = le32(body, uint32(len(data)))
+	body = le32(body, uint32(origLen))
+	body = append(body, data...)
+	body = pad32(body)
+	if dir != DirUnknown {
+		body = appendOption(body, optEpbFlags, le32(nil, uint32(dir)))
+		body = appendOption(body, optEndOfOpt, nil)
+	}
+	pw.buf = body
+	return pw.writeBlock(blockEPB, body)
+}
+
+// writeBlock escribe tipo, largo total, cuerpo y largo total repetido en un solo Write
+func (pw *Writer) writeBlock(kind uint32, body []byte) error {
+	total := uint32(12 + len(body))
+	block := make([]byte, 0, total)
+	block = le32(block, kind)
+	block = le32(block, total)
+	block = append(block, body...)
+	block = le32(block, total)
+	_, err := pw.w.Write(block)
+	return err
+}
+
+// appendOption agrega una opción TLV alineada a 32 bits
+func appendOption(b []byte, code uint16, value []byte) []byte {
+	b = le16(b, code)
+	b = le16(b, uint16(len(value)))
+	b = append(b, value...)
+	return pad32(b)
+}
+
+func pad32(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func le16(b []byte, v uint16) []byte { return binary.LittleEndian.AppendUint16(b, v) }
+func le32(b []byte, v uint32) []byte { return binary.LittleEndian.AppendUint32(b, v) }
+func le64(b []byte, v uint64) []byte { return binary.LittleEndian.AppendUint64(b, v) }
+
+// Record es un paquete leído de un archivo pcapng
+type Record struct {
+	Time    time.Time
+	Data    []byte
+	OrigLen int
+	Dir     Direction
+}
+
+// Reader lee los paquetes de un archivo pcapng (little endian, como los que escribe Writer)
+type Reader struct {
+	r      io.Reader
+	tsResN []uint64 // Unidades por segundo de cada interfaz
+}
+
+// NewReader valida el Section Header del stream
+func NewReader(r io.Reader) (*Reader, error) {
+	pr := &Reader{r: r}
+	kind, body, err := pr.readBlock()
+	if err != nil {
+		return nil, fmt.Errorf("error leyendo pcapng: %v", err)
+	}
+	if kind != blockSHB || len(body) < 16 || binary.LittleEndian.Uint32(body) != byteOrderMagic {
+		return nil, fmt.Errorf("not a little-endian pcapng stream")
+	}
+	return pr, nil
+}
+
+// Next devuelve el próximo paquete o io.EOF. Un último bloque incompleto (un
+// archivo que se está escribiendo) también se informa como io.EOF.
+func (pr *Reader) Next() (Record, error) {
+	for {
+		kind, body, err := pr.readBlock()
+		if err != nil {
+			if errors.Is(err, io.ErrUnexpectedEOF) {
+				err = io.EOF
+			}
+			return Record{}, err
+		}
+
+		switch kind {
+		case blockIDB:
+			pr.tsResN = append(pr.tsResN, interfaceTsRes(body))
+		case blockEPB:
+			if len(body) < 20 {
+				return Record{}, fmt.Errorf("truncated packet block")
+			}
+			iface := binary.LittleEndian.Uint32(body[0:])
+			ts := uint64(binary.LittleEndian.Uint32(body[4:]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:]))
+			capLen := int(binary.LittleEndian.Uint32(body[12:]))
+			origLen := int(binary.LittleEndian.Uint32(body[16:]))
+			if 20+capLen > len(body) {
+				return Record{}, fmt.Errorf("truncated packet block")
+			}
+
+			res := uint64(1_000_000) // Default de pcapng: microsegundos
+			if int(iface) < len(pr.tsResN) {
+				res = pr.tsResN[iface]
+			}
+			rec := Record{
+				Time:    time.Unix(int64(ts/res), int64(ts%res*1_000_000_000/res)),
+				Data:    body[20 : 20+capLen],
+				OrigLen: origLen,
+			}
+			if end := 20 + capLen + (4-capLen%4)%4; end < len(body) {
+				if flags, ok := findOption(body[end:], optEpbFlags); ok && len(flags) == 4 {
+					rec.Dir = Direction(binary.LittleEndian.Uint32(flags) & 0x3)
+				}
+			}
+			return rec, nil
+		}
+		// Otros bloques (estadísticas, comentarios) se ignoran
+	}
+}
+
+func (pr *Reader) readBlock() (uint32, []byte, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(pr.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	kind := binary.LittleEndian.Uint32(hdr[0:])
+	total := binary.LittleEndian.Uint32(hdr[4:])
+	if total < 12 || total%4 != 0 || total > 16*1024*1024 {
+		return 0, nil, fmt.Errorf("invalid block length %d", total)
+	}
+	block := make([]byte, total-8)
+	if _, err := io.ReadFull(pr.r, block); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return kind, block[:len(block)-4], nil
+}
+
+// interfaceTsRes lee if_tsresol de un IDB: potencia de 10 (o de 2 con el bit alto)
+func interfaceTsRes(body []byte) uint64 {
+	res := uint64(1_000_000)
+	if len(body) < 8 {
+		return res
+	}
+	if v, ok := findOption(body[8:], optIfTsRes); ok && len(v) >= 1 {
+		exp := uint64(v[0] & 0x7F)
+		if exp > 9 {
+			return res // Más fino que nanosegundos: no lo necesitamos
+		}
+		base := uint64(10)
+		if v[0]&0x80 != 0 {
+			base = 2
+		}
+		res = 1
+		for range exp {
+			res *= base
+		}
+	}
+	return res
+}
+
+// findOption busca una opción TLV en una lista de opciones
+func findOption(opts []byte, code uint16) ([]byte, bool) {
+	for len(opts) >= 4 {
+		c := binary.LittleEndian.Uint16(opts[0:])
+		l := int(binary.LittleEndian.Uint16(opts[2:]))
+		if c == optEndOfOpt || 4+l > len(opts) {
+			break
+		}
+		if c == code {
+			return opts[4 : 4+l], true
+		}
+		opts = opts[4+l+(4-l%4)%4:]
+	}
+	return nil, false
+}
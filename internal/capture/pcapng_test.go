@@ -0,0 +1,109 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestWriterBlocks verifica la estructura de los bloques: tipo, largo repetido y alineación
+func TestWriterBlocks(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "r1:eth1", 65535)
+	if err != nil {
+		t.Fatalf("error creando writer: %v", err)
+	}
+	frame := ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, ProtoTCP, tcpHeader(40000, 179))
+	ts := time.Unix(1700000000, 123456789)
+	if err := w.WritePacket(ts, frame[:50], len(frame), DirOutbound); err != nil {
+		t.Fatalf("error escribiendo paquete: %v", err)
+	}
+
+	data := buf.Bytes()
+	var kinds []uint32
+	for off := 0; off < len(data); {
+		kind := binary.LittleEndian.Uint32(data[off:])
+		total := int(binary.LittleEndian.Uint32(data[off+4:]))
+		if total%4 != 0 || off+total > len(data) {
+			t.Fatalf("bloque %#x con largo inválido %d", kind, total)
+		}
+		if trailer := int(binary.LittleEndian.Uint32(data[off+total-4:])); trailer != total {
+			t.Fatalf("bloque %#x: largo final %d, se esperaba %d", kind, trailer, total)
+		}
+		if kind == blockEPB {
+			body := data[off+8:]
+			nanos := uint64(binary.LittleEndian.Uint32(body[4:]))<<32 | uint64(binary.LittleEndian.Uint32(body[8:]))
+			if int64(nanos) != ts.UnixNano() {
+				t.Errorf("timestamp inesperado: %d", nanos)
+			}
+			if capLen, orig := binary.LittleEndian.Uint32(body[12:]), binary.LittleEndian.Uint32(body[16:]); capLen != 50 || int(orig) != len(frame) {
+				t.Errorf("largos inesperados: %d/%d", capLen, orig)
+			}
+		}
+		kinds = append(kinds, kind)
+		off += total
+	}
+
+	if len(kinds) != 3 || kinds[0] != blockSHB || kinds[1] != blockIDB || kinds[2] != blockEPB {
+		t.Errorf("bloques inesperados: %#x", kinds)
+	}
+	if binary.LittleEndian.Uint32(data[8:]) != byteOrderMagic {
+		t.Errorf("falta el byte-order magic")
+	}
+}
+
+// TestReaderRoundTrip lee lo que escribe Writer, incluido un último bloque incompleto
+func TestReaderRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, "r1:eth1", 65535)
+	frame := ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, ProtoUDP, make([]byte, 9))
+	ts := time.Unix(1700000000, 5)
+	_ = w.WritePacket(ts, frame, len(frame), DirInbound)
+	_ = w.WritePacket(ts, frame, len(frame), DirOutbound)
+	data := buf.Bytes()[:buf.Len()-8] // El segundo paquete quedó a medio escribir
+
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("error leyendo cabecera: %v", err)
+	}
+	rec, err := r.Next()
+	if err != nil {
+		t.Fatalf("error leyendo paquete: %v", err)
+	}
+	if !rec.Time.Equal(ts) || !bytes.Equal(rec.Data, frame) || rec.Dir != DirInbound {
+		t.Errorf("paquete inesperado: %+v", rec)
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("se esperaba io.EOF, se obtuvo %v", err)
+	}
+}
+
+// TestRingRotation verifica que solo quedan los últimos archivos
+func TestRingRotation(t *testing.T) {
+	dir := t.TempDir()
+	// Cada paquete llena un archivo de 200 bytes: se escriben 6 y quedan los 2 últimos
+	r, err := NewRing(dir, "l1-source", "r1:eth1", 65535, 200, 2)
+	if err != nil {
+		t.Fatalf("error creando ring: %v", err)
+	}
+	frame := ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, ProtoUDP, make([]byte, 100))
+	for range 6 {
+		if err := r.WritePacket(time.Now(), frame, len(frame), DirInbound); err != nil {
+			t.Fatalf("error escribiendo: %v", err)
+		}
+	}
+	r.Close()
+
+	files := r.Files()
+	if len(files) != 2 || filepath.Base(files[1]) != "l1-source-0006.pcapng" {
+		t.Fatalf("archivos inesperados: %v", files)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Errorf("se esperaban 2 archivos en disco, hay %d", len(entries))
+	}
+}
@@ -0,0 +1,107 @@
+package capture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Ring escribe una captura en archivos pcapng que rotan al llegar a un tamaño.
+// Con maxFiles > 0 funciona como ring buffer: solo se conservan los últimos archivos.
+type Ring struct {
+	dir, prefix string
+	ifName      string
+	snaplen     uint32
+	maxSize     int64 // 0 = un solo archivo
+	maxFiles    int   // 0 = se conservan todos
+
+	seq   int
+	files []string
+	file  *os.File
+	w     *Writer
+	size  int64
+}
+
+// NewRing crea el primer archivo (<dir>/<prefix>-0001.pcapng)
+func NewRing(dir, prefix, ifName string, snaplen uint32, maxSize int64, maxFiles int) (*Ring, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creando directorio de capturas: %v", err)
+	}
+	r := &Ring{dir: dir, prefix: prefix, ifName: ifName, snaplen: snaplen, maxSize: maxSize, maxFiles: maxFiles}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// WritePacket escribe un paquete, rotando antes si el archivo actual está lleno
+func (r *Ring) WritePacket(ts time.Time, data []byte, origLen int, dir Direction) error {
+	if r.maxSize > 0 && r.size >= r.maxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	return r.w.WritePacket(ts, data, origLen, dir)
+}
+
+// Files devuelve los archivos que siguen en disco, del más viejo al más nuevo
+func (r *Ring) Files() []string {
+	return append([]string(nil), r.files...)
+}
+
+// Close cierra el archivo actual
+func (r *Ring) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *Ring) open() error {
+	r.seq++
+	path := filepath.Join(r.dir, fmt.Sprintf("%s-%04d.pcapng", r.prefix, r.seq))
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creando archivo de captura: %v", err)
+	}
+
+	r.file, r.size = f, 0
+	w, err := NewWriter(ringCounter{r}, r.ifName, r.snaplen)
+	if err != nil {
+		f.Close()
+		os.Remove(path)
+		return fmt.Errorf("error escribiendo cabecera pcapng: %v", err)
+	}
+	r.w = w
+	r.files = append(r.files, path)
+	return nil
+}
+
+// rotate cierra el archivo lleno, abre el siguiente y borra los que sobran
+func (r *Ring) rotate() error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	if err := r.open(); err != nil {
+		return err
+	}
+	for r.maxFiles > 0 && len(r.files) > r.maxFiles {
+		if err := os.Remove(r.files[0]); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("error borrando archivo de captura viejo: %v", err)
+		}
+		r.files = r.files[1:]
+	}
+	return nil
+}
+
+// ringCounter escribe en el archivo actual llevando la cuenta del tamaño
+type ringCounter struct{ r *Ring }
+
+func (c ringCounter) Write(p []byte) (int, error) {
+	n, err := c.r.file.Write(p)
+	c.r.size += int64(n)
+	return n, err
+}
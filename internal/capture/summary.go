@@ -0,0 +1,281 @@
+package capture
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// PacketInfo es una línea del listado de paquetes (lo que mostraría Wireshark)
+type PacketInfo struct {
+	No        int       `json:"no"`
+	Time      time.Time `json:"time"`
+	Source    string    `json:"source,omitempty"` // Dónde se capturó (link y extremo)
+	Direction string    `json:"direction,omitempty"`
+	Src       string    `json:"src,omitempty"`
+	Dst       string    `json:"dst,omitempty"`
+	Protocol  string    `json:"protocol"`
+	Type      string    `json:"type,omitempty"` // Hello, OPEN, echo-request...
+	Length    int       `json:"length"`
+	Info      string    `json:"info,omitempty"`
+}
+
+// ProtocolStats cuenta paquetes y mensajes de un protocolo
+type ProtocolStats struct {
+	Packets int            `json:"packets"`
+	Bytes   int64          `json:"bytes"`
+	Types   map[string]int `json:"types,omitempty"` // Mensajes por tipo (un segmento BGP puede traer varios)
+}
+
+// Summary es el resumen de una captura
+type Summary struct {
+	Packets   int                       `json:"packets"`
+	Bytes     int64                     `json:"bytes"`
+	Start     *time.Time                `json:"start,omitempty"`
+	End       *time.Time                `json:"end,omitempty"`
+	Protocols map[string]*ProtocolStats `json:"protocols"`
+}
+
+// Summarizer decodifica paquetes y acumula el resumen
+type Summarizer struct {
+	Summary
+}
+
+func NewSummarizer() *Summarizer {
+	return &Summarizer{Summary{Protocols: make(map[string]*ProtocolStats)}}
+}
+
+// Add decodifica un paquete, lo suma al resumen y devuelve su línea del listado
+func (s *Summarizer) Add(rec Record, source string) PacketInfo {
+	pkt := Decode(rec.Data)
+	proto, types, info := Describe(&pkt)
+
+	s.Packets++
+	s.Bytes += int64(rec.OrigLen)
+	if s.Start == nil || rec.Time.Before(*s.Start) {
+		t := rec.Time
+		s.Start = &t
+	}
+	if s.End == nil || rec.Time.After(*s.End) {
+		t := rec.Time
+		s.End = &t
+	}
+
+	stats, ok := s.Protocols[proto]
+	if !ok {
+		stats = &ProtocolStats{}
+		s.Protocols[proto] = stats
+	}
+	stats.Packets++
+	stats.Bytes += int64(rec.OrigLen)
+	for _, t := range types {
+		if stats.Types == nil {
+			stats.Types = make(map[string]int)
+		}
+		stats.Types[t]++
+	}
+
+	pi := PacketInfo{
+		No:       s.Packets,
+		Time:     rec.Time,
+		Source:   source,
+		Protocol: proto,
+		Length:   rec.OrigLen,
+		Info:     info,
+	}
+	switch rec.Dir {
+	case DirInbound:
+		pi.Direction = "in"
+	case DirOutbound:
+		pi.Direction = "out"
+	}
+	if pkt.Src.IsValid() {
+		pi.Src, pi.Dst = pkt.Src.String(), pkt.Dst.String()
+	}
+	if len(types) > 0 {
+		pi.Type = types[0]
+	}
+	return pi
+}
+
+// Nombres de mensajes de los protocolos que se decodifican
+var (
+	ospfTypes = map[byte]string{1: "Hello", 2: "DB Description", 3: "LS Request", 4: "LS Update", 5: "LS Ack"}
+	bgpTypes  = map[byte]string{1: "OPEN", 2: "UPDATE", 3: "NOTIFICATION", 4: "KEEPALIVE", 5: "ROUTE-REFRESH"}
+	icmpTypes = map[uint8]string{
+		0: "echo-reply", 3: "dest-unreachable", 5: "redirect", 8: "echo-request", 11: "time-exceeded",
+	}
+	icmp6Types = map[uint8]string{
+		1: "dest-unreachable", 3: "time-exceeded", 128: "echo-request", 129: "echo-reply",
+		133: "router-solicitation", 134: "router-advertisement",
+		135: "neighbor-solicitation", 136: "neighbor-advertisement",
+	}
+)
+
+// Describe devuelve el protocolo de más arriba que se reconoce, los mensajes que
+// trae el paquete y una descripción corta
+func Describe(p *Packet) (string, []string, string) {
+	switch {
+	case p.EtherType == EtherTypeARP:
+		switch p.ARPOp {
+		case 1:
+			return "arp", []string{"request"}, fmt.Sprintf("who-has %s tell %s", p.Dst, p.Src)
+		case 2:
+			return "arp", []string{"reply"}, fmt.Sprintf("%s is-at (to %s)", p.Src, p.Dst)
+		}
+		return "arp", nil, ""
+	case p.EtherType == EtherTypeLLDP:
+		return "lldp", nil, ""
+	case !p.IsIP():
+		return "other", nil, fmt.Sprintf("ethertype 0x%04x", p.EtherType)
+	case p.Fragment:
+		return "ip", nil, fmt.Sprintf("fragment (proto %d)", p.Proto)
+	}
+
+	switch p.Proto {
+	case ProtoICMP, ProtoICMPv6:
+		names, proto := icmpTypes, "icmp"
+		if p.Proto == ProtoICMPv6 {
+			names, proto = icmp6Types, "icmp6"
+		}
+		name, ok := names[p.ICMPType]
+		if !ok {
+			return proto, []string{fmt.Sprintf("type-%d", p.ICMPType)}, fmt.Sprintf("type %d code %d", p.ICMPType, p.ICMPCode)
+		}
+		return proto, []string{name}, name
+	case ProtoOSPF:
+		return describeOSPF(p.Payload)
+	case ProtoTCP:
+		if (p.SrcPort == 179 || p.DstPort == 179) && len(p.Payload) > 0 {
+			if types, info := describeBGP(p.Payload); len(types) > 0 {
+				return "bgp", types, info
+			}
+		}
+		return "tcp", nil, fmt.Sprintf("%d → %d [%s]", p.SrcPort, p.DstPort, tcpFlags(p.TCPFlags))
+	case ProtoUDP:
+		return "udp", nil, fmt.Sprintf("%d → %d", p.SrcPort, p.DstPort)
+	case ProtoVRRP:
+		return "vrrp", nil, ""
+	case ProtoIGMP:
+		return "igmp", nil, ""
+	}
+	return "ip", nil, fmt.Sprintf("proto %d", p.Proto)
+}
+
+// describeOSPF lee la cabecera común de OSPFv2/v3: versión, tipo, router ID y área
+func describeOSPF(data []byte) (string, []string, string) {
+	if len(data) < 12 {
+		return "ospf", nil, "truncated"
+	}
+	name, ok := ospfTypes[data[1]]
+	if !ok {
+		name = fmt.Sprintf("type-%d", data[1])
+	}
+	routerID := netip.AddrFrom4([4]byte(data[4:8]))
+	area := netip.AddrFrom4([4]byte(data[8:12]))
+	return "ospf", []string{name}, fmt.Sprintf("OSPFv%d %s router %s area %s", data[0], name, routerID, area)
+}
+
+// describeBGP recorre los mensajes BGP que empiezan en el segmento. Un mensaje
+// partido entre segmentos solo se cuenta en el segmento donde empieza.
+func describeBGP(data []byte) ([]string, string) {
+	var types, infos []string
+	for len(data) >= 19 {
+		for _, b := range data[:16] {
+			if b != 0xFF {
+				return types, strings.Join(infos, ", ")
+			}
+		}
+		length := int(binary.BigEndian.Uint16(data[16:18]))
+		if length < 19 {
+			break
+		}
+		msg := data[19:min(length, len(data))]
+		name, ok := bgpTypes[data[18]]
+		if !ok {
+			name = fmt.Sprintf("type-%d", data[18])
+		}
+		types = append(types, name)
+		infos = append(infos, bgpInfo(data[18], name, msg, len(msg) == length-19))
+
+		if length > len(data) {
+			break
+		}
+		data = data[length:]
+	}
+	return types, strings.Join(infos, ", ")
+}
+
+// bgpInfo describe un mensaje: AS e ID del OPEN, prefijos del UPDATE, código de la NOTIFICATION
+func bgpInfo(kind byte, name string, msg []byte, complete bool) string {
+	switch kind {
+	case 1:
+		if len(msg) >= 10 {
+			as := binary.BigEndian.Uint16(msg[1:3])
+			hold := binary.BigEndian.Uint16(msg[3:5])
+			id := netip.AddrFrom4([4]byte(msg[5:9]))
+			return fmt.Sprintf("OPEN AS %d id %s hold %ds", as, id, hold)
+		}
+	case 2:
+		if complete {
+			if withdrawn, announced, ok := updatePrefixes(msg); ok {
+				return fmt.Sprintf("UPDATE +%d -%d", announced, withdrawn)
+			}
+		}
+	case 3:
+		if len(msg) >= 2 {
+			return fmt.Sprintf("NOTIFICATION %d/%d", msg[0], msg[1])
+		}
+	}
+	return name
+}
+
+// updatePrefixes cuenta los prefijos IPv4 retirados y anunciados de un UPDATE
+// (los de MP-BGP viajan en los atributos y no se cuentan)
+func updatePrefixes(msg []byte) (int, int, bool) {
+	if len(msg) < 4 {
+		return 0, 0, false
+	}
+	wLen := int(binary.BigEndian.Uint16(msg[0:2]))
+	if 2+wLen+2 > len(msg) {
+		return 0, 0, false
+	}
+	withdrawn, ok := countPrefixes(msg[2 : 2+wLen])
+	if !ok {
+		return 0, 0, false
+	}
+	aLen := int(binary.BigEndian.Uint16(msg[2+wLen : 4+wLen]))
+	if 4+wLen+aLen > len(msg) {
+		return 0, 0, false
+	}
+	announced, ok := countPrefixes(msg[4+wLen+aLen:])
+	return withdrawn, announced, ok
+}
+
+func countPrefixes(data []byte) (int, bool) {
+	n := 0
+	for len(data) > 0 {
+		size := 1 + (int(data[0])+7)/8
+		if data[0] > 32 || size > len(data) {
+			return n, false
+		}
+		data = data[size:]
+		n++
+	}
+	return n, true
+}
+
+func tcpFlags(f uint8) string {
+	var names []string
+	for i, name := range []string{"FIN", "SYN", "RST", "PSH", "ACK", "URG"} {
+		if f&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,67 @@
+package capture
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// bgpMessage arma un mensaje BGP con su marker y cabecera
+func bgpMessage(kind byte, body []byte) []byte {
+	msg := make([]byte, 19, 19+len(body))
+	for i := range 16 {
+		msg[i] = 0xFF
+	}
+	binary.BigEndian.PutUint16(msg[16:], uint16(19+len(body)))
+	msg[18] = kind
+	return append(msg, body...)
+}
+
+func TestSummarizer(t *testing.T) {
+	open := []byte{4, 0xFD, 0xE9, 0, 180, 1, 1, 1, 1, 0}  // AS 65001, hold 180, id 1.1.1.1
+	update := []byte{0, 0, 0, 0, 24, 10, 1, 1, 16, 10, 2} // Anuncia 10.1.1.0/24 y 10.2.0.0/16
+	segment := append(append(bgpMessage(1, open), bgpMessage(4, nil)...), bgpMessage(2, update)...)
+
+	ospfHello := make([]byte, 44)
+	ospfHello[0], ospfHello[1] = 2, 1
+	copy(ospfHello[4:], []byte{2, 2, 2, 2})
+
+	icmp := []byte{8, 0, 0, 0, 0, 1, 0, 1}
+
+	frames := [][]byte{
+		ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, ProtoTCP, append(tcpHeader(40000, 179), segment...)),
+		ipv4Frame([4]byte{10, 0, 0, 2}, [4]byte{224, 0, 0, 5}, ProtoOSPF, ospfHello),
+		ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, ProtoICMP, icmp),
+		ipv4Frame([4]byte{10, 0, 0, 1}, [4]byte{10, 0, 0, 2}, ProtoTCP, tcpHeader(40000, 179)),
+	}
+
+	s := NewSummarizer()
+	var infos []PacketInfo
+	for i, f := range frames {
+		infos = append(infos, s.Add(Record{Time: time.Unix(int64(i), 0), Data: f, OrigLen: len(f)}, "l1:source"))
+	}
+
+	if infos[0].Protocol != "bgp" || infos[0].Info != "OPEN AS 65001 id 1.1.1.1 hold 180s, KEEPALIVE, UPDATE +2 -0" {
+		t.Errorf("BGP mal descripto: %+v", infos[0])
+	}
+	if infos[1].Protocol != "ospf" || infos[1].Type != "Hello" || infos[1].Info != "OSPFv2 Hello router 2.2.2.2 area 0.0.0.0" {
+		t.Errorf("OSPF mal descripto: %+v", infos[1])
+	}
+	if infos[2].Protocol != "icmp" || infos[2].Type != "echo-request" {
+		t.Errorf("ICMP mal descripto: %+v", infos[2])
+	}
+	if infos[3].Protocol != "tcp" || infos[3].Info != "40000 → 179 [SYN]" {
+		t.Errorf("TCP mal descripto: %+v", infos[3])
+	}
+
+	bgp := s.Protocols["bgp"]
+	if s.Packets != 4 || bgp == nil || bgp.Packets != 1 || bgp.Types["OPEN"] != 1 || bgp.Types["KEEPALIVE"] != 1 || bgp.Types["UPDATE"] != 1 {
+		t.Errorf("resumen inesperado: %+v, bgp %+v", s.Summary, bgp)
+	}
+	if s.Protocols["ospf"].Types["Hello"] != 1 || s.Protocols["tcp"].Packets != 1 {
+		t.Errorf("conteos inesperados: %+v", s.Protocols)
+	}
+	if !s.Start.Equal(time.Unix(0, 0)) || !s.End.Equal(time.Unix(3, 0)) {
+		t.Errorf("rango de tiempo inesperado: %v - %v", s.Start, s.End)
+	}
+}
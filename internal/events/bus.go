@@ -0,0 +1,88 @@
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Type identifica la clase de evento publicado
+type Type string
+
+const (
+	NodeCreated    Type = "node.created"
+	NodeDeleted    Type = "node.deleted"
+	NodeState      Type = "node.state" // Cambio de estado del contenedor (Docker)
+	LinkCreated    Type = "link.created"
+	LinkDeleted    Type = "link.deleted"
+	LinkUp         Type = "link.up" // Interfaz operativa (netlink)
+	LinkDown       Type = "link.down"
+	AddressAdded   Type = "address.added"
+	AddressRemoved Type = "address.removed"
+	DeployProgress Type = "deploy.progress" // Un elemento de un deploy/apply terminó
+)
+
+// Origen de un evento
+const (
+	SourceAPI    = "api"
+	SourceDocker = "docker"
+	SourceKernel = "kernel"
+)
+
+// Event es un cambio de topología o de runtime enviado a los clientes suscritos
+type Event struct {
+	Type       Type        `json:"type"`
+	Source     string      `json:"source"`
+	TopologyID string      `json:"topology_id,omitempty"`
+	NodeID     string      `json:"node_id,omitempty"`
+	LinkID     string      `json:"link_id,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	Time       time.Time   `json:"time"`
+}
+
+// Bus distribuye eventos a todos los suscriptores (fan-out en memoria)
+type Bus struct {
+	mu   sync.RWMutex
+	subs map[chan Event]struct{}
+}
+
+// NewBus crea un bus sin suscriptores
+func NewBus() *Bus {
+	return &Bus{subs: make(map[chan Event]struct{})}
+}
+
+// Publish envía el evento a cada suscriptor sin bloquear.
+// Un cliente lento pierde eventos en vez de frenar al orquestador.
+func (b *Bus) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// Subscribe registra un suscriptor con un buffer de tamaño buffer.
+// La función devuelta lo da de baja y cierra el canal.
+func (b *Bus) Subscribe(buffer int) (<-chan Event, func()) {
+	ch := make(chan Event, buffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+}
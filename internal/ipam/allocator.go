@@ -0,0 +1,122 @@
+package ipam
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Pools por defecto cuando el laboratorio no define los suyos
+const (
+	DefaultP2PPoolV4    = "10.100.0.0/16"
+	DefaultP2PPoolV6    = "fd00:100::/64"
+	DefaultLoopbackPool = "10.200.0.0/24"
+)
+
+// Allocator reparte subredes de un pool evitando las que ya están en uso
+// (asignaciones previas o direcciones configuradas a mano).
+type Allocator struct {
+	pool netip.Prefix
+	used []netip.Prefix
+}
+
+// NewAllocator crea un allocator sobre pool. used acepta direcciones en CIDR
+// (10.0.0.1/30) y se interpreta como la subred completa que ocupan.
+func NewAllocator(pool string, used []string) (*Allocator, error) {
+	p, err := netip.ParsePrefix(pool)
+	if err != nil {
+		return nil, fmt.Errorf("pool inválido %s: %v", pool, err)
+	}
+
+	a := &Allocator{pool: p.Masked()}
+	for _, u := range used {
+		if err := a.Reserve(u); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Reserve marca una dirección o subred como ocupada
+func (a *Allocator) Reserve(cidr string) error {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return fmt.Errorf("dirección inválida %s: %v", cidr, err)
+	}
+	a.used = append(a.used, p.Masked())
+	return nil
+}
+
+// Next devuelve la primera subred libre de longitud bits dentro del pool y la reserva
+func (a *Allocator) Next(bits int) (netip.Prefix, error) {
+	if bits < a.pool.Bits() || bits > a.pool.Addr().BitLen() {
+		return netip.Prefix{}, fmt.Errorf("no se pueden asignar /%d desde %s", bits, a.pool)
+	}
+
+	candidate := netip.PrefixFrom(a.pool.Addr(), bits)
+	for a.pool.Contains(candidate.Addr()) {
+		if conflict, ok := a.conflict(candidate); ok {
+			// Saltar al final del bloque ocupado si es mayor que el candidato
+			last := lastAddr(conflict)
+			if conflict.Bits() > bits {
+				last = lastAddr(candidate)
+			}
+			next := last.Next()
+			if !next.IsValid() {
+				break
+			}
+			candidate = netip.PrefixFrom(next, bits)
+			continue
+		}
+
+		a.used = append(a.used, candidate)
+		return candidate, nil
+	}
+
+	return netip.Prefix{}, fmt.Errorf("pool %s agotado para subredes /%d", a.pool, bits)
+}
+
+// conflict devuelve la primera subred usada que se solapa con p
+func (a *Allocator) conflict(p netip.Prefix) (netip.Prefix, bool) {
+	for _, u := range a.used {
+		if u.Overlaps(p) {
+			return u, true
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// lastAddr calcula la última dirección de una subred
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Masked().Addr().AsSlice()
+	hostBits := len(b)*8 - p.Bits()
+	for i := len(b) - 1; i >= 0 && hostBits > 0; i-- {
+		if hostBits >= 8 {
+			b[i] = 0xff
+			hostBits -= 8
+		} else {
+			b[i] |= byte(1<<hostBits - 1)
+			hostBits = 0
+		}
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// PointToPoint devuelve las dos direcciones de host (en CIDR) de una subred punto a punto.
+// En /30 se evitan red y broadcast; en /31 y /127 (RFC 3021 / RFC 6164) se usan ambas.
+func PointToPoint(subnet netip.Prefix) (string, string, error) {
+	first := subnet.Masked().Addr()
+	hostBits := first.BitLen() - subnet.Bits()
+
+	switch {
+	case hostBits == 1:
+		// /31 o /127
+	case first.Is4() && hostBits == 2:
+		first = first.Next()
+	default:
+		return "", "", fmt.Errorf("%s no es una subred punto a punto", subnet)
+	}
+
+	second := first.Next()
+	return netip.PrefixFrom(first, subnet.Bits()).String(), netip.PrefixFrom(second, subnet.Bits()).String(), nil
+}
@@ -0,0 +1,68 @@
+package ipam
+
+import "testing"
+
+// TestNextSkipsUsed verifica que el allocator evita asignaciones previas y direcciones manuales
+func TestNextSkipsUsed(t *testing.T) {
+	// 10.0.0.1/30 ocupa 10.0.0.0/30; la dirección manual 10.0.0.9/24 invade todo el /24
+	alloc, err := NewAllocator("10.0.0.0/16", []string{"10.0.0.1/30"})
+	if err != nil {
+		t.Fatalf("Error creando allocator: %v", err)
+	}
+
+	got, err := alloc.Next(30)
+	if err != nil {
+		t.Fatalf("Next falló: %v", err)
+	}
+	if got.String() != "10.0.0.4/30" {
+		t.Errorf("Se esperaba 10.0.0.4/30, se obtuvo %s", got)
+	}
+
+	if err := alloc.Reserve("10.0.0.9/24"); err != nil {
+		t.Fatalf("Reserve falló: %v", err)
+	}
+	got, _ = alloc.Next(30)
+	if got.String() != "10.0.1.0/30" {
+		t.Errorf("Se esperaba 10.0.1.0/30 tras el conflicto manual, se obtuvo %s", got)
+	}
+}
+
+// TestNextExhausted verifica el error cuando el pool se agota
+func TestNextExhausted(t *testing.T) {
+	alloc, _ := NewAllocator("192.168.0.0/30", nil)
+	if _, err := alloc.Next(31); err != nil {
+		t.Fatalf("Primer /31 falló: %v", err)
+	}
+	if _, err := alloc.Next(31); err != nil {
+		t.Fatalf("Segundo /31 falló: %v", err)
+	}
+	if _, err := alloc.Next(31); err == nil {
+		t.Errorf("Se esperaba error de pool agotado")
+	}
+}
+
+// TestPointToPoint verifica las direcciones de host de cada tipo de subred
+func TestPointToPoint(t *testing.T) {
+	cases := map[string][2]string{
+		"10.0.0.4/30":  {"10.0.0.5/30", "10.0.0.6/30"},
+		"10.0.0.4/31":  {"10.0.0.4/31", "10.0.0.5/31"},
+		"fd00::10/127": {"fd00::10/127", "fd00::11/127"},
+	}
+	for subnet, want := range cases {
+		alloc, _ := NewAllocator(subnet, nil)
+		p, _ := alloc.Next(alloc.pool.Bits())
+		a, b, err := PointToPoint(p)
+		if err != nil {
+			t.Fatalf("%s: %v", subnet, err)
+		}
+		if a != want[0] || b != want[1] {
+			t.Errorf("%s: se esperaba %v, se obtuvo [%s %s]", subnet, want, a, b)
+		}
+	}
+
+	alloc, _ := NewAllocator("10.0.0.0/29", nil)
+	p, _ := alloc.Next(29)
+	if _, _, err := PointToPoint(p); err == nil {
+		t.Errorf("Se esperaba error para una subred /29")
+	}
+}
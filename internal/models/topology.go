@@ -1,5 +1,13 @@
 package models
 
+import (
+	"fmt"
+	"maps"
+	"path"
+	"slices"
+	"time"
+)
+
 // NodeType define el tipo de dispositivo (router, switch, host)
 type NodeType string
 
@@ -9,9 +17,32 @@ const (
 	HOST   NodeType = "host"   // Usa imagen Alpine/Ubuntu
 )
 
+// RuntimeKind indica qué ejecuta los procesos de un nodo
+type RuntimeKind string
+
+const (
+	RuntimeDocker    RuntimeKind = "docker" // Un contenedor por nodo (por defecto)
+	RuntimeNamespace RuntimeKind = "netns"  // Solo un network namespace con nombre y procesos del host
+)
+
+// NodeStatus es el estado de ejecución de un nodo, tal como lo ve el orquestador
+type NodeStatus string
+
+const (
+	StatusRunning NodeStatus = "running"
+	StatusStopped NodeStatus = "stopped" // El contenedor existe pero no corre
+	StatusPaused  NodeStatus = "paused"  // Procesos congelados, links intactos
+	StatusMissing NodeStatus = "missing" // El contenedor/bridge ya no existe
+	StatusError   NodeStatus = "error"
+)
+
+// DefaultTopologyID agrupa los nodos creados sin laboratorio explícito
+const DefaultTopologyID = "default"
+
 // Node representa un dispositivo en la red
 type Node struct {
 	ID          string   `json:"id" gorm:"primaryKey"`
+	TopologyID  string   `json:"topology_id" gorm:"index"`
 	Name        string   `json:"name"`
 	Type        NodeType `json:"type"`
 	Image       string   `json:"image"`
@@ -19,15 +50,101 @@ type Node struct {
 	RAMLimit    string   `json:"ram_limit"`
 	X           float64  `json:"x"` // Canvas position
 	Y           float64  `json:"y"` // Canvas position
+
+	// Runtime: contenedor (por defecto) o namespace. Command solo aplica a netns:
+	// proceso del host que corre dentro del namespace (p.ej. bird o los daemons de FRR)
+	Runtime RuntimeKind `json:"runtime,omitempty"`
+	Command []string    `json:"command,omitempty" gorm:"serializer:json"`
+
+	// Configuración inicial (se persiste como JSON junto al nodo)
+	Startup StartupConfig `json:"startup" gorm:"serializer:json"`
 	
 	// Internal state
-	ContainerID string `json:"container_id"`
-	PID         int    `json:"pid"`
+	ContainerID string     `json:"container_id"`
+	PID         int        `json:"pid"`
+	Status      NodeStatus `json:"status"`
 	
 	// Runtime Info (Not persisted in DB)
 	Interfaces []InterfaceInfo `json:"interfaces" gorm:"-"`
 }
 
+// RuntimeKind devuelve el runtime del nodo, con Docker cuando no se indicó ninguno
+func (n Node) RuntimeKind() RuntimeKind {
+	if n.Runtime == "" {
+		return RuntimeDocker
+	}
+	return n.Runtime
+}
+
+// ValidateRuntime verifica que el runtime admita el tipo y la configuración del nodo.
+// Un nodo netns comparte el sistema de archivos del host: no lleva imagen, límites
+// de recursos ni archivos de arranque, solo comandos.
+func (n Node) ValidateRuntime() error {
+	switch n.RuntimeKind() {
+	case RuntimeDocker:
+		if len(n.Command) > 0 {
+			return fmt.Errorf("command is only valid for netns nodes")
+		}
+		return nil
+	case RuntimeNamespace:
+	default:
+		return fmt.Errorf("unknown runtime %q (docker, netns)", n.Runtime)
+	}
+
+	switch {
+	case n.Type == SWITCH:
+		return fmt.Errorf("switch nodes are bridges and take no runtime")
+	case n.Image != "":
+		return fmt.Errorf("netns nodes run host binaries and take no image")
+	case n.CPURequest != "" || n.RAMLimit != "":
+		return fmt.Errorf("netns nodes do not support cpu or ram limits")
+	case n.Startup.FRRConf != "" || n.Startup.Daemons != "" || len(n.Startup.Files) > 0:
+		return fmt.Errorf("netns nodes share the host filesystem: only startup commands are supported")
+	}
+	return nil
+}
+
+// StartupConfig es la configuración que se inyecta en el contenedor al crearlo
+type StartupConfig struct {
+	FRRConf  string            `json:"frr_conf,omitempty"` // /etc/frr/frr.conf (solo routers)
+	Daemons  string            `json:"daemons,omitempty"`  // /etc/frr/daemons (solo routers)
+	Files    map[string]string `json:"files,omitempty"`    // Ruta absoluta -> contenido
+	Commands []string          `json:"commands,omitempty"` // Se ejecutan con sh -c tras el arranque
+}
+
+// IsZero indica si el nodo no tiene configuración inicial
+func (s StartupConfig) IsZero() bool {
+	return s.FRRConf == "" && s.Daemons == "" && len(s.Files) == 0 && len(s.Commands) == 0
+}
+
+// Equal compara dos configuraciones (nil y vacío se consideran iguales)
+func (s StartupConfig) Equal(o StartupConfig) bool {
+	return s.FRRConf == o.FRRConf && s.Daemons == o.Daemons &&
+		maps.Equal(s.Files, o.Files) && slices.Equal(s.Commands, o.Commands)
+}
+
+// Validate verifica que la configuración sea aplicable al tipo de nodo
+func (s StartupConfig) Validate(t NodeType) error {
+	if s.IsZero() {
+		return nil
+	}
+	if t == SWITCH {
+		return fmt.Errorf("switch nodes have no container to configure")
+	}
+	if (s.FRRConf != "" || s.Daemons != "") && t != ROUTER {
+		return fmt.Errorf("frr_conf and daemons are only valid for routers")
+	}
+	for p := range s.Files {
+		if !path.IsAbs(p) || path.Clean(p) != p || p == "/" {
+			return fmt.Errorf("invalid file path %q: must be absolute and clean", p)
+		}
+		if (p == "/etc/frr/frr.conf" && s.FRRConf != "") || (p == "/etc/frr/daemons" && s.Daemons != "") {
+			return fmt.Errorf("file %s is already set by frr_conf/daemons", p)
+		}
+	}
+	return nil
+}
+
 // InterfaceInfo maps the output of 'ip -j addr'
 type InterfaceInfo struct {
 	Name        string      `json:"ifname"`
@@ -39,19 +156,216 @@ type IPAddress struct {
 	Prefix  int    `json:"prefixlen"`
 }
 
+// AddressSource indica quién asignó una dirección
+type AddressSource string
+
+const (
+	AddressManual AddressSource = "manual" // Vía API por el usuario
+	AddressIPAM   AddressSource = "ipam"   // Asignada automáticamente desde un pool del lab
+)
+
+// IPAMMode selecciona el direccionamiento automático de un link punto a punto
+type IPAMMode string
+
+const (
+	IPAMNone     IPAMMode = ""
+	IPAMv4Net30  IPAMMode = "ipv4/30"
+	IPAMv4Net31  IPAMMode = "ipv4/31"
+	IPAMv6Net127 IPAMMode = "ipv6/127"
+)
+
+// PrefixLen devuelve la longitud de subred del modo y si es un modo válido
+func (m IPAMMode) PrefixLen() (int, bool) {
+	switch m {
+	case IPAMv4Net30:
+		return 30, true
+	case IPAMv4Net31:
+		return 31, true
+	case IPAMv6Net127:
+		return 127, true
+	}
+	return 0, false
+}
+
+// InterfaceAddress es una IP asignada a la interfaz de un nodo.
+// Se persiste para volver a aplicarla cuando el nodo se recrea.
+type InterfaceAddress struct {
+	ID        uint          `json:"id" gorm:"primaryKey"`
+	NodeID    string        `json:"node_id" gorm:"index"`
+	Interface string        `json:"interface"`
+	Address   string        `json:"address"` // CIDR: 10.0.0.1/30, 2001:db8::1/64
+	Source    AddressSource `json:"source"`
+	LinkID    string        `json:"link_id,omitempty" gorm:"index"` // Link que originó la asignación (IPAM)
+
+	// Runtime Info (Not persisted in DB)
+	Applied bool `json:"applied" gorm:"-"` // Presente en el kernel
+}
+
+// ConfigSnapshot es una versión guardada de la running-config de un router.
+// Sobrevive al borrado del nodo y del laboratorio.
+type ConfigSnapshot struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	TopologyID string    `json:"topology_id" gorm:"index"`
+	NodeID     string    `json:"node_id" gorm:"index"`
+	NodeName   string    `json:"node_name"`
+	Version    int       `json:"version"` // Correlativo por nodo, empieza en 1
+	Config     string    `json:"config"`
+	Note       string    `json:"note,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// TerminalRecording describe una sesión de terminal grabada en asciicast v2.
+// El contenido vive en un archivo; como los snapshots, sobrevive al nodo.
+type TerminalRecording struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	TopologyID  string     `json:"topology_id" gorm:"index"`
+	NodeID      string     `json:"node_id" gorm:"index"`
+	NodeName    string     `json:"node_name"`
+	User        string     `json:"user" gorm:"column:username;index"` // Quién abrió la terminal (?user=)
+	Shell       string     `json:"shell"`
+	Cols        uint       `json:"cols"` // Tamaño inicial
+	Rows        uint       `json:"rows"`
+	File        string     `json:"-"` // Ruta del .cast en disco
+	Size        int64      `json:"size"`
+	DurationSec float64    `json:"duration_sec"`
+	StartedAt   time.Time  `json:"started_at"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"` // nil = sesión en curso
+}
+
+// CaptureStatus es el estado de una sesión de captura
+type CaptureStatus string
+
+const (
+	CaptureRunning CaptureStatus = "running"
+	CaptureStopped CaptureStatus = "stopped"
+	CaptureFailed  CaptureStatus = "failed"
+)
+
+// CaptureTarget es un extremo de link capturado por una sesión
+type CaptureTarget struct {
+	LinkID string `json:"link_id"`
+	End    string `json:"end"` // source | target
+
+	// Se completan al arrancar y al terminar la captura
+	NodeName  string `json:"node_name,omitempty"`
+	Interface string `json:"interface,omitempty"`
+	Packets   uint64 `json:"packets"`
+	Bytes     uint64 `json:"bytes"`
+	Dropped   uint64 `json:"dropped"` // Descartados por el kernel (buffer lleno)
+	Error     string `json:"error,omitempty"`
+}
+
+// CaptureSession es una captura con nombre sobre uno o más links, guardada en
+// archivos pcapng que rotan. Como las grabaciones, sobrevive al laboratorio.
+type CaptureSession struct {
+	ID             uint            `json:"id" gorm:"primaryKey"`
+	TopologyID     string          `json:"topology_id" gorm:"index"`
+	Name           string          `json:"name"`
+	Filter         string          `json:"filter,omitempty"`
+	Snaplen        int             `json:"snaplen"`
+	Targets        []CaptureTarget `json:"targets" gorm:"serializer:json"`
+	MaxFileSize    int64           `json:"max_file_size,omitempty"`    // Bytes por archivo antes de rotar (0 = sin rotación)
+	MaxFiles       int             `json:"max_files,omitempty"`        // Archivos que se conservan por extremo (0 = todos)
+	MaxDurationSec float64         `json:"max_duration_sec,omitempty"` // 0 = hasta que se detenga
+	MaxPackets     uint64          `json:"max_packets,omitempty"`      // Por extremo, 0 = sin límite
+	Dir            string          `json:"-"`                          // Directorio de los .pcapng
+	Status         CaptureStatus   `json:"status"`
+	Error          string          `json:"error,omitempty"`
+	StartedAt      time.Time       `json:"started_at"`
+	StoppedAt      *time.Time      `json:"stopped_at,omitempty"`
+}
+
 // Link representa un cable virtual (veth pair) entre dos nodos
 type Link struct {
-	ID        string `json:"id" gorm:"primaryKey"`
-	SourceID  string `json:"source"`
-	TargetID  string `json:"target"`
-	SourceInt string `json:"source_int"`
-	TargetInt string `json:"target_int"`
+	ID         string `json:"id" gorm:"primaryKey"`
+	TopologyID string `json:"topology_id" gorm:"index"`
+	SourceID   string `json:"source"`
+	TargetID   string `json:"target"`
+	SourceInt  string `json:"source_int"`
+	TargetInt  string `json:"target_int"`
+
+	// Direccionamiento automático desde el pool del laboratorio
+	IPAM IPAMMode `json:"ipam,omitempty"`
+
+	// Emulación WAN (se aplica con tc en ambos extremos)
+	Impairment Impairment `json:"impairment" gorm:"embedded;embeddedPrefix:imp_"`
+}
+
+// Impairment define la degradación de un link. Los valores aplican por sentido:
+// cada extremo del veth encola sus paquetes de salida con netem (y tbf si hay Rate).
+type Impairment struct {
+	Delay     uint32  `json:"delay_ms"`      // Latencia en milisegundos
+	Jitter    uint32  `json:"jitter_ms"`     // Variación de la latencia (requiere Delay)
+	Loss      float32 `json:"loss_pct"`      // Pérdida de paquetes (%)
+	Duplicate float32 `json:"duplicate_pct"` // Duplicación (%)
+	Reorder   float32 `json:"reorder_pct"`   // Reordenamiento (%, requiere Delay)
+	Corrupt   float32 `json:"corrupt_pct"`   // Corrupción de bits (%)
+	Rate      uint64  `json:"rate_kbit"`     // Límite de ancho de banda (kbit/s)
+}
+
+// Límites de Impairment: con ellos los campos de tc calculados (latencia en us,
+// burst y cola del tbf en bytes) entran en un uint32
+const (
+	MaxImpairmentDelay = 60000       // ms (1 minuto)
+	MaxImpairmentRate  = 100_000_000 // kbit/s (100 Gbit/s)
+)
+
+// IsZero indica si el link no tiene ninguna degradación configurada
+func (i Impairment) IsZero() bool {
+	return i == Impairment{}
+}
+
+// Validate verifica que los valores sean aplicables por netem
+func (i Impairment) Validate() error {
+	// En orden fijo: con varios campos mal, el error es siempre el mismo
+	pcts := []struct {
+		name string
+		v    float32
+	}{
+		{"loss_pct", i.Loss},
+		{"duplicate_pct", i.Duplicate},
+		{"reorder_pct", i.Reorder},
+		{"corrupt_pct", i.Corrupt},
+	}
+	for _, p := range pcts {
+		if p.v < 0 || p.v > 100 {
+			return fmt.Errorf("%s must be between 0 and 100", p.name)
+		}
+	}
+	if i.Delay > MaxImpairmentDelay {
+		return fmt.Errorf("delay_ms must be at most %d", MaxImpairmentDelay)
+	}
+	if i.Jitter > 0 && i.Delay == 0 {
+		return fmt.Errorf("jitter_ms requires delay_ms")
+	}
+	if i.Jitter > i.Delay {
+		return fmt.Errorf("jitter_ms must not exceed delay_ms")
+	}
+	if i.Reorder > 0 && i.Delay == 0 {
+		return fmt.Errorf("reorder_pct requires delay_ms")
+	}
+	if i.Rate > MaxImpairmentRate {
+		return fmt.Errorf("rate_kbit must be at most %d", MaxImpairmentRate)
+	}
+	return nil
 }
 
 // Topology es el objeto que engloba un laboratorio completo
 type Topology struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Nodes []Node `json:"nodes"`
-	Links []Link `json:"links"`
+	ID        string    `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// Pools de IPAM (vacío = pools por defecto)
+	P2PPoolV4    string `json:"p2p_pool_v4,omitempty"`
+	P2PPoolV6    string `json:"p2p_pool_v6,omitempty"`
+	LoopbackPool string `json:"loopback_pool,omitempty"`
+
+	// Presupuesto total de recursos del lab (vacío = sin límite)
+	CPUBudget string `json:"cpu_budget,omitempty"` // CPUs, ej: "4"
+	RAMBudget string `json:"ram_budget,omitempty"` // Tamaño, ej: "8g"
+
+	// Contenido del laboratorio (se persiste en sus propias tablas)
+	Nodes []Node `json:"nodes" gorm:"-"`
+	Links []Link `json:"links" gorm:"-"`
 }
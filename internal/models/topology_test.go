@@ -0,0 +1,35 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+func TestImpairmentValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		imp  Impairment
+		ok   bool
+	}{
+		{"vacío", Impairment{}, true},
+		{"delay máximo", Impairment{Delay: MaxImpairmentDelay}, true},
+		{"delay excedido", Impairment{Delay: MaxImpairmentDelay + 1}, false},
+		{"delay que desborda uint32 en us", Impairment{Delay: math.MaxUint32/1000 + 1}, false},
+		{"jitter igual al delay", Impairment{Delay: 100, Jitter: 100}, true},
+		{"jitter mayor al delay", Impairment{Delay: 100, Jitter: 101}, false},
+		{"jitter sin delay", Impairment{Jitter: 10}, false},
+		{"rate máximo", Impairment{Rate: MaxImpairmentRate}, true},
+		{"rate excedido", Impairment{Rate: MaxImpairmentRate + 1}, false},
+		{"rate enorme", Impairment{Rate: math.MaxUint64}, false},
+		{"pérdida 100%", Impairment{Loss: 100}, true},
+		{"pérdida negativa", Impairment{Loss: -1}, false},
+		{"reorder sin delay", Impairment{Reorder: 10}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.imp.Validate(); (err == nil) != tt.ok {
+				t.Errorf("Validate() = %v, se esperaba ok=%v", err, tt.ok)
+			}
+		})
+	}
+}
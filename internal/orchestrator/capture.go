@@ -0,0 +1,148 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"open-veth/internal/capture"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// Parámetros del socket de captura
+const (
+	captureReadTimeout = 250 * 1000      // µs entre chequeos del contexto
+	captureRcvBuf      = 4 * 1024 * 1024 // Colchón para ráfagas (OSPF/BGP al levantar un lab)
+)
+
+// PacketSource entrega los paquetes que pasan por una interfaz
+type PacketSource interface {
+	// ReadPacket espera el próximo paquete hasta que se cancele el contexto.
+	// Devuelve los bytes copiados en buf, el largo original y el sentido del paquete.
+	ReadPacket(ctx context.Context, buf []byte) (int, int, capture.Direction, error)
+	// Dropped devuelve los paquetes descartados desde la llamada anterior
+	Dropped() (uint64, error)
+	Close() error
+}
+
+// PacketSocket es un socket AF_PACKET atado a una interfaz dentro del namespace de un nodo.
+// El socket queda en ese namespace aunque el hilo vuelva al original.
+type PacketSocket struct {
+	fd    int
+	Iface string
+}
+
+var _ PacketSource = (*PacketSocket)(nil)
+
+// OpenPacketSocket abre un socket de captura en una interfaz del namespace (PID, o
+// HostPID para los puertos de los bridges).
+// Si el filtro trae BPF compilado se carga en el kernel antes de empezar a recibir.
+func (nm *NetworkManager) OpenPacketSocket(pid int, ifaceName string, filter *capture.Filter) (PacketSource, error) {
+	return nm.kernel.OpenPacketSocket(pid, ifaceName, filter)
+}
+
+// OpenPacketSocket abre un socket AF_PACKET dentro del namespace
+func (k netlinkKernel) OpenPacketSocket(pid int, ifaceName string, filter *capture.Filter) (PacketSource, error) {
+	ps := &PacketSocket{fd: -1, Iface: ifaceName}
+	err := k.do(pid, func() error {
+		link, err := netlink.LinkByName(ifaceName)
+		if err != nil {
+			return fmt.Errorf("interfaz %s no encontrada: %v", ifaceName, err)
+		}
+
+		// Protocolo 0: no recibe nada hasta el bind, así no se cuelan paquetes de
+		// otras interfaces ni paquetes que el filtro descartaría
+		fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+		if err != nil {
+			return fmt.Errorf("error abriendo socket de captura: %v", err)
+		}
+		ps.fd = fd
+
+		if prog := filter.Kernel(); len(prog) > 0 {
+			if err := ps.attachFilter(prog); err != nil {
+				return err
+			}
+		}
+		_ = unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF, captureRcvBuf)
+		tv := unix.Timeval{Usec: captureReadTimeout}
+		if err := unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &tv); err != nil {
+			return fmt.Errorf("error configurando socket de captura: %v", err)
+		}
+
+		addr := &unix.SockaddrLinklayer{Protocol: htons(unix.ETH_P_ALL), Ifindex: link.Attrs().Index}
+		if err := unix.Bind(fd, addr); err != nil {
+			return fmt.Errorf("error asociando socket a %s: %v", ifaceName, err)
+		}
+		return nil
+	})
+	if err != nil {
+		ps.Close()
+		return nil, err
+	}
+	return ps, nil
+}
+
+// attachFilter carga un programa BPF clásico en el socket
+func (ps *PacketSocket) attachFilter(prog []capture.Instruction) error {
+	filters := make([]unix.SockFilter, len(prog))
+	for i, ins := range prog {
+		filters[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+	fprog := unix.SockFprog{Len: uint16(len(filters)), Filter: &filters[0]}
+	if err := unix.SetsockoptSockFprog(ps.fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, &fprog); err != nil {
+		return fmt.Errorf("el kernel rechazó el filtro BPF: %v", err)
+	}
+	return nil
+}
+
+// ReadPacket espera el próximo paquete hasta que se cancele el contexto.
+// Devuelve los bytes copiados en buf, el largo original y el sentido del paquete.
+func (ps *PacketSocket) ReadPacket(ctx context.Context, buf []byte) (int, int, capture.Direction, error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, 0, capture.DirUnknown, err
+		}
+
+		// Con MSG_TRUNC el kernel devuelve el largo real aunque no entre en buf
+		origLen, from, err := unix.Recvfrom(ps.fd, buf, unix.MSG_TRUNC)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+			return 0, 0, capture.DirUnknown, fmt.Errorf("error leyendo paquete en %s: %v", ps.Iface, err)
+		}
+
+		dir := capture.DirInbound
+		if ll, ok := from.(*unix.SockaddrLinklayer); ok && ll.Pkttype == unix.PACKET_OUTGOING {
+			dir = capture.DirOutbound
+		}
+		return min(origLen, len(buf)), origLen, dir, nil
+	}
+}
+
+// Dropped devuelve los paquetes que el kernel descartó porque el buffer del socket
+// estaba lleno desde la llamada anterior (PACKET_STATISTICS se reinicia al leerse)
+func (ps *PacketSocket) Dropped() (uint64, error) {
+	stats, err := unix.GetsockoptTpacketStats(ps.fd, unix.SOL_PACKET, unix.PACKET_STATISTICS)
+	if err != nil {
+		return 0, fmt.Errorf("error leyendo estadísticas de %s: %v", ps.Iface, err)
+	}
+	return uint64(stats.Drops), nil
+}
+
+// Close cierra el socket (idempotente)
+func (ps *PacketSocket) Close() error {
+	if ps == nil || ps.fd < 0 {
+		return nil
+	}
+	err := unix.Close(ps.fd)
+	ps.fd = -1
+	return err
+}
+
+// htons convierte un ethertype al orden de red que esperan los sockets AF_PACKET
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
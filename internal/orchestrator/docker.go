@@ -8,8 +8,6 @@ import (
 
 	"io"
 
-	"bytes"
-
 	"encoding/json"
 
 	"time"
@@ -22,12 +20,12 @@ import (
 
 	"github.com/docker/docker/api/types/container"
 
+	"github.com/docker/docker/api/types/filters"
+
 	"github.com/docker/docker/api/types/image"
 
 	"github.com/docker/docker/client"
 
-	"github.com/docker/docker/pkg/stdcopy"
-
 )
 
 
@@ -46,61 +44,13 @@ type Manager struct {
 
 func (m *Manager) GetNodeInterfaces(ctx context.Context, containerID string) ([]models.InterfaceInfo, error) {
 
-	// 1. Create execution configuration
-
-	execConfig := container.ExecOptions{
-
-		Cmd:          []string{"ip", "-j", "addr"},
-
-		AttachStdout: true,
-
-		AttachStderr: true,
-
-	}
-
-
-
-	// Add timeout to prevent hanging if the container is unresponsive
-
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-
-	defer cancel()
-
-
-
-	// 2. Create the execution instance
-
-	execIDResp, err := m.cli.ContainerExecCreate(ctx, containerID, execConfig)
-
-	if err != nil {
-
-		return nil, fmt.Errorf("error creating exec: %v", err)
-
-	}
-
+	// Short timeout to prevent hanging if the container is unresponsive
 
-
-	// 3. Attach and execute (Attach gives us the streams)
-
-	resp, err := m.cli.ContainerExecAttach(ctx, execIDResp.ID, container.ExecStartOptions{})
+	res, err := m.execOK(ctx, containerID, []string{"ip", "-j", "addr"}, 5*time.Second)
 
 	if err != nil {
 
-		return nil, fmt.Errorf("error attaching to exec: %v", err)
-
-	}
-
-	defer resp.Close()
-
-
-
-	// 4. Read Stdout (separate from Stderr using stdcopy, Docker mixes streams with headers)
-
-	var outBuf, errBuf bytes.Buffer
-
-	if _, err := stdcopy.StdCopy(&outBuf, &errBuf, resp.Reader); err != nil {
-
-		return nil, fmt.Errorf("error reading exec output: %v", err)
+		return nil, fmt.Errorf("error running 'ip -j addr': %v", err)
 
 	}
 
@@ -108,21 +58,21 @@ func (m *Manager) GetNodeInterfaces(ctx context.Context, containerID string) ([]
 
 	// Log stderr warning if not critical
 
-	if errBuf.Len() > 0 {
+	if res.Stderr != "" {
 
-		fmt.Printf("Warning: 'ip -j addr' stderr: %s\n", errBuf.String())
+		fmt.Printf("Warning: 'ip -j addr' stderr: %s\n", res.Stderr)
 
 	}
 
 
 
-	// 5. Parse JSON
+	// Parse JSON
 
 	var interfaces []models.InterfaceInfo
 
-	if err := json.Unmarshal(outBuf.Bytes(), &interfaces); err != nil {
+	if err := json.Unmarshal([]byte(res.Stdout), &interfaces); err != nil {
 
-		return nil, fmt.Errorf("error parsing ip addr json: %v. Output: %s", err, outBuf.String())
+		return nil, fmt.Errorf("error parsing ip addr json: %v. Output: %s", err, res.Stdout)
 
 	}
 
@@ -154,21 +104,21 @@ func NewManager() (*Manager, error) {
 
 
 
-// GetDockerClient returns the internal Docker client
+// CreateNode creates and starts a container for a topology node
 
-func (m *Manager) GetDockerClient() *client.Client {
+func (m *Manager) CreateNode(ctx context.Context, node models.Node) (string, error) {
 
-	return m.cli
+	if node.RuntimeKind() != models.RuntimeDocker {
 
-}
+		return "", fmt.Errorf("node %s uses the %s runtime, not docker", node.Name, node.RuntimeKind())
 
+	}
 
 
-// CreateNode creates and starts a container for a topology node
 
-func (m *Manager) CreateNode(ctx context.Context, node models.Node) (string, error) {
+	name := ContainerName(node)
 
-	fmt.Printf("Orchestrating node: %s (Image: %s)...\n", node.Name, node.Image)
+	fmt.Printf("Orchestrating node: %s (Image: %s)...\n", name, node.Image)
 
 
 
@@ -220,21 +170,43 @@ func (m *Manager) CreateNode(ctx context.Context, node models.Node) (string, err
 
 			"openveth.name": node.Name,
 
+			"openveth.topology": node.TopologyID,
+
+			"openveth.spec": nodeSpec(node),
+
 		},
 
 	}
 
+	nanoCPUs, memory, err := NodeResources(node)
+
+	if err != nil {
+
+		return "", err
+
+	}
+
+
+
 	hostConfig := &container.HostConfig{
 
 		CapAdd: []string{"NET_ADMIN", "SYS_ADMIN"},
 
+		Resources: container.Resources{
+
+			NanoCPUs: nanoCPUs, // 0 = unlimited
+
+			Memory:   memory,
+
+		},
+
 	}
 
 
 
 	// 3. Create container (Conflict handling)
 
-	resp, err := m.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, node.Name)
+	resp, err := m.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, name)
 
 	if err != nil {
 
@@ -246,11 +218,19 @@ func (m *Manager) CreateNode(ctx context.Context, node models.Node) (string, err
 
 		// If container exists, try to recover it
 
-		inspect, inspectErr := m.cli.ContainerInspect(ctx, node.Name)
+		inspect, inspectErr := m.cli.ContainerInspect(ctx, name)
+
+		if inspectErr == nil && !ownsContainer(inspect.Config, node) {
 
-		if inspectErr == nil {
+			// Never reuse or remove a container that belongs to another node or lab
 
-			fmt.Printf("Node %s already exists (ID: %s). Reusing...\n", node.Name, inspect.ID[:12])
+			return "", fmt.Errorf("container name %s is already used by another node: %v", name, err)
+
+		}
+
+		if inspectErr == nil && inspect.Config.Labels["openveth.spec"] == nodeSpec(node) {
+
+			fmt.Printf("Node %s already exists (ID: %s). Reusing...\n", name, inspect.ID[:12])
 
 
 
@@ -258,7 +238,9 @@ func (m *Manager) CreateNode(ctx context.Context, node models.Node) (string, err
 
 			if !inspect.State.Running {
 
-				fmt.Printf("Node %s was stopped. Starting...\n", node.Name)
+				// New namespace: links are restored by the API's container event watcher
+
+				fmt.Printf("Node %s was stopped. Starting...\n", name)
 
 				if errStart := m.cli.ContainerStart(ctx, inspect.ID, container.StartOptions{}); errStart != nil {
 
@@ -272,9 +254,41 @@ func (m *Manager) CreateNode(ctx context.Context, node models.Node) (string, err
 
 		}
 
+		if inspectErr != nil {
+
+			return "", fmt.Errorf("error creating container: %v", err)
+
+		}
+
+
+
+		// Image, startup config or resources changed: the old container would ignore them
+
+		fmt.Printf("Node %s changed since it was created (ID: %s). Recreating...\n", name, inspect.ID[:12])
 
+		if errRemove := m.cli.ContainerRemove(ctx, inspect.ID, container.RemoveOptions{Force: true}); errRemove != nil {
 
-		return "", fmt.Errorf("error creating container: %v", err)
+			return "", fmt.Errorf("error removing outdated node: %v", errRemove)
+
+		}
+
+		if resp, err = m.cli.ContainerCreate(ctx, config, hostConfig, nil, nil, name); err != nil {
+
+			return "", fmt.Errorf("error creating container: %v", err)
+
+		}
+
+	}
+
+
+
+	// 3b. Startup files go in before start so FRR boots with its own config
+
+	if err := m.copyFiles(ctx, resp.ID, startupFiles(node.Startup)); err != nil {
+
+		_ = m.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+
+		return "", err
 
 	}
 
@@ -310,13 +324,25 @@ func (m *Manager) CreateNode(ctx context.Context, node models.Node) (string, err
 
 	} else {
 
-		fmt.Printf("Warning: Could not rename eth0 to mgmt0 in %s: %v\n", node.Name, err)
+		fmt.Printf("Warning: Could not rename eth0 to mgmt0 in %s: %v\n", name, err)
+
+	}
+
+
+
+	// 6. Startup commands (a failed setup must not leave a half-configured node behind)
+
+	if err := m.finishStartup(ctx, resp.ID, node); err != nil {
+
+		_ = m.cli.ContainerRemove(ctx, resp.ID, container.RemoveOptions{Force: true})
+
+		return "", err
 
 	}
 
 
 
-	fmt.Printf("Node %s created and started successfully (ID: %s).\n", node.Name, resp.ID[:12])
+	fmt.Printf("Node %s created and started successfully (ID: %s).\n", name, resp.ID[:12])
 
 	return resp.ID, nil
 
@@ -324,7 +350,25 @@ func (m *Manager) CreateNode(ctx context.Context, node models.Node) (string, err
 
 
 
-// DeleteNode stops and removes a container (Cleanup)
+// ownsContainer reports whether a container carries the openveth labels of a node
+
+func ownsContainer(config *container.Config, node models.Node) bool {
+
+	if config == nil {
+
+		return false
+
+	}
+
+	labels := config.Labels
+
+	return labels["openveth"] == "true" && labels["openveth.name"] == node.Name && labels["openveth.topology"] == node.TopologyID
+
+}
+
+
+
+// DeleteNode stops and removes a container (Cleanup). Accepts a container ID or name.
 
 func (m *Manager) DeleteNode(ctx context.Context, nodeName string) error {
 
@@ -380,29 +424,45 @@ func (m *Manager) TestConnection(ctx context.Context) error {
 
 
 
-// ListNodes displays containers managed by OpenVeth
+// ListNodes returns the containers managed by OpenVeth (label openveth=true)
 
-func (m *Manager) ListNodes(ctx context.Context) error {
+func (m *Manager) ListNodes(ctx context.Context) ([]RuntimeNode, error) {
 
-	containers, err := m.cli.ContainerList(ctx, container.ListOptions{All: true})
+	containers, err := m.cli.ContainerList(ctx, container.ListOptions{
+
+		All:     true,
+
+		Filters: filters.NewArgs(filters.Arg("label", "openveth=true")),
+
+	})
 
 	if err != nil {
 
-		return err
+		return nil, fmt.Errorf("error listing containers: %v", err)
 
 	}
 
 
 
-	fmt.Printf("Found %d containers on host.\n", len(containers))
+	nodes := make([]RuntimeNode, 0, len(containers))
 
 	for _, c := range containers {
 
-		fmt.Printf("- %s (ID: %s)\n", c.Names[0], c.ID[:10])
+		nodes = append(nodes, RuntimeNode{
+
+			ID:         c.ID,
+
+			NodeName:   c.Labels["openveth.name"],
+
+			TopologyID: c.Labels["openveth.topology"],
+
+			Running:    c.State == "running",
+
+		})
 
 	}
 
-	return nil
+	return nodes, nil
 
 }
 
@@ -424,7 +484,7 @@ func (m *Manager) GetNodePID(ctx context.Context, containerID string) (int, erro
 
 	if !inspect.State.Running {
 
-		return 0, fmt.Errorf("container %s is not running", containerID)
+		return 0, fmt.Errorf("container %s is not running: %w", containerID, ErrNodeState)
 
 	}
 
@@ -433,5 +493,63 @@ func (m *Manager) GetNodePID(ctx context.Context, containerID string) (int, erro
 	return inspect.State.Pid, nil
 
 }
-		
-		
+
+
+
+// NodePaused reports whether a container is paused (Docker still reports it as running)
+
+func (m *Manager) NodePaused(ctx context.Context, containerID string) (bool, error) {
+
+	inspect, err := m.cli.ContainerInspect(ctx, containerID)
+
+	if err != nil {
+
+		return false, fmt.Errorf("error inspecting container %s: %v", containerID, err)
+
+	}
+
+	return inspect.State.Paused, nil
+
+}
+
+
+
+// FindNodeContainer looks up a node container by its openveth labels (running or not)
+
+func (m *Manager) FindNodeContainer(ctx context.Context, node models.Node) (string, bool, error) {
+
+	args := filters.NewArgs(
+
+		filters.Arg("label", "openveth=true"),
+
+		filters.Arg("label", "openveth.name="+node.Name),
+
+	)
+
+	if node.TopologyID != "" {
+
+		args.Add("label", "openveth.topology="+node.TopologyID)
+
+	}
+
+
+
+	containers, err := m.cli.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+
+	if err != nil {
+
+		return "", false, fmt.Errorf("error listing containers for %s: %v", node.Name, err)
+
+	}
+
+	if len(containers) == 0 {
+
+		return "", false, nil
+
+	}
+
+
+
+	return containers[0].ID, containers[0].State == "running", nil
+
+}
@@ -52,3 +52,49 @@ func TestCreateAndDeleteNode(t *testing.T) {
 
 	t.Logf("✅ Éxito: Contenedor creado con ID %s", containerID)
 }
+
+// TestNodeSpec verifica que un cambio de imagen, startup o recursos cambie la etiqueta
+// openveth.spec, para que CreateNode no reutilice un contenedor desactualizado
+func TestNodeSpec(t *testing.T) {
+	base := models.Node{ID: "r1", Name: "r1", Type: models.ROUTER, Image: "frr:latest",
+		Startup: models.StartupConfig{FRRConf: "hostname r1\n"}}
+	if nodeSpec(base) != nodeSpec(base) {
+		t.Fatal("la etiqueta debería ser estable")
+	}
+
+	changes := map[string]func(n *models.Node){
+		"imagen":   func(n *models.Node) { n.Image = "frr:9" },
+		"frr.conf": func(n *models.Node) { n.Startup.FRRConf = "hostname r2\n" },
+		"comandos": func(n *models.Node) { n.Startup.Commands = []string{"ip addr add 10.0.0.1/32 dev lo"} },
+		"archivos": func(n *models.Node) { n.Startup.Files = map[string]string{"/etc/motd": "hola"} },
+		"cpu":      func(n *models.Node) { n.CPURequest = "0.5" },
+		"memoria":  func(n *models.Node) { n.RAMLimit = "256m" },
+	}
+	for name, change := range changes {
+		n := base
+		change(&n)
+		if nodeSpec(n) == nodeSpec(base) {
+			t.Errorf("cambiar %s debería cambiar la etiqueta", name)
+		}
+	}
+
+	// El estado del nodo no es parte de la especificación
+	moved := base
+	moved.PID, moved.Status = 1234, models.StatusRunning
+	if nodeSpec(moved) != nodeSpec(base) {
+		t.Error("el PID o el estado no deberían cambiar la etiqueta")
+	}
+}
+
+// TestContainerNameUnambiguous verifica que dos pares (lab, nodo) que se concatenan
+// igual no compartan contenedor
+func TestContainerNameUnambiguous(t *testing.T) {
+	a := models.Node{TopologyID: "a", Name: "b-c"}
+	b := models.Node{TopologyID: "a-b", Name: "c"}
+	if ContainerName(a) == ContainerName(b) {
+		t.Errorf("los dos nodos comparten el nombre %s", ContainerName(a))
+	}
+	if ContainerName(a) != ContainerName(a) {
+		t.Error("el nombre debería ser estable")
+	}
+}
@@ -0,0 +1,55 @@
+package orchestrator
+
+import (
+	"context"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// ContainerEvent is a lifecycle change of a container managed by OpenVeth
+type ContainerEvent struct {
+	ContainerID string
+	NodeName    string // Label openveth.name
+	TopologyID  string // Label openveth.topology
+	Action      string // start, die, stop, pause, unpause, destroy...
+}
+
+// WatchContainers streams lifecycle events of openveth=true containers until ctx is cancelled.
+// The error channel receives at most one error, after which the event channel is closed.
+func (m *Manager) WatchContainers(ctx context.Context) (<-chan ContainerEvent, <-chan error) {
+	out := make(chan ContainerEvent)
+	errs := make(chan error, 1)
+
+	msgs, dockerErrs := m.cli.Events(ctx, events.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("type", string(events.ContainerEventType)),
+			filters.Arg("label", "openveth=true"),
+		),
+	})
+
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg := <-msgs:
+				ev := ContainerEvent{
+					ContainerID: msg.Actor.ID,
+					NodeName:    msg.Actor.Attributes["openveth.name"],
+					TopologyID:  msg.Actor.Attributes["openveth.topology"],
+					Action:      string(msg.Action),
+				}
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-dockerErrs:
+				errs <- err
+				return
+			}
+		}
+	}()
+
+	return out, errs
+}
@@ -0,0 +1,163 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ErrExecTimeout is returned when a command outlives its context; the process is killed
+var ErrExecTimeout = errors.New("command timed out")
+
+// execOutputLimit caps what Exec keeps of each stream; the rest is read and dropped
+const execOutputLimit = 1 << 20
+
+// ExecResult is the outcome of a command run inside a node container
+type ExecResult struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitCode   int    `json:"exit_code"` // -1 if the command did not finish
+	DurationMs int64  `json:"duration_ms"`
+	TimedOut   bool   `json:"timed_out"`
+	Truncated  bool   `json:"truncated"` // stdout or stderr went over execOutputLimit
+}
+
+// Exec runs cmd inside a container and collects its output. A timeout of 0 only
+// uses the deadline of ctx. Hitting the timeout is not an error: the partial output
+// is returned with TimedOut set.
+func (m *Manager) Exec(ctx context.Context, containerID string, cmd []string, timeout time.Duration) (ExecResult, error) {
+	return collectExec(ctx, timeout, func(ctx context.Context, stdout, stderr io.Writer) (int, error) {
+		return m.ExecStream(ctx, containerID, cmd, stdout, stderr)
+	})
+}
+
+// collectExec buffers the output of an ExecStream call and applies the timeout
+func collectExec(ctx context.Context, timeout time.Duration, stream func(ctx context.Context, stdout, stderr io.Writer) (int, error)) (ExecResult, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	outBuf := &cappedBuffer{max: execOutputLimit}
+	errBuf := &cappedBuffer{max: execOutputLimit}
+	start := time.Now()
+	code, err := stream(ctx, outBuf, errBuf)
+
+	res := ExecResult{
+		Stdout:     outBuf.String(),
+		Stderr:     errBuf.String(),
+		ExitCode:   code,
+		DurationMs: time.Since(start).Milliseconds(),
+		Truncated:  outBuf.truncated || errBuf.truncated,
+	}
+	// Only the deadline is a timeout: a caller that went away is an error
+	if errors.Is(err, ErrExecTimeout) && ctx.Err() == context.DeadlineExceeded {
+		res.TimedOut = true
+		return res, nil
+	}
+	if errors.Is(err, ErrExecTimeout) && ctx.Err() != nil {
+		return res, ctx.Err()
+	}
+	return res, err
+}
+
+// cappedBuffer keeps the first max bytes written and accepts (drops) the rest, so the
+// command is still drained instead of blocking on a full pipe
+type cappedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.truncated = true
+		b.Buffer.Write(p[:max(room, 0)])
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// ExecStream runs cmd inside a container and copies its output to stdout and stderr
+// as it is produced. It returns the exit code once the command finishes. If ctx is
+// done first the process is killed and ErrExecTimeout is returned.
+func (m *Manager) ExecStream(ctx context.Context, containerID string, cmd []string, stdout, stderr io.Writer) (int, error) {
+	execIDResp, err := m.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		Cmd:          cmd,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return -1, fmt.Errorf("error creating exec: %v", err)
+	}
+
+	// Attach gives us the streams (Docker mixes them with headers, stdcopy splits them)
+	resp, err := m.cli.ContainerExecAttach(ctx, execIDResp.ID, container.ExecStartOptions{})
+	if err != nil {
+		return -1, fmt.Errorf("error attaching to exec: %v", err)
+	}
+	defer resp.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := stdcopy.StdCopy(stdout, stderr, resp.Reader)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return -1, fmt.Errorf("error reading exec output: %v", err)
+		}
+	case <-ctx.Done():
+		// The hijacked connection ignores ctx: kill the process and unblock the reader
+		m.killExec(execIDResp.ID)
+		resp.Close()
+		<-done
+		return -1, ErrExecTimeout
+	}
+
+	// The stream is closed once the process exits, so the exit code is final
+	inspect, err := m.cli.ContainerExecInspect(ctx, execIDResp.ID)
+	if err != nil {
+		return -1, fmt.Errorf("error inspecting exec: %v", err)
+	}
+	return inspect.ExitCode, nil
+}
+
+// killExec sends SIGKILL to the process of an exec (Docker has no API to stop one)
+func (m *Manager) killExec(execID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	inspect, err := m.cli.ContainerExecInspect(ctx, execID)
+	if err != nil || !inspect.Running || inspect.Pid <= 0 {
+		return
+	}
+	if err := syscall.Kill(inspect.Pid, syscall.SIGKILL); err != nil {
+		fmt.Printf("Warning: could not kill exec %s (pid %d): %v\n", execID[:12], inspect.Pid, err)
+	}
+}
+
+// execOK runs cmd and turns a timeout or a non-zero exit code into an error
+func (m *Manager) execOK(ctx context.Context, containerID string, cmd []string, timeout time.Duration) (ExecResult, error) {
+	res, err := m.Exec(ctx, containerID, cmd, timeout)
+	switch {
+	case err != nil:
+		return res, err
+	case res.TimedOut:
+		return res, fmt.Errorf("%v after %s", ErrExecTimeout, timeout)
+	case res.ExitCode != 0:
+		return res, fmt.Errorf("exited with %d: %s", res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	return res, nil
+}
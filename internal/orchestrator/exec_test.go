@@ -0,0 +1,45 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCollectExec(t *testing.T) {
+	// Una salida sin fin se corta en el límite, pero se sigue leyendo hasta el final
+	written := 0
+	res, err := collectExec(context.Background(), 0, func(ctx context.Context, stdout, stderr io.Writer) (int, error) {
+		chunk := []byte(strings.Repeat("y\n", 4096))
+		for written < 3*execOutputLimit {
+			n, err := stdout.Write(chunk)
+			if err != nil || n != len(chunk) {
+				return -1, errors.New("escritura corta")
+			}
+			written += n
+		}
+		io.WriteString(stderr, "ok")
+		return 0, nil
+	})
+	if err != nil || !res.Truncated || len(res.Stdout) != execOutputLimit || res.Stderr != "ok" {
+		t.Errorf("resultado inesperado: err=%v truncated=%v stdout=%d stderr=%q", err, res.Truncated, len(res.Stdout), res.Stderr)
+	}
+
+	wait := func(ctx context.Context, stdout, stderr io.Writer) (int, error) {
+		<-ctx.Done()
+		return -1, ErrExecTimeout
+	}
+	if res, err := collectExec(context.Background(), 10*time.Millisecond, wait); err != nil || !res.TimedOut {
+		t.Errorf("vencer el plazo debería ser timed_out: %+v, %v", res, err)
+	}
+
+	// Un cliente que se va no es un timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if res, err := collectExec(ctx, time.Minute, wait); !errors.Is(err, context.Canceled) || res.TimedOut {
+		t.Errorf("se esperaba context.Canceled sin timed_out: %+v, %v", res, err)
+	}
+}
@@ -0,0 +1,492 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"open-veth/internal/models"
+)
+
+// fakePIDBase is above the kernel's PID_MAX_LIMIT, so fake PIDs never point to a
+// real process (or its namespace)
+const fakePIDBase = 1 << 22
+
+// FakeRuntime is an in-memory NodeRuntime for tests. Nodes start running, get a new
+// PID on every start and keep their FRR running-config in memory. Terminals echo
+// their input back, like a TTY with nothing behind it.
+type FakeRuntime struct {
+	// ExecHandler answers Exec and ExecStream; nil means exit code 0 and no output
+	ExecHandler func(id string, cmd []string) ExecResult
+
+	// Kernel, when set, gets a network namespace for every running node. It is
+	// destroyed when the node stops, taking the node's links with it.
+	Kernel *FakeKernel
+
+	mu       sync.Mutex
+	nodes    map[string]*fakeNode // Key: runtime ID
+	seq      int
+	failures map[string]error // Method name -> error returned
+	terms    map[string][2]uint
+	lastExec map[string]string // Runtime ID -> last terminal exec ID
+	watchers []chan ContainerEvent
+}
+
+type fakeNode struct {
+	RuntimeNode
+	name   string // Container name (ContainerName)
+	pid    int
+	paused bool
+	config string // FRR running-config
+}
+
+var _ NodeRuntime = (*FakeRuntime)(nil)
+
+// NewFakeRuntime returns an empty runtime
+func NewFakeRuntime() *FakeRuntime {
+	return &FakeRuntime{
+		nodes:    make(map[string]*fakeNode),
+		failures: make(map[string]error),
+		terms:    make(map[string][2]uint),
+		lastExec: make(map[string]string),
+	}
+}
+
+// Fail makes a method (by name, e.g. "CreateNode") return err until Fail is called with nil
+func (f *FakeRuntime) Fail(method string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.failures, method)
+		return
+	}
+	f.failures[method] = err
+}
+
+// Node returns the runtime state of a node and its FRR running-config
+func (f *FakeRuntime) Node(id string) (RuntimeNode, string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, ok := f.nodes[id]
+	if !ok {
+		return RuntimeNode{}, "", false
+	}
+	return n.RuntimeNode, n.config, true
+}
+
+// TerminalSize returns the last size of a terminal opened with OpenTerminal
+func (f *FakeRuntime) TerminalSize(execID string) (uint, uint, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	size, ok := f.terms[execID]
+	return size[0], size[1], ok
+}
+
+// LastExecID returns the exec ID of the last terminal opened on a node (ID or container name)
+func (f *FakeRuntime) LastExecID(ref string) (string, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup("", ref)
+	if err != nil {
+		return "", false
+	}
+	execID, ok := f.lastExec[n.ID]
+	return execID, ok
+}
+
+// lookup finds a node by ID or container name. Requires f.mu.
+func (f *FakeRuntime) lookup(method, ref string) (*fakeNode, error) {
+	if err := f.failures[method]; err != nil {
+		return nil, err
+	}
+	if n, ok := f.nodes[ref]; ok {
+		return n, nil
+	}
+	for _, n := range f.nodes {
+		if n.name == ref {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("no such container: %s", ref)
+}
+
+// running is lookup for methods that need the node up. Requires f.mu.
+func (f *FakeRuntime) running(method, id string) (*fakeNode, error) {
+	n, err := f.lookup(method, id)
+	if err != nil {
+		return nil, err
+	}
+	if !n.Running {
+		return nil, fmt.Errorf("container %s is not running: %w", id, ErrNodeState)
+	}
+	return n, nil
+}
+
+// start gives the node a fresh PID, as a new network namespace would. Requires f.mu.
+func (f *FakeRuntime) start(n *fakeNode) {
+	f.stop(n)
+	f.seq++
+	n.pid = fakePIDBase + f.seq
+	n.Running, n.paused = true, false
+	if f.Kernel != nil {
+		f.Kernel.AddNamespace(n.pid)
+	}
+}
+
+// stop kills the node process and its network namespace. Requires f.mu.
+func (f *FakeRuntime) stop(n *fakeNode) {
+	if f.Kernel != nil && n.pid != 0 {
+		f.Kernel.DeleteNamespace(n.pid)
+	}
+	n.Running, n.paused, n.pid = false, false, 0
+}
+
+// emit queues container events; watchers that fall behind lose events. Requires f.mu.
+func (f *FakeRuntime) emit(n *fakeNode, actions ...string) {
+	for _, action := range actions {
+		ev := ContainerEvent{ContainerID: n.ID, NodeName: n.NodeName, TopologyID: n.TopologyID, Action: action}
+		for _, w := range f.watchers {
+			select {
+			case w <- ev:
+			default:
+			}
+		}
+	}
+}
+
+// --- Create and delete ---
+
+func (f *FakeRuntime) CreateNode(ctx context.Context, node models.Node) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["CreateNode"]; err != nil {
+		return "", err
+	}
+
+	name := ContainerName(node)
+	for _, n := range f.nodes {
+		if n.name == name {
+			return "", fmt.Errorf("container name %s is already in use", name)
+		}
+	}
+
+	f.seq++
+	n := &fakeNode{
+		RuntimeNode: RuntimeNode{
+			ID:         fmt.Sprintf("fake-%04d", f.seq),
+			NodeName:   node.Name,
+			TopologyID: node.TopologyID,
+		},
+		name:   name,
+		config: node.Startup.FRRConf,
+	}
+	f.start(n)
+	f.nodes[n.ID] = n
+	f.emit(n, "create", "start")
+	return n.ID, nil
+}
+
+func (f *FakeRuntime) DeleteNode(ctx context.Context, ref string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["DeleteNode"]; err != nil {
+		return err
+	}
+	n, err := f.lookup("DeleteNode", ref)
+	if err != nil {
+		return nil // Already gone, like Docker's force remove
+	}
+	f.stop(n)
+	delete(f.nodes, n.ID)
+	f.emit(n, "die", "destroy")
+	return nil
+}
+
+// --- Lifecycle ---
+
+func (f *FakeRuntime) StartNode(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup("StartNode", id)
+	if err != nil {
+		return err
+	}
+	if !n.Running {
+		f.start(n)
+		f.emit(n, "start")
+	}
+	return nil
+}
+
+func (f *FakeRuntime) StopNode(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup("StopNode", id)
+	if err != nil {
+		return err
+	}
+	if n.Running {
+		f.stop(n)
+		f.emit(n, "die", "stop")
+	}
+	return nil
+}
+
+func (f *FakeRuntime) RestartNode(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup("RestartNode", id)
+	if err != nil {
+		return err
+	}
+	f.start(n)
+	f.emit(n, "die", "start", "restart")
+	return nil
+}
+
+func (f *FakeRuntime) PauseNode(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.running("PauseNode", id)
+	if err != nil {
+		return err
+	}
+	n.paused = true
+	f.emit(n, "pause")
+	return nil
+}
+
+func (f *FakeRuntime) UnpauseNode(ctx context.Context, id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.running("UnpauseNode", id)
+	if err != nil {
+		return err
+	}
+	if !n.paused {
+		return fmt.Errorf("container %s is not paused: %w", id, ErrNodeState)
+	}
+	n.paused = false
+	f.emit(n, "unpause")
+	return nil
+}
+
+// --- Inspect and list ---
+
+func (f *FakeRuntime) GetNodePID(ctx context.Context, id string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.running("GetNodePID", id)
+	if err != nil {
+		return 0, err
+	}
+	return n.pid, nil
+}
+
+func (f *FakeRuntime) NodePaused(ctx context.Context, id string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.lookup("NodePaused", id)
+	if err != nil {
+		return false, err
+	}
+	return n.paused, nil
+}
+
+func (f *FakeRuntime) FindNodeContainer(ctx context.Context, node models.Node) (string, bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["FindNodeContainer"]; err != nil {
+		return "", false, err
+	}
+	for _, n := range f.nodes {
+		if n.NodeName == node.Name && (node.TopologyID == "" || n.TopologyID == node.TopologyID) {
+			return n.ID, n.Running, nil
+		}
+	}
+	return "", false, nil
+}
+
+// GetNodeInterfaces reports the loopback only: links live in the kernel, not in the runtime
+func (f *FakeRuntime) GetNodeInterfaces(ctx context.Context, id string) ([]models.InterfaceInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.running("GetNodeInterfaces", id); err != nil {
+		return nil, err
+	}
+	return []models.InterfaceInfo{
+		{Name: "lo", IPAddresses: []models.IPAddress{{Address: "127.0.0.1", Prefix: 8}}},
+	}, nil
+}
+
+func (f *FakeRuntime) ListNodes(ctx context.Context) ([]RuntimeNode, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["ListNodes"]; err != nil {
+		return nil, err
+	}
+	list := make([]RuntimeNode, 0, len(f.nodes))
+	for _, n := range f.nodes {
+		list = append(list, n.RuntimeNode)
+	}
+	return list, nil
+}
+
+// WatchContainers streams the events of later calls until ctx is cancelled
+func (f *FakeRuntime) WatchContainers(ctx context.Context) (<-chan ContainerEvent, <-chan error) {
+	ch := make(chan ContainerEvent, 64)
+	errs := make(chan error, 1)
+
+	f.mu.Lock()
+	f.watchers = append(f.watchers, ch)
+	f.mu.Unlock()
+
+	out := make(chan ContainerEvent)
+	go func() {
+		defer close(out)
+		defer func() {
+			f.mu.Lock()
+			for i, w := range f.watchers {
+				if w == ch {
+					f.watchers = append(f.watchers[:i], f.watchers[i+1:]...)
+					break
+				}
+			}
+			f.mu.Unlock()
+		}()
+		for {
+			select {
+			case ev := <-ch:
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
+// --- Exec and attach ---
+
+func (f *FakeRuntime) exec(method, id string, cmd []string) (ExecResult, error) {
+	f.mu.Lock()
+	_, err := f.running(method, id)
+	handler := f.ExecHandler
+	f.mu.Unlock()
+	if err != nil {
+		return ExecResult{}, err
+	}
+	if handler == nil {
+		return ExecResult{}, nil
+	}
+	return handler(id, cmd), nil
+}
+
+func (f *FakeRuntime) Exec(ctx context.Context, id string, cmd []string, timeout time.Duration) (ExecResult, error) {
+	return f.exec("Exec", id, cmd)
+}
+
+func (f *FakeRuntime) ExecStream(ctx context.Context, id string, cmd []string, stdout, stderr io.Writer) (int, error) {
+	res, err := f.exec("ExecStream", id, cmd)
+	if err != nil {
+		return -1, err
+	}
+	if res.TimedOut {
+		return -1, ErrExecTimeout
+	}
+	if _, err := io.WriteString(stdout, res.Stdout); err != nil {
+		return -1, err
+	}
+	if _, err := io.WriteString(stderr, res.Stderr); err != nil {
+		return -1, err
+	}
+	return res.ExitCode, nil
+}
+
+// OpenTerminal returns a TTY that echoes everything written to it
+func (f *FakeRuntime) OpenTerminal(ctx context.Context, id string, cmd []string, cols, rows uint) (*TermSession, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.running("OpenTerminal", id)
+	if err != nil {
+		return nil, err
+	}
+
+	f.seq++
+	execID := fmt.Sprintf("fake-exec-%04d", f.seq)
+	f.terms[execID] = [2]uint{cols, rows}
+	f.lastExec[n.ID] = execID
+
+	client, tty := net.Pipe()
+	go func() {
+		_, _ = io.Copy(tty, tty)
+		tty.Close()
+	}()
+	return &TermSession{
+		ExecID: execID,
+		Conn:   client,
+		Reader: client,
+		close:  func() { client.Close() },
+	}, nil
+}
+
+func (f *FakeRuntime) ResizeTerminal(ctx context.Context, execID string, cols, rows uint) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.failures["ResizeTerminal"]; err != nil {
+		return err
+	}
+	if _, ok := f.terms[execID]; !ok {
+		return fmt.Errorf("no such exec: %s", execID)
+	}
+	f.terms[execID] = [2]uint{cols, rows}
+	return nil
+}
+
+// --- Router configuration ---
+
+// ApplyStartupConfig replaces the running-config with frr.conf; new daemons restart the node
+func (f *FakeRuntime) ApplyStartupConfig(ctx context.Context, id string, node models.Node) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.running("ApplyStartupConfig", id)
+	if err != nil {
+		return err
+	}
+	if node.Startup.FRRConf != "" {
+		n.config = node.Startup.FRRConf
+	}
+	if node.Startup.Daemons != "" {
+		f.start(n)
+		f.emit(n, "die", "start", "restart")
+	}
+	return nil
+}
+
+func (f *FakeRuntime) RunningConfig(ctx context.Context, id string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.running("RunningConfig", id)
+	if err != nil {
+		return "", err
+	}
+	return n.config, nil
+}
+
+func (f *FakeRuntime) RestoreFRRConfig(ctx context.Context, id, config string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n, err := f.running("RestoreFRRConfig", id)
+	if err != nil {
+		return err
+	}
+	n.config = config
+	return nil
+}
@@ -0,0 +1,612 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"open-veth/internal/capture"
+	"open-veth/internal/models"
+)
+
+// FakeKernel modela en memoria los namespaces de red, con sus interfaces, bridges y
+// direcciones. Reproduce las reglas del kernel que afectan a NetworkManager: nombres de
+// 15 caracteres como máximo, nombres únicos por namespace, no renombrar interfaces
+// levantadas, y que al borrar un veth (o su namespace) desaparezca también su par.
+// Los cambios se notifican a WatchNamespace y los paquetes se inyectan con InjectPacket.
+type FakeKernel struct {
+	mu        sync.Mutex
+	ns        map[int]*fakeNetns // Clave: PID (HostPID = host)
+	nextIndex int
+	failures  map[string]error // Nombre del método -> error devuelto
+}
+
+type fakeNetns struct {
+	pid     int
+	links   map[string]*fakeLink
+	watches map[chan KernelEvent]struct{}
+}
+
+type fakeLink struct {
+	KernelLink
+	ns      *fakeNetns
+	peer    *fakeLink // Otro extremo del veth
+	addrs   []string
+	imp     models.Impairment
+	sockets map[*fakePacketSocket]struct{}
+}
+
+// Capacidad de los canales de WatchNamespace y de los sockets de captura
+const (
+	fakeWatchBuffer  = 64
+	fakeSocketBuffer = 256
+)
+
+var _ Kernel = (*FakeKernel)(nil)
+
+// NewFakeKernel crea un kernel con solo el namespace del host
+func NewFakeKernel() *FakeKernel {
+	k := &FakeKernel{
+		ns:        make(map[int]*fakeNetns),
+		nextIndex: 1,
+		failures:  make(map[string]error),
+	}
+	k.AddNamespace(HostPID)
+	return k
+}
+
+// AddNamespace crea el namespace de un proceso (PID) con su loopback levantada
+func (k *FakeKernel) AddNamespace(pid int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if _, ok := k.ns[pid]; ok {
+		return
+	}
+	n := &fakeNetns{pid: pid, links: make(map[string]*fakeLink), watches: make(map[chan KernelEvent]struct{})}
+	n.links["lo"] = &fakeLink{KernelLink: KernelLink{Name: "lo", Index: 1, Kind: "device", Up: true}, ns: n}
+	k.ns[pid] = n
+}
+
+// DeleteNamespace destruye el namespace como al morir su proceso: los veth que
+// tenía se llevan consigo a su par (otro nodo o un puerto de bridge en el host)
+func (k *FakeKernel) DeleteNamespace(pid int) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	n, ok := k.ns[pid]
+	if !ok || pid == HostPID {
+		return
+	}
+	for _, l := range n.links {
+		k.del(l)
+	}
+	for ch := range n.watches {
+		close(ch)
+	}
+	n.watches = nil
+	delete(k.ns, pid)
+}
+
+// Fail hace que un método (por nombre, p.ej. "AddVeth") devuelva err hasta que se llame con nil
+func (k *FakeKernel) Fail(method string, err error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err == nil {
+		delete(k.failures, method)
+		return
+	}
+	k.failures[method] = err
+}
+
+// Peer devuelve el namespace (PID) y el nombre del otro extremo de un veth
+func (k *FakeKernel) Peer(pid int, name string) (int, string, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("", pid, name)
+	if err != nil || l.peer == nil {
+		return 0, "", false
+	}
+	return l.peer.ns.pid, l.peer.Name, true
+}
+
+// Impairment devuelve el impairment configurado en una interfaz
+func (k *FakeKernel) Impairment(pid int, name string) (models.Impairment, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("", pid, name)
+	if err != nil {
+		return models.Impairment{}, false
+	}
+	return l.imp, true
+}
+
+// netns busca el namespace de un PID. Requiere k.mu.
+func (k *FakeKernel) netns(method string, pid int) (*fakeNetns, error) {
+	if err := k.failures[method]; err != nil {
+		return nil, err
+	}
+	if pid != HostPID {
+		if err := checkPID(pid); err != nil {
+			return nil, err
+		}
+	}
+	n, ok := k.ns[pid]
+	if !ok {
+		return nil, fmt.Errorf("error obteniendo ns del pid %d: %w", pid, syscall.ESRCH)
+	}
+	return n, nil
+}
+
+// link busca una interfaz dentro del namespace de un PID. Requiere k.mu.
+func (k *FakeKernel) link(method string, pid int, name string) (*fakeLink, error) {
+	n, err := k.netns(method, pid)
+	if err != nil {
+		return nil, err
+	}
+	l, ok := n.links[name]
+	if !ok {
+		return nil, ErrLinkNotFound
+	}
+	return l, nil
+}
+
+// add crea una interfaz validando el nombre como lo haría el kernel. Requiere k.mu.
+func (k *FakeKernel) add(n *fakeNetns, name, kind string) (*fakeLink, error) {
+	if err := validIfName(name); err != nil {
+		return nil, err
+	}
+	if _, exists := n.links[name]; exists {
+		return nil, syscall.EEXIST
+	}
+	k.nextIndex++
+	l := &fakeLink{KernelLink: KernelLink{Name: name, Index: k.nextIndex, Kind: kind}, ns: n}
+	n.links[name] = l
+	n.notify(KernelEvent{Interface: name, Kind: "link"})
+	return l, nil
+}
+
+// del borra una interfaz, su par si es un veth, y suelta los puertos si es un bridge. Requiere k.mu.
+func (k *FakeKernel) del(l *fakeLink) {
+	l.leave()
+	if l.Kind == "bridge" {
+		for _, port := range l.ns.links {
+			if port.MasterIndex == l.Index {
+				port.MasterIndex = 0
+			}
+		}
+	}
+	if l.peer != nil {
+		peer := l.peer
+		l.peer, peer.peer = nil, nil
+		peer.leave()
+	}
+}
+
+// leave saca la interfaz de su namespace avisando a los watchers, como netlink con
+// RTM_DELADDR y RTM_DELLINK, y corta las capturas abiertas en ella. Requiere k.mu.
+func (l *fakeLink) leave() {
+	delete(l.ns.links, l.Name)
+	for _, a := range l.addrs {
+		l.ns.notify(KernelEvent{Interface: l.Name, Kind: "addr", Deleted: true, Address: a})
+	}
+	l.ns.notify(KernelEvent{Interface: l.Name, Kind: "link", Deleted: true})
+	for s := range l.sockets {
+		s.shutdown()
+	}
+	l.sockets = nil
+}
+
+// notify entrega un evento a los watchers del namespace sin bloquear: como netlink,
+// si el lector no da abasto el evento se pierde. Requiere k.mu.
+func (n *fakeNetns) notify(ev KernelEvent) {
+	for ch := range n.watches {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// sorted devuelve las interfaces del namespace por índice. Requiere k.mu.
+func (n *fakeNetns) sorted() []*fakeLink {
+	list := make([]*fakeLink, 0, len(n.links))
+	for _, l := range n.links {
+		list = append(list, l)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Index < list[j].Index })
+	return list
+}
+
+// validIfName aplica las reglas de dev_valid_name: 1 a 15 caracteres, sin '/' ni espacios
+func validIfName(name string) error {
+	if name == "" || len(name) > 15 || name == "." || name == ".." || strings.ContainsAny(name, "/: \t\n") {
+		return fmt.Errorf("nombre de interfaz inválido %q: %w", name, syscall.EINVAL)
+	}
+	return nil
+}
+
+func (k *FakeKernel) LinkByName(pid int, name string) (KernelLink, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("LinkByName", pid, name)
+	if err != nil {
+		return KernelLink{}, err
+	}
+	return l.KernelLink, nil
+}
+
+func (k *FakeKernel) LinkList(pid int) ([]KernelLink, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	n, err := k.netns("LinkList", pid)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]KernelLink, 0, len(n.links))
+	for _, l := range n.sorted() {
+		list = append(list, l.KernelLink)
+	}
+	return list, nil
+}
+
+func (k *FakeKernel) AddVeth(pid int, name, peerName string, txQLen int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	n, err := k.netns("AddVeth", pid)
+	if err != nil {
+		return err
+	}
+	if name == peerName {
+		return syscall.EEXIST
+	}
+	if err := validIfName(peerName); err != nil {
+		return err
+	}
+	if _, exists := n.links[peerName]; exists {
+		return syscall.EEXIST
+	}
+	l, err := k.add(n, name, "veth")
+	if err != nil {
+		return err
+	}
+	peer, _ := k.add(n, peerName, "veth")
+	l.peer, peer.peer = peer, l
+	return nil
+}
+
+func (k *FakeKernel) AddBridge(pid int, name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	n, err := k.netns("AddBridge", pid)
+	if err != nil {
+		return err
+	}
+	_, err = k.add(n, name, "bridge")
+	return err
+}
+
+func (k *FakeKernel) LinkDel(pid int, name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("LinkDel", pid, name)
+	if err != nil {
+		return err
+	}
+	if l.Name == "lo" {
+		return syscall.EOPNOTSUPP
+	}
+	k.del(l)
+	return nil
+}
+
+// LinkSetNs mueve la interfaz: como en el kernel llega abajo, sin bridge y sin direcciones
+func (k *FakeKernel) LinkSetNs(pid int, name string, targetPid int) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("LinkSetNs", pid, name)
+	if err != nil {
+		return err
+	}
+	target, err := k.netns("LinkSetNs", targetPid)
+	if err != nil {
+		return err
+	}
+	if _, exists := target.links[name]; exists {
+		return syscall.EEXIST
+	}
+	l.leave()
+	l.ns = target
+	l.Up, l.MasterIndex, l.addrs = false, 0, nil
+	target.links[name] = l
+	target.notify(KernelEvent{Interface: name, Kind: "link"})
+	return nil
+}
+
+func (k *FakeKernel) LinkSetName(pid int, name, newName string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("LinkSetName", pid, name)
+	if err != nil {
+		return err
+	}
+	if err := validIfName(newName); err != nil {
+		return err
+	}
+	if _, exists := l.ns.links[newName]; exists {
+		return syscall.EEXIST
+	}
+	if l.Up {
+		return syscall.EBUSY
+	}
+	delete(l.ns.links, name)
+	l.Name = newName
+	l.ns.links[newName] = l
+	return nil
+}
+
+func (k *FakeKernel) LinkSetUp(pid int, name string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("LinkSetUp", pid, name)
+	if err != nil {
+		return err
+	}
+	if !l.Up {
+		l.Up = true
+		l.ns.notify(KernelEvent{Interface: name, Kind: "link", Up: true})
+	}
+	return nil
+}
+
+func (k *FakeKernel) LinkSetMaster(pid int, name, bridgeName string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("LinkSetMaster", pid, name)
+	if err != nil {
+		return err
+	}
+	br, err := k.link("LinkSetMaster", pid, bridgeName)
+	if err != nil {
+		return err
+	}
+	if br.Kind != "bridge" || br == l {
+		return syscall.EINVAL
+	}
+	l.MasterIndex = br.Index
+	return nil
+}
+
+// parseCIDR normaliza la dirección como la devolvería el kernel
+func parseCIDR(cidr string) (string, error) {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", err, syscall.EINVAL)
+	}
+	return p.String(), nil
+}
+
+func (k *FakeKernel) AddrReplace(pid int, name, cidr string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("AddrReplace", pid, name)
+	if err != nil {
+		return err
+	}
+	cidr, err = parseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	for _, a := range l.addrs {
+		if a == cidr {
+			return nil
+		}
+	}
+	l.addrs = append(l.addrs, cidr)
+	l.ns.notify(KernelEvent{Interface: name, Kind: "addr", Address: cidr})
+	return nil
+}
+
+func (k *FakeKernel) AddrDel(pid int, name, cidr string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("AddrDel", pid, name)
+	if err != nil {
+		return err
+	}
+	cidr, err = parseCIDR(cidr)
+	if err != nil {
+		return err
+	}
+	for i, a := range l.addrs {
+		if a == cidr {
+			l.addrs = append(l.addrs[:i], l.addrs[i+1:]...)
+			l.ns.notify(KernelEvent{Interface: name, Kind: "addr", Deleted: true, Address: cidr})
+			return nil
+		}
+	}
+	return syscall.EADDRNOTAVAIL
+}
+
+func (k *FakeKernel) AddrList(pid int, name string) ([]string, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("AddrList", pid, name)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string(nil), l.addrs...), nil
+}
+
+func (k *FakeKernel) SetImpairment(pid int, name string, imp models.Impairment) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("SetImpairment", pid, name)
+	if err != nil {
+		return err
+	}
+	l.imp = imp
+	return nil
+}
+
+// WatchNamespace entrega primero las interfaces actuales (como ListExisting) y después
+// los cambios que hacen los métodos del FakeKernel. El canal se cierra al cancelar ctx o
+// al borrar el namespace.
+func (k *FakeKernel) WatchNamespace(ctx context.Context, pid int) (<-chan KernelEvent, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := checkPID(pid); err != nil {
+		return nil, err
+	}
+	n, err := k.netns("WatchNamespace", pid)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan KernelEvent, len(n.links)+fakeWatchBuffer)
+	for _, l := range n.sorted() {
+		ch <- KernelEvent{Interface: l.Name, Kind: "link", Up: l.Up}
+	}
+	n.watches[ch] = struct{}{}
+
+	go func() {
+		<-ctx.Done()
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		if _, ok := n.watches[ch]; ok {
+			delete(n.watches, ch)
+			close(ch)
+		}
+	}()
+	return ch, nil
+}
+
+// OpenPacketSocket abre una captura que recibe los paquetes de InjectPacket.
+// Los filtros de userspace se aplican al entregar; los de BPF no (no hay intérprete).
+func (k *FakeKernel) OpenPacketSocket(pid int, name string, filter *capture.Filter) (PacketSource, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("OpenPacketSocket", pid, name)
+	if err != nil {
+		return nil, fmt.Errorf("interfaz %s no encontrada: %w", name, err)
+	}
+
+	s := &fakePacketSocket{
+		k:       k,
+		link:    l,
+		iface:   name,
+		filter:  filter,
+		packets: make(chan fakePacket, fakeSocketBuffer),
+		down:    make(chan struct{}),
+		closed:  make(chan struct{}),
+	}
+	if l.sockets == nil {
+		l.sockets = make(map[*fakePacketSocket]struct{})
+	}
+	l.sockets[s] = struct{}{}
+	return s, nil
+}
+
+// InjectPacket hace pasar un paquete por una interfaz: lo ven como saliente las capturas
+// de esa interfaz y como entrante las del otro extremo si es un veth
+func (k *FakeKernel) InjectPacket(pid int, name string, frame []byte) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	l, err := k.link("", pid, name)
+	if err != nil {
+		return err
+	}
+	l.deliver(frame, capture.DirOutbound)
+	if l.peer != nil {
+		l.peer.deliver(frame, capture.DirInbound)
+	}
+	return nil
+}
+
+// deliver copia el paquete a cada captura abierta en la interfaz. Requiere k.mu.
+func (l *fakeLink) deliver(frame []byte, dir capture.Direction) {
+	for s := range l.sockets {
+		s.push(fakePacket{data: append([]byte(nil), frame...), dir: dir})
+	}
+}
+
+type fakePacket struct {
+	data []byte
+	dir  capture.Direction
+}
+
+// fakePacketSocket es una captura del FakeKernel
+type fakePacketSocket struct {
+	k       *FakeKernel
+	link    *fakeLink
+	iface   string
+	filter  *capture.Filter
+	packets chan fakePacket
+	dropped atomic.Uint64
+
+	down      chan struct{} // La interfaz se borró o cambió de namespace
+	downOnce  sync.Once
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// push encola el paquete; con el buffer lleno lo descarta y lo cuenta, como el kernel
+func (s *fakePacketSocket) push(p fakePacket) {
+	if s.filter != nil {
+		pkt := capture.Decode(p.data)
+		if !s.filter.Match(&pkt) {
+			return
+		}
+	}
+	select {
+	case s.packets <- p:
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+func (s *fakePacketSocket) shutdown() {
+	s.downOnce.Do(func() { close(s.down) })
+}
+
+// ReadPacket entrega primero los paquetes encolados; después falla con ENETDOWN si la interfaz ya no está
+func (s *fakePacketSocket) ReadPacket(ctx context.Context, buf []byte) (int, int, capture.Direction, error) {
+	for {
+		select {
+		case p := <-s.packets:
+			return copy(buf, p.data), len(p.data), p.dir, nil
+		default:
+		}
+
+		select {
+		case p := <-s.packets:
+			return copy(buf, p.data), len(p.data), p.dir, nil
+		case <-ctx.Done():
+			return 0, 0, capture.DirUnknown, ctx.Err()
+		case <-s.closed:
+			return 0, 0, capture.DirUnknown, fmt.Errorf("error leyendo paquete en %s: %w", s.iface, syscall.EBADF)
+		case <-s.down:
+			if len(s.packets) > 0 {
+				continue
+			}
+			return 0, 0, capture.DirUnknown, fmt.Errorf("error leyendo paquete en %s: %w", s.iface, syscall.ENETDOWN)
+		}
+	}
+}
+
+// Dropped devuelve los descartes desde la llamada anterior, como PACKET_STATISTICS
+func (s *fakePacketSocket) Dropped() (uint64, error) {
+	return s.dropped.Swap(0), nil
+}
+
+// Close deja de recibir paquetes (idempotente)
+func (s *fakePacketSocket) Close() error {
+	s.k.mu.Lock()
+	delete(s.link.sockets, s)
+	s.k.mu.Unlock()
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
@@ -0,0 +1,118 @@
+package orchestrator
+
+import (
+	"errors"
+	"fmt"
+
+	"open-veth/internal/models"
+
+	"github.com/vishvananda/netlink"
+)
+
+// Handles de tc: netem en la raíz (1:) y tbf como hijo (10:) colgando de 1:1
+var (
+	netemHandle = netlink.MakeHandle(1, 0)
+	tbfParent   = netlink.MakeHandle(1, 1)
+	tbfHandle   = netlink.MakeHandle(10, 0)
+)
+
+// ApplyImpairment configura netem/tbf en una interfaz dentro del namespace (PID).
+// Un Impairment vacío elimina cualquier qdisc raíz y deja la interfaz sin degradación.
+func (nm *NetworkManager) ApplyImpairment(pid int, ifaceName string, imp models.Impairment) error {
+	if err := imp.Validate(); err != nil {
+		return err
+	}
+	if err := nm.kernel.SetImpairment(pid, ifaceName, imp); err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			return fmt.Errorf("interfaz %s no encontrada: %w", ifaceName, err)
+		}
+		return err
+	}
+
+	if !imp.IsZero() {
+		fmt.Printf("Impairment aplicado en PID %d: %s %+v\n", pid, ifaceName, imp)
+	}
+	return nil
+}
+
+func (k netlinkKernel) SetImpairment(pid int, name string, imp models.Impairment) error {
+	return k.do(pid, func() error {
+		link, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+
+		// Partimos siempre de cero para no heredar un tbf anterior
+		if err := clearRootQdisc(link); err != nil {
+			return err
+		}
+		if imp.IsZero() {
+			return nil
+		}
+
+		if err := netlink.QdiscAdd(netemQdisc(link.Attrs().Index, imp)); err != nil {
+			return fmt.Errorf("error aplicando netem en %s: %v", name, err)
+		}
+		if imp.Rate > 0 {
+			if err := netlink.QdiscAdd(tbfQdisc(link.Attrs().Index, imp.Rate)); err != nil {
+				return fmt.Errorf("error aplicando tbf en %s: %v", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// clearRootQdisc elimina el qdisc raíz de la interfaz (y con él sus hijos)
+func clearRootQdisc(link netlink.Link) error {
+	qdiscs, err := netlink.QdiscList(link)
+	if err != nil {
+		return fmt.Errorf("error listando qdiscs: %v", err)
+	}
+	for _, q := range qdiscs {
+		if q.Attrs().Parent == netlink.HANDLE_ROOT && q.Attrs().Handle == netemHandle {
+			if err := netlink.QdiscDel(q); err != nil {
+				return fmt.Errorf("error eliminando qdisc %s: %v", q.Type(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// netemQdisc traduce un Impairment al qdisc netem raíz
+func netemQdisc(linkIndex int, imp models.Impairment) *netlink.Netem {
+	attrs := netlink.QdiscAttrs{
+		LinkIndex: linkIndex,
+		Handle:    netemHandle,
+		Parent:    netlink.HANDLE_ROOT,
+	}
+	return netlink.NewNetem(attrs, netlink.NetemQdiscAttrs{
+		Latency:     imp.Delay * 1000, // ms -> us
+		Jitter:      imp.Jitter * 1000,
+		Loss:        imp.Loss,
+		Duplicate:   imp.Duplicate,
+		ReorderProb: imp.Reorder,
+		CorruptProb: imp.Corrupt,
+	})
+}
+
+// tbfQdisc crea el limitador de ancho de banda bajo netem
+func tbfQdisc(linkIndex int, rateKbit uint64) *netlink.Tbf {
+	rate := rateKbit * 1000 / 8 // bytes/s
+
+	// Burst de ~10ms de tráfico (mínimo un par de MTUs) y cola de ~50ms
+	burst := uint32(rate / 100)
+	if burst < 3200 {
+		burst = 3200
+	}
+
+	return &netlink.Tbf{
+		QdiscAttrs: netlink.QdiscAttrs{
+			LinkIndex: linkIndex,
+			Handle:    tbfHandle,
+			Parent:    tbfParent,
+		},
+		Rate:   rate,
+		Buffer: netlink.Xmittime(rate, burst),
+		Limit:  uint32(rate/20) + burst,
+	}
+}
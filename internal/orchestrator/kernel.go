@@ -0,0 +1,315 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+
+	"open-veth/internal/capture"
+	"open-veth/internal/models"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+)
+
+// HostPID identifica el namespace de red del host en las operaciones de Kernel.
+// No es 0: un nodo parado guarda PID 0 y sus operaciones deben fallar, no caer en el host.
+const HostPID = -1
+
+// ErrLinkNotFound indica que la interfaz no existe en el namespace
+var ErrLinkNotFound = errors.New("la interfaz no existe")
+
+// ErrNoNamespace indica un PID que no es de ningún proceso (p.ej. el 0 de un nodo parado)
+var ErrNoNamespace = errors.New("el nodo no tiene namespace (PID inválido)")
+
+// Kernel agrupa las operaciones de netlink/netns que usa NetworkManager.
+// Cada operación recibe el PID cuyo namespace de red se modifica (HostPID para el host).
+// netlinkKernel habla con el kernel real; FakeKernel lo modela en memoria para los tests.
+type Kernel interface {
+	// Consultas
+	LinkByName(pid int, name string) (KernelLink, error) // ErrLinkNotFound si no existe
+	LinkList(pid int) ([]KernelLink, error)
+
+	// Creación y borrado. Borrar un extremo de un veth borra también su par.
+	AddVeth(pid int, name, peerName string, txQLen int) error
+	AddBridge(pid int, name string) error
+	LinkDel(pid int, name string) error
+
+	// Configuración de interfaces
+	LinkSetNs(pid int, name string, targetPid int) error // Mueve la interfaz al namespace de targetPid
+	LinkSetName(pid int, name, newName string) error
+	LinkSetUp(pid int, name string) error
+	LinkSetMaster(pid int, name, bridgeName string) error
+
+	// Direcciones (CIDR) e impairments (netem/tbf)
+	AddrReplace(pid int, name, cidr string) error
+	AddrDel(pid int, name, cidr string) error // syscall.EADDRNOTAVAIL si no estaba
+	AddrList(pid int, name string) ([]string, error)
+	SetImpairment(pid int, name string, imp models.Impairment) error // Un Impairment vacío limpia los qdiscs
+
+	// Observación: eventos de links/direcciones y sockets de captura
+	WatchNamespace(ctx context.Context, pid int) (<-chan KernelEvent, error)
+	OpenPacketSocket(pid int, name string, filter *capture.Filter) (PacketSource, error)
+}
+
+// KernelLink es una interfaz de red tal como la ve el kernel
+type KernelLink struct {
+	Name        string
+	Index       int
+	Kind        string // "veth", "bridge", "device"...
+	MasterIndex int    // Bridge al que está conectada (0 = ninguno)
+	Up          bool
+}
+
+// netlinkKernel implementa Kernel con netlink sobre el kernel del host
+type netlinkKernel struct{}
+
+// do ejecuta la acción en el namespace del PID (o directamente en el host)
+func (netlinkKernel) do(pid int, action func() error) error {
+	if pid == HostPID {
+		return action()
+	}
+	return runInNs(pid, action)
+}
+
+// checkPID rechaza los PIDs que no pueden ser de un nodo
+func checkPID(pid int) error {
+	if pid <= 0 {
+		return fmt.Errorf("pid %d: %w", pid, ErrNoNamespace)
+	}
+	return nil
+}
+
+// lookup traduce el error de netlink a ErrLinkNotFound
+func (netlinkKernel) lookup(name string) (netlink.Link, error) {
+	l, err := netlink.LinkByName(name)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		return nil, ErrLinkNotFound
+	}
+	return l, err
+}
+
+func toKernelLink(l netlink.Link) KernelLink {
+	attrs := l.Attrs()
+	return KernelLink{
+		Name:        attrs.Name,
+		Index:       attrs.Index,
+		Kind:        l.Type(),
+		MasterIndex: attrs.MasterIndex,
+		Up:          attrs.Flags&net.FlagUp != 0,
+	}
+}
+
+func (k netlinkKernel) LinkByName(pid int, name string) (KernelLink, error) {
+	var kl KernelLink
+	err := k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		kl = toKernelLink(l)
+		return nil
+	})
+	return kl, err
+}
+
+func (k netlinkKernel) LinkList(pid int) ([]KernelLink, error) {
+	var list []KernelLink
+	err := k.do(pid, func() error {
+		links, err := netlink.LinkList()
+		if err != nil {
+			return err
+		}
+		for _, l := range links {
+			list = append(list, toKernelLink(l))
+		}
+		return nil
+	})
+	return list, err
+}
+
+func (k netlinkKernel) AddVeth(pid int, name, peerName string, txQLen int) error {
+	return k.do(pid, func() error {
+		return netlink.LinkAdd(&netlink.Veth{
+			LinkAttrs: netlink.LinkAttrs{Name: name, TxQLen: txQLen},
+			PeerName:  peerName,
+		})
+	})
+}
+
+func (k netlinkKernel) AddBridge(pid int, name string) error {
+	return k.do(pid, func() error {
+		return netlink.LinkAdd(&netlink.Bridge{LinkAttrs: netlink.LinkAttrs{Name: name}})
+	})
+}
+
+func (k netlinkKernel) LinkDel(pid int, name string) error {
+	return k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		return netlink.LinkDel(l)
+	})
+}
+
+func (k netlinkKernel) LinkSetNs(pid int, name string, targetPid int) error {
+	// Obtenemos el NS del destino solo para moverla (netlink.LinkSetNsFd necesita el FD)
+	if err := checkPID(targetPid); err != nil {
+		return err
+	}
+	targetNs, err := netns.GetFromPid(targetPid)
+	if err != nil {
+		return fmt.Errorf("error obteniendo ns del pid %d: %v", targetPid, err)
+	}
+	defer targetNs.Close()
+
+	return k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetNsFd(l, int(targetNs))
+	})
+}
+
+func (k netlinkKernel) LinkSetName(pid int, name, newName string) error {
+	return k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetName(l, newName)
+	})
+}
+
+func (k netlinkKernel) LinkSetUp(pid int, name string) error {
+	return k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetUp(l)
+	})
+}
+
+func (k netlinkKernel) LinkSetMaster(pid int, name, bridgeName string) error {
+	return k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		br, err := k.lookup(bridgeName)
+		if err != nil {
+			return err
+		}
+		return netlink.LinkSetMaster(l, br)
+	})
+}
+
+func (k netlinkKernel) AddrReplace(pid int, name, cidr string) error {
+	return k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		addr, err := netlink.ParseAddr(cidr)
+		if err != nil {
+			return err
+		}
+		return netlink.AddrReplace(l, addr)
+	})
+}
+
+func (k netlinkKernel) AddrDel(pid int, name, cidr string) error {
+	return k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		addr, err := netlink.ParseAddr(cidr)
+		if err != nil {
+			return err
+		}
+		return netlink.AddrDel(l, addr)
+	})
+}
+
+func (k netlinkKernel) AddrList(pid int, name string) ([]string, error) {
+	var cidrs []string
+	err := k.do(pid, func() error {
+		l, err := k.lookup(name)
+		if err != nil {
+			return err
+		}
+		addrs, err := netlink.AddrList(l, netlink.FAMILY_ALL)
+		if err != nil {
+			return err
+		}
+		for _, a := range addrs {
+			cidrs = append(cidrs, a.IPNet.String())
+		}
+		return nil
+	})
+	return cidrs, err
+}
+
+// runInNs ejecuta una función dentro del namespace de red de un proceso (PID)
+// Se encarga de bloquear el hilo y restaurar el namespace original al finalizar.
+func runInNs(pid int, action func() error) error {
+	if err := checkPID(pid); err != nil {
+		return err
+	}
+
+	// Obtener handle del namespace destino
+	targetNs, err := netns.GetFromPid(pid)
+	if err != nil {
+		return fmt.Errorf("error obteniendo ns del pid %d: %v", pid, err)
+	}
+	defer targetNs.Close()
+
+	return runInNsHandle(targetNs, action)
+}
+
+// runInNamedNs es runInNs para un namespace con nombre (/var/run/netns/<name>)
+func runInNamedNs(name string, action func() error) error {
+	targetNs, err := netns.GetFromName(name)
+	if err != nil {
+		return fmt.Errorf("error obteniendo ns %s: %v", name, err)
+	}
+	defer targetNs.Close()
+
+	return runInNsHandle(targetNs, action)
+}
+
+// runInNsHandle cambia el hilo actual al namespace, ejecuta la acción y vuelve
+func runInNsHandle(targetNs netns.NsHandle, action func() error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// Guardar el namespace original
+	origns, err := netns.Get()
+	if err != nil {
+		return fmt.Errorf("error obteniendo netns original: %v", err)
+	}
+	defer origns.Close()
+
+	// Cambiar al namespace destino
+	if err := netns.Set(targetNs); err != nil {
+		return fmt.Errorf("error cambiando de ns: %v", err)
+	}
+
+	// Ejecutar la acción
+	err = action()
+
+	// Volver al namespace original
+	// Es crítico hacer esto antes de retornar, incluso si action() falló
+	if errSwitchBack := netns.Set(origns); errSwitchBack != nil {
+		// Si fallamos en volver, estamos en un estado inconsistente (panic worthy en algunos casos)
+		return fmt.Errorf("CRÍTICO: error volviendo al ns original (error acción: %v): %v", err, errSwitchBack)
+	}
+
+	return err
+}
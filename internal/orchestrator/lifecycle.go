@@ -0,0 +1,63 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/errdefs"
+)
+
+// stopTimeout is how long Docker waits for a graceful stop before killing (seconds)
+const stopTimeout = 10
+
+// StartNode starts a stopped node container
+func (m *Manager) StartNode(ctx context.Context, containerID string) error {
+	if err := m.cli.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		return lifecycleError("starting", containerID, err)
+	}
+	return nil
+}
+
+// StopNode stops a node container gracefully
+func (m *Manager) StopNode(ctx context.Context, containerID string) error {
+	timeout := stopTimeout
+	if err := m.cli.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &timeout}); err != nil {
+		return lifecycleError("stopping", containerID, err)
+	}
+	return nil
+}
+
+// RestartNode stops and starts a node container (it gets a new network namespace)
+func (m *Manager) RestartNode(ctx context.Context, containerID string) error {
+	timeout := stopTimeout
+	if err := m.cli.ContainerRestart(ctx, containerID, container.StopOptions{Timeout: &timeout}); err != nil {
+		return lifecycleError("restarting", containerID, err)
+	}
+	return nil
+}
+
+// PauseNode freezes every process of a node container (namespace and links survive)
+func (m *Manager) PauseNode(ctx context.Context, containerID string) error {
+	if err := m.cli.ContainerPause(ctx, containerID); err != nil {
+		return lifecycleError("pausing", containerID, err)
+	}
+	return nil
+}
+
+// UnpauseNode resumes a paused node container
+func (m *Manager) UnpauseNode(ctx context.Context, containerID string) error {
+	if err := m.cli.ContainerUnpause(ctx, containerID); err != nil {
+		return lifecycleError("unpausing", containerID, err)
+	}
+	return nil
+}
+
+// lifecycleError wraps a Docker error; conflicts (pausing a stopped container,
+// unpausing a running one) become ErrNodeState
+func lifecycleError(verb, containerID string, err error) error {
+	if errdefs.IsConflict(err) {
+		return fmt.Errorf("error %s node %s: %w: %v", verb, containerID, ErrNodeState, err)
+	}
+	return fmt.Errorf("error %s node %s: %v", verb, containerID, err)
+}
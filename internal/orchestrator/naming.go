@@ -0,0 +1,62 @@
+package orchestrator
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"open-veth/internal/models"
+)
+
+// ContainerName devuelve el nombre del contenedor Docker de un nodo.
+// Se prefija con el ID del laboratorio para que dos labs puedan tener un "r1" a la vez.
+// IDs y nombres pueden llevar "-", así que el sufijo con el hash del par (lab, nodo)
+// evita que el lab "a" con "b-c" y el lab "a-b" con "c" compartan contenedor.
+func ContainerName(node models.Node) string {
+	if node.TopologyID == "" {
+		return node.Name
+	}
+	return fmt.Sprintf("ov-%s-%s-%s", node.TopologyID, node.Name, shortHash(node.TopologyID, node.Name))
+}
+
+// nodeSpec resume lo que define un contenedor de nodo (imagen, startup y recursos).
+// Va en la etiqueta openveth.spec: si cambia, el contenedor existente no se reutiliza.
+func nodeSpec(node models.Node) string {
+	data, _ := json.Marshal(struct {
+		Image      string
+		Startup    models.StartupConfig
+		CPURequest string
+		RAMLimit   string
+	}{node.Image, node.Startup, node.CPURequest, node.RAMLimit})
+	return fmt.Sprintf("%x", sha256.Sum256(data))[:16]
+}
+
+// shortHash genera un identificador corto (8 hex) y estable para nombres de interfaces.
+// Linux limita los nombres de interfaz a 15 caracteres, por eso no usamos los IDs completos.
+func shortHash(parts ...string) string {
+	h := fnv.New32a()
+	for _, p := range parts {
+		h.Write([]byte(p))
+		h.Write([]byte{'/'})
+	}
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// hostVethNames devuelve los nombres temporales en el host de ambos extremos de un link
+func hostVethNames(link models.Link) (string, string) {
+	tag := shortHash(link.TopologyID, link.ID)
+	return fmt.Sprintf("veth%s_s", tag), fmt.Sprintf("veth%s_t", tag)
+}
+
+// BridgeName devuelve el nombre del Linux Bridge que implementa un nodo SWITCH
+func BridgeName(node models.Node) string {
+	return "br" + shortHash(node.TopologyID, node.ID)
+}
+
+// BridgePortName devuelve el nombre en el host del puerto del bridge al que se conecta
+// la interfaz de un nodo (PID). Depende del PID, así que cambia si el nodo se recrea.
+func BridgePortName(bridgeName string, pid int, iface string) string {
+	return "vb" + shortHash(bridgeName, strconv.Itoa(pid), iface)
+}
@@ -0,0 +1,762 @@
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"open-veth/internal/models"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	namespaceIDPrefix    = "netns:"         // Runtime IDs of namespace nodes
+	namespaceRunDir      = "/var/run/netns" // Where netns (and 'ip netns') bind the namespaces
+	namespaceStopTimeout = 5 * time.Second  // SIGTERM grace period of the node processes
+)
+
+// errNamespaceFRR is returned by the FRR config methods: a namespace node has no
+// /etc/frr of its own and its daemons are whatever its command started
+var errNamespaceFRR = errors.New("FRR configuration is not managed for netns nodes")
+
+// IsNamespaceID reports whether a runtime ID belongs to a namespace node
+func IsNamespaceID(id string) bool {
+	return strings.HasPrefix(id, namespaceIDPrefix)
+}
+
+// NamespaceRuntime runs nodes as bare named network namespaces with processes from
+// the host filesystem, for labs too large for a container per node.
+//
+// Every running node has a holder process (sleep infinity) in its namespace. Its
+// PID is the node PID used for links and addresses, and stays valid even if the
+// node command exits. Stopping a node kills its processes and removes the namespace,
+// like a container losing its network namespace. Node state is kept in stateDir so
+// nodes outlive the server, as containers do.
+//
+// There is no isolation beyond the network: commands, exec and terminals run as the
+// server user on the host filesystem, so exec on a namespace node is a host shell.
+type NamespaceRuntime struct {
+	stateDir string
+
+	mu       sync.Mutex // Serializes state changes
+	seq      int
+	terms    map[string]*os.File // Exec ID -> PTY master
+	watchers []chan ContainerEvent
+}
+
+// namespaceState is the state file of a namespace node
+type namespaceState struct {
+	Name       string   `json:"name"` // Namespace name (ContainerName)
+	NodeName   string   `json:"node_name"`
+	TopologyID string   `json:"topology_id"`
+	Command    []string `json:"command,omitempty"`
+	HolderPID  int      `json:"holder_pid"` // 0 = stopped
+	CommandPID int      `json:"command_pid,omitempty"`
+	Paused     bool     `json:"paused,omitempty"`
+}
+
+var _ NodeRuntime = (*NamespaceRuntime)(nil)
+
+// NewNamespaceRuntime keeps the node state and command logs in stateDir
+func NewNamespaceRuntime(stateDir string) (*NamespaceRuntime, error) {
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating namespace state dir: %v", err)
+	}
+	return &NamespaceRuntime{stateDir: stateDir, terms: make(map[string]*os.File)}, nil
+}
+
+func (r *NamespaceRuntime) statePath(name string) string {
+	return filepath.Join(r.stateDir, name+".json")
+}
+
+// LogPath is the file that collects the output of a node command
+func (r *NamespaceRuntime) LogPath(id string) string {
+	return filepath.Join(r.stateDir, strings.TrimPrefix(id, namespaceIDPrefix)+".log")
+}
+
+// validNamespaceName rejects names that are not a plain file name under namespaceRunDir
+func validNamespaceName(name string) error {
+	if name == "" || name == "." || name == ".." || len(name) > 255 || strings.ContainsAny(name, "/\x00") {
+		return fmt.Errorf("invalid namespace name %q", name)
+	}
+	return nil
+}
+
+// load reads the state of a node by runtime ID or namespace name
+func (r *NamespaceRuntime) load(ref string) (*namespaceState, bool, error) {
+	name := strings.TrimPrefix(ref, namespaceIDPrefix)
+	if err := validNamespaceName(name); err != nil {
+		return nil, false, err
+	}
+	data, err := os.ReadFile(r.statePath(name))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading state of %s: %v", name, err)
+	}
+	var st namespaceState
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, false, fmt.Errorf("error reading state of %s: %v", name, err)
+	}
+	return &st, true, nil
+}
+
+// lookup is load for nodes that must exist
+func (r *NamespaceRuntime) lookup(ref string) (*namespaceState, error) {
+	st, found, err := r.load(ref)
+	if err == nil && !found {
+		err = fmt.Errorf("no such namespace node: %s", ref)
+	}
+	return st, err
+}
+
+// running is lookup for methods that need the node up
+func (r *NamespaceRuntime) running(ref string) (*namespaceState, error) {
+	st, err := r.lookup(ref)
+	if err != nil {
+		return nil, err
+	}
+	if !st.isRunning() {
+		return nil, fmt.Errorf("namespace node %s is not running: %w", st.Name, ErrNodeState)
+	}
+	return st, nil
+}
+
+// save writes the state file atomically, so readers never see half of it
+func (r *NamespaceRuntime) save(st *namespaceState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := r.statePath(st.Name) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return fmt.Errorf("error saving state of %s: %v", st.Name, err)
+	}
+	return os.Rename(tmp, r.statePath(st.Name))
+}
+
+func (st *namespaceState) id() string {
+	return namespaceIDPrefix + st.Name
+}
+
+// isRunning checks the holder against the kernel: a PID reused by another process
+// lives in another namespace
+func (st *namespaceState) isRunning() bool {
+	return st.HolderPID > 0 && inNamespace(st.HolderPID, st.Name)
+}
+
+// inNamespace reports whether a process lives in the named network namespace
+func inNamespace(pid int, name string) bool {
+	var nsStat, procStat unix.Stat_t
+	if err := unix.Stat(filepath.Join(namespaceRunDir, name), &nsStat); err != nil {
+		return false
+	}
+	if err := unix.Stat(fmt.Sprintf("/proc/%d/ns/net", pid), &procStat); err != nil {
+		return false
+	}
+	return nsStat.Dev == procStat.Dev && nsStat.Ino == procStat.Ino
+}
+
+// createNamedNs creates /var/run/netns/<name> with the loopback up.
+// netns.NewNamed moves the calling thread into the new namespace, so the thread
+// is locked and switched back before returning.
+func createNamedNs(name string) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origns, err := netns.Get()
+	if err != nil {
+		return fmt.Errorf("error getting current netns: %v", err)
+	}
+	defer origns.Close()
+
+	ns, err := netns.NewNamed(name)
+	if err != nil {
+		_ = netns.Set(origns)
+		return fmt.Errorf("error creating namespace %s: %v", name, err)
+	}
+	defer ns.Close()
+
+	var upErr error
+	if lo, err := netlink.LinkByName("lo"); err == nil {
+		upErr = netlink.LinkSetUp(lo)
+	} else {
+		upErr = err
+	}
+
+	if err := netns.Set(origns); err != nil {
+		return fmt.Errorf("CRITICAL: error returning to the original netns: %v", err)
+	}
+	if upErr != nil {
+		_ = netns.DeleteNamed(name)
+		return fmt.Errorf("error bringing up lo in %s: %v", name, upErr)
+	}
+	return nil
+}
+
+// spawn starts cmd inside the named namespace. Only the locked thread that forks
+// enters the namespace, so the child inherits it and the server stays on the host.
+func spawn(name string, cmd *exec.Cmd) error {
+	return runInNamedNs(name, cmd.Start)
+}
+
+// start creates the namespace, its holder and the node command
+func (r *NamespaceRuntime) start(st *namespaceState) error {
+	if err := createNamedNs(st.Name); err != nil {
+		return err
+	}
+
+	// Own process groups: a Ctrl-C to the server must not reach the nodes
+	holder := exec.Command("sleep", "infinity")
+	holder.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := spawn(st.Name, holder); err != nil {
+		_ = netns.DeleteNamed(st.Name)
+		return fmt.Errorf("error starting namespace holder: %v", err)
+	}
+	go func() { _ = holder.Wait() }()
+	st.HolderPID, st.CommandPID, st.Paused = holder.Process.Pid, 0, false
+
+	if len(st.Command) == 0 {
+		return nil
+	}
+	logFile, err := os.OpenFile(r.LogPath(st.id()), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		r.kill(st)
+		return fmt.Errorf("error opening log of %s: %v", st.Name, err)
+	}
+	defer logFile.Close() // The command keeps its own descriptor
+
+	cmd := exec.Command(st.Command[0], st.Command[1:]...)
+	cmd.Stdout, cmd.Stderr = logFile, logFile
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if err := spawn(st.Name, cmd); err != nil {
+		r.kill(st)
+		return fmt.Errorf("error starting %s: %v", st.Command[0], err)
+	}
+	st.CommandPID = cmd.Process.Pid
+
+	go func() {
+		err := cmd.Wait()
+		fmt.Printf("Namespace node %s: %s exited (%v)\n", st.Name, st.Command[0], err)
+	}()
+	return nil
+}
+
+// kill stops every process in the namespace (the command, daemons that detached
+// from its process group and the holder), then removes the namespace
+func (r *NamespaceRuntime) kill(st *namespaceState) {
+	if pids := namespacePIDs(st.Name); len(pids) > 0 {
+		st.signal(syscall.SIGTERM)
+		st.signal(syscall.SIGCONT) // A paused process only sees SIGTERM once resumed
+
+		deadline := time.Now().Add(namespaceStopTimeout)
+		for len(namespacePIDs(st.Name)) > 0 && time.Now().Before(deadline) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		// A process may fork while it is being killed: repeat until none is left
+		for i := 0; i < 10 && len(namespacePIDs(st.Name)) > 0; i++ {
+			st.signal(syscall.SIGKILL)
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(namespaceRunDir, st.Name)); err != nil {
+		// Already removed
+	} else if err := netns.DeleteNamed(st.Name); err != nil {
+		fmt.Printf("Warning: could not remove namespace %s: %v\n", st.Name, err)
+	}
+	st.HolderPID, st.CommandPID, st.Paused = 0, 0, false
+}
+
+// signal sends sig to every process in the namespace of the node
+func (st *namespaceState) signal(sig syscall.Signal) {
+	for _, pid := range namespacePIDs(st.Name) {
+		_ = syscall.Kill(pid, sig)
+	}
+}
+
+// namespacePIDs lists the processes whose network namespace is the named one,
+// as 'ip netns pids' does. The server itself is never included.
+func namespacePIDs(name string) []int {
+	var nsStat unix.Stat_t
+	if err := unix.Stat(filepath.Join(namespaceRunDir, name), &nsStat); err != nil {
+		return nil
+	}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	var pids []int
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		var procStat unix.Stat_t
+		if unix.Stat(fmt.Sprintf("/proc/%d/ns/net", pid), &procStat) != nil {
+			continue // Gone or a zombie
+		}
+		if procStat.Dev == nsStat.Dev && procStat.Ino == nsStat.Ino {
+			pids = append(pids, pid)
+		}
+	}
+	return pids
+}
+
+// emit queues lifecycle events; watchers that fall behind lose events. Requires r.mu.
+func (r *NamespaceRuntime) emit(st *namespaceState, actions ...string) {
+	for _, action := range actions {
+		ev := ContainerEvent{ContainerID: st.id(), NodeName: st.NodeName, TopologyID: st.TopologyID, Action: action}
+		for _, w := range r.watchers {
+			select {
+			case w <- ev:
+			default:
+			}
+		}
+	}
+}
+
+// --- Create and delete ---
+
+// CreateNode creates the namespace of a node, starts its command and runs its
+// startup commands inside the namespace
+func (r *NamespaceRuntime) CreateNode(ctx context.Context, node models.Node) (string, error) {
+	name := ContainerName(node)
+	if err := validNamespaceName(name); err != nil {
+		return "", err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, found, _ := r.load(name); found {
+		return "", fmt.Errorf("namespace node %s already exists", name)
+	}
+	if _, err := os.Stat(filepath.Join(namespaceRunDir, name)); err == nil {
+		return "", fmt.Errorf("namespace %s already exists", name)
+	}
+
+	fmt.Printf("Orchestrating namespace node: %s\n", name)
+	st := &namespaceState{Name: name, NodeName: node.Name, TopologyID: node.TopologyID, Command: node.Command}
+	if err := r.start(st); err != nil {
+		return "", err
+	}
+	if err := r.save(st); err != nil {
+		r.kill(st)
+		return "", err
+	}
+
+	// A failed setup must not leave a half-configured node behind
+	for _, cmd := range node.Startup.Commands {
+		res, err := r.Exec(ctx, st.id(), []string{"sh", "-c", cmd}, startupCommandTimeout)
+		if err == nil && res.TimedOut {
+			err = fmt.Errorf("%v after %s", ErrExecTimeout, startupCommandTimeout)
+		} else if err == nil && res.ExitCode != 0 {
+			err = fmt.Errorf("exited with %d: %s", res.ExitCode, strings.TrimSpace(res.Stderr))
+		}
+		if err != nil {
+			r.kill(st)
+			_ = os.Remove(r.statePath(name))
+			return "", fmt.Errorf("startup command %q: %v", cmd, err)
+		}
+	}
+
+	r.emit(st, "create", "start")
+	return st.id(), nil
+}
+
+// DeleteNode kills the processes of a node and removes its namespace and state.
+// Accepts a runtime ID or a namespace name; unknown nodes are already gone.
+func (r *NamespaceRuntime) DeleteNode(ctx context.Context, ref string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, found, err := r.load(ref)
+	if err != nil || !found {
+		return err
+	}
+
+	fmt.Printf("Deleting namespace node %s...\n", st.Name)
+	r.kill(st)
+	if err := os.Remove(r.statePath(st.Name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting node %s: %v", st.Name, err)
+	}
+	_ = os.Remove(r.LogPath(st.id()))
+	r.emit(st, "die", "destroy")
+	return nil
+}
+
+// --- Lifecycle ---
+
+func (r *NamespaceRuntime) StartNode(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, err := r.lookup(id)
+	if err != nil || st.isRunning() {
+		return err
+	}
+	r.kill(st) // Leftovers of a node whose holder died
+	if err := r.start(st); err != nil {
+		return err
+	}
+	r.emit(st, "start")
+	return r.save(st)
+}
+
+func (r *NamespaceRuntime) StopNode(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, err := r.lookup(id)
+	if err != nil || !st.isRunning() {
+		return err
+	}
+	r.kill(st)
+	r.emit(st, "die", "stop")
+	return r.save(st)
+}
+
+func (r *NamespaceRuntime) RestartNode(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, err := r.lookup(id)
+	if err != nil {
+		return err
+	}
+	r.kill(st)
+	if err := r.start(st); err != nil {
+		_ = r.save(st)
+		return err
+	}
+	r.emit(st, "die", "start", "restart")
+	return r.save(st)
+}
+
+// PauseNode freezes the node processes with SIGSTOP; links and addresses stay
+func (r *NamespaceRuntime) PauseNode(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, err := r.running(id)
+	if err != nil {
+		return err
+	}
+	st.signal(syscall.SIGSTOP)
+	st.Paused = true
+	r.emit(st, "pause")
+	return r.save(st)
+}
+
+func (r *NamespaceRuntime) UnpauseNode(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, err := r.running(id)
+	if err != nil {
+		return err
+	}
+	if !st.Paused {
+		return fmt.Errorf("namespace node %s is not paused: %w", st.Name, ErrNodeState)
+	}
+	st.signal(syscall.SIGCONT)
+	st.Paused = false
+	r.emit(st, "unpause")
+	return r.save(st)
+}
+
+// --- Inspect and list ---
+
+// GetNodePID returns the PID of the holder process
+func (r *NamespaceRuntime) GetNodePID(ctx context.Context, id string) (int, error) {
+	st, err := r.running(id)
+	if err != nil {
+		return 0, err
+	}
+	return st.HolderPID, nil
+}
+
+// NodePaused reports whether the node processes are stopped with SIGSTOP
+func (r *NamespaceRuntime) NodePaused(ctx context.Context, id string) (bool, error) {
+	st, err := r.lookup(id)
+	if err != nil {
+		return false, err
+	}
+	return st.Paused, nil
+}
+
+func (r *NamespaceRuntime) FindNodeContainer(ctx context.Context, node models.Node) (string, bool, error) {
+	st, found, err := r.load(ContainerName(node))
+	if err != nil || !found {
+		return "", false, err
+	}
+	return st.id(), st.isRunning(), nil
+}
+
+// GetNodeInterfaces runs the host 'ip -j addr' inside the namespace
+func (r *NamespaceRuntime) GetNodeInterfaces(ctx context.Context, id string) ([]models.InterfaceInfo, error) {
+	res, err := r.Exec(ctx, id, []string{"ip", "-j", "addr"}, 5*time.Second)
+	if err == nil && res.ExitCode != 0 {
+		err = fmt.Errorf("exited with %d: %s", res.ExitCode, strings.TrimSpace(res.Stderr))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error running 'ip -j addr': %v", err)
+	}
+
+	var interfaces []models.InterfaceInfo
+	if err := json.Unmarshal([]byte(res.Stdout), &interfaces); err != nil {
+		return nil, fmt.Errorf("error parsing ip addr json: %v. Output: %s", err, res.Stdout)
+	}
+	return interfaces, nil
+}
+
+func (r *NamespaceRuntime) ListNodes(ctx context.Context) ([]RuntimeNode, error) {
+	entries, err := os.ReadDir(r.stateDir)
+	if err != nil {
+		return nil, fmt.Errorf("error listing namespace nodes: %v", err)
+	}
+	var list []RuntimeNode
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".json")
+		if !ok {
+			continue
+		}
+		st, found, err := r.load(name)
+		if err != nil || !found {
+			continue
+		}
+		list = append(list, RuntimeNode{ID: st.id(), NodeName: st.NodeName, TopologyID: st.TopologyID, Running: st.isRunning()})
+	}
+	return list, nil
+}
+
+// WatchContainers streams the lifecycle events of later calls until ctx is cancelled
+func (r *NamespaceRuntime) WatchContainers(ctx context.Context) (<-chan ContainerEvent, <-chan error) {
+	ch := make(chan ContainerEvent, 64)
+	errs := make(chan error, 1)
+
+	r.mu.Lock()
+	r.watchers = append(r.watchers, ch)
+	r.mu.Unlock()
+
+	out := make(chan ContainerEvent)
+	go func() {
+		defer close(out)
+		defer func() {
+			r.mu.Lock()
+			for i, w := range r.watchers {
+				if w == ch {
+					r.watchers = append(r.watchers[:i], r.watchers[i+1:]...)
+					break
+				}
+			}
+			r.mu.Unlock()
+		}()
+		for {
+			select {
+			case ev := <-ch:
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, errs
+}
+
+// --- Exec and attach ---
+
+func (r *NamespaceRuntime) Exec(ctx context.Context, id string, cmd []string, timeout time.Duration) (ExecResult, error) {
+	return collectExec(ctx, timeout, func(ctx context.Context, stdout, stderr io.Writer) (int, error) {
+		return r.ExecStream(ctx, id, cmd, stdout, stderr)
+	})
+}
+
+// ExecStream runs a host command inside the namespace. Exit codes follow Docker:
+// 127 when the command does not exist, 128+n when killed by signal n.
+func (r *NamespaceRuntime) ExecStream(ctx context.Context, id string, cmd []string, stdout, stderr io.Writer) (int, error) {
+	st, err := r.running(id)
+	if err != nil {
+		return -1, err
+	}
+	if len(cmd) == 0 {
+		return -1, fmt.Errorf("empty command")
+	}
+
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Stdout, c.Stderr = stdout, stderr
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	c.WaitDelay = time.Second // Background children must not hold the output open
+	if err := spawn(st.Name, c); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			fmt.Fprintln(stderr, err)
+			return 127, nil
+		}
+		return -1, fmt.Errorf("error starting command: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- c.Wait() }()
+
+	select {
+	case err := <-done:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+				return 128 + int(ws.Signal()), nil
+			}
+			return exitErr.ExitCode(), nil
+		}
+		if err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+			return -1, fmt.Errorf("error running command: %v", err)
+		}
+		return c.ProcessState.ExitCode(), nil
+	case <-ctx.Done():
+		_ = syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+		<-done
+		return -1, ErrExecTimeout
+	}
+}
+
+// OpenTerminal starts a host command inside the namespace on a new PTY
+func (r *NamespaceRuntime) OpenTerminal(ctx context.Context, id string, cmd []string, cols, rows uint) (*TermSession, error) {
+	st, err := r.running(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(cmd) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+
+	master, tty, err := openPTY()
+	if err != nil {
+		return nil, err
+	}
+	if cols > 0 && rows > 0 {
+		_ = setPTYSize(master, cols, rows)
+	}
+
+	c := exec.Command(cmd[0], cmd[1:]...)
+	c.Stdin, c.Stdout, c.Stderr = tty, tty, tty
+	c.Env = append(os.Environ(), "TERM=xterm")
+	c.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true} // The PTY (stdin) becomes its controlling terminal
+	err = spawn(st.Name, c)
+	tty.Close()
+	if err != nil {
+		master.Close()
+		return nil, fmt.Errorf("error starting terminal: %v", err)
+	}
+	go func() { _ = c.Wait() }()
+
+	r.mu.Lock()
+	r.seq++
+	execID := fmt.Sprintf("%s%s/%d", namespaceIDPrefix, st.Name, r.seq)
+	r.terms[execID] = master
+	r.mu.Unlock()
+
+	return &TermSession{
+		ExecID: execID,
+		Conn:   master,
+		Reader: master,
+		close: func() {
+			r.mu.Lock()
+			delete(r.terms, execID)
+			r.mu.Unlock()
+			master.Close() // The shell gets SIGHUP
+		},
+	}, nil
+}
+
+func (r *NamespaceRuntime) ResizeTerminal(ctx context.Context, execID string, cols, rows uint) error {
+	r.mu.Lock()
+	master, ok := r.terms[execID]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no such terminal: %s", execID)
+	}
+	if err := setPTYSize(master, cols, rows); err != nil {
+		return fmt.Errorf("error resizing terminal: %v", err)
+	}
+	return nil
+}
+
+// openPTY allocates a pseudo-terminal pair. The master stays non-blocking, so
+// closing it unblocks a pending Read.
+func openPTY() (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening /dev/ptmx: %v", err)
+	}
+
+	var n int
+	err = control(master, func(fd int) error {
+		if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil { // unlockpt
+			return err
+		}
+		n, err = unix.IoctlGetInt(fd, unix.TIOCGPTN) // ptsname
+		return err
+	})
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("error setting up pty: %v", err)
+	}
+
+	tty, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("error opening pty: %v", err)
+	}
+	return master, tty, nil
+}
+
+// setPTYSize sets the window size; the kernel sends SIGWINCH to the foreground process
+func setPTYSize(master *os.File, cols, rows uint) error {
+	return control(master, func(fd int) error {
+		return unix.IoctlSetWinsize(fd, unix.TIOCSWINSZ, &unix.Winsize{Row: uint16(rows), Col: uint16(cols)})
+	})
+}
+
+// control runs fn on the descriptor of f without switching it to blocking mode (as Fd does)
+func control(f *os.File, fn func(fd int) error) error {
+	raw, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var fnErr error
+	if err := raw.Control(func(fd uintptr) { fnErr = fn(int(fd)) }); err != nil {
+		return err
+	}
+	return fnErr
+}
+
+// --- Router configuration ---
+
+// ApplyStartupConfig has nothing to push: namespace nodes only take startup
+// commands, which run when the node is created
+func (r *NamespaceRuntime) ApplyStartupConfig(ctx context.Context, id string, node models.Node) error {
+	_, err := r.running(id)
+	return err
+}
+
+func (r *NamespaceRuntime) RunningConfig(ctx context.Context, id string) (string, error) {
+	return "", errNamespaceFRR
+}
+
+func (r *NamespaceRuntime) RestoreFRRConfig(ctx context.Context, id, config string) error {
+	return errNamespaceFRR
+}
@@ -0,0 +1,264 @@
+package orchestrator
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"open-veth/internal/models"
+)
+
+// newTestNamespaceRuntime crea el runtime sobre un directorio temporal y borra al
+// final los nodos que queden. Se salta si el entorno no permite crear namespaces.
+func newTestNamespaceRuntime(t *testing.T) *NamespaceRuntime {
+	t.Helper()
+	if os.Geteuid() != 0 {
+		t.Skip("hace falta root para crear namespaces")
+	}
+	probe := fmt.Sprintf("ovtest-probe-%d", os.Getpid())
+	if err := createNamedNs(probe); err != nil {
+		t.Skipf("no se pueden crear namespaces: %v", err)
+	}
+	(&NamespaceRuntime{}).kill(&namespaceState{Name: probe})
+
+	r, err := NewNamespaceRuntime(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		nodes, _ := r.ListNodes(context.Background())
+		for _, n := range nodes {
+			_ = r.DeleteNode(context.Background(), n.ID)
+		}
+	})
+	return r
+}
+
+func testNamespaceNode(name string) models.Node {
+	return models.Node{
+		ID:         name,
+		Name:       name,
+		TopologyID: fmt.Sprintf("ovtest%d", os.Getpid()),
+		Type:       models.ROUTER,
+		Runtime:    models.RuntimeNamespace,
+	}
+}
+
+// waitForLog espera a que el comando del nodo, que arranca en segundo plano, haya
+// escrito "up" n veces
+func waitForLog(t *testing.T, path string, n int) {
+	t.Helper()
+	var log []byte
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		if log, _ = os.ReadFile(path); strings.Count(string(log), "up") == n {
+			return
+		}
+	}
+	t.Fatalf("el comando debería haberse ejecutado %d veces: %q", n, log)
+}
+
+func TestNamespaceNodeLinks(t *testing.T) {
+	r := newTestNamespaceRuntime(t)
+	ctx := context.Background()
+
+	a := testNamespaceNode("a")
+	a.Startup.Commands = []string{"ip addr add 192.0.2.1/32 dev lo"}
+	idA, err := r.CreateNode(ctx, a)
+	if err != nil {
+		t.Fatalf("CreateNode falló: %v", err)
+	}
+	idB, err := r.CreateNode(ctx, testNamespaceNode("b"))
+	if err != nil {
+		t.Fatalf("CreateNode falló: %v", err)
+	}
+	if !IsNamespaceID(idA) {
+		t.Errorf("ID inesperado: %s", idA)
+	}
+	if _, err := r.CreateNode(ctx, a); err == nil {
+		t.Error("crear dos veces el mismo nodo debería fallar")
+	}
+
+	pidA, err := r.GetNodePID(ctx, idA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pidB, err := r.GetNodePID(ctx, idB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// El NetworkManager real trata al holder como a cualquier contenedor
+	nm := NewNetworkManager()
+	link := models.Link{ID: "l1", TopologyID: a.TopologyID, SourceInt: "eth1", TargetInt: "eth1"}
+	if err := nm.CreateLink(link, pidA, pidB); err != nil {
+		t.Fatalf("CreateLink falló: %v", err)
+	}
+	if err := nm.SetInterfaceIP(pidA, "eth1", "10.0.0.1/30"); err != nil {
+		t.Fatalf("SetInterfaceIP falló: %v", err)
+	}
+
+	res, err := r.Exec(ctx, idA, []string{"ip", "-o", "addr", "show", "eth1"}, 5*time.Second)
+	if err != nil || res.ExitCode != 0 || !strings.Contains(res.Stdout, "10.0.0.1/30") {
+		t.Errorf("eth1 debería tener 10.0.0.1/30: %+v %v", res, err)
+	}
+	ifaces, err := r.GetNodeInterfaces(ctx, idA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, i := range ifaces {
+		names = append(names, i.Name)
+	}
+	if got := strings.Join(names, ","); got != "lo,eth1" {
+		t.Errorf("interfaces inesperadas: %s", got)
+	}
+	res, _ = r.Exec(ctx, idA, []string{"ip", "-o", "addr", "show", "lo"}, 5*time.Second)
+	if !strings.Contains(res.Stdout, "192.0.2.1/32") {
+		t.Errorf("el comando de arranque debería haber configurado lo: %q", res.Stdout)
+	}
+
+	// Códigos de salida como en Docker
+	if res, _ := r.Exec(ctx, idA, []string{"sh", "-c", "exit 3"}, 5*time.Second); res.ExitCode != 3 {
+		t.Errorf("se esperaba el código 3: %+v", res)
+	}
+	if res, _ := r.Exec(ctx, idA, []string{"no-such-command"}, 5*time.Second); res.ExitCode != 127 {
+		t.Errorf("se esperaba el código 127: %+v", res)
+	}
+	if res, err := r.Exec(ctx, idA, []string{"sleep", "5"}, 100*time.Millisecond); err != nil || !res.TimedOut {
+		t.Errorf("el comando debería agotar el tiempo: %+v %v", res, err)
+	}
+}
+
+func TestNamespaceNodeLifecycle(t *testing.T) {
+	r := newTestNamespaceRuntime(t)
+	ctx := context.Background()
+
+	node := testNamespaceNode("c")
+	node.Command = []string{"sh", "-c", "echo up; exec sleep infinity"}
+	id, err := r.CreateNode(ctx, node)
+	if err != nil {
+		t.Fatalf("CreateNode falló: %v", err)
+	}
+	nsPath := filepath.Join(namespaceRunDir, ContainerName(node))
+
+	waitForLog(t, r.LogPath(id), 1)
+	if found, running, err := r.FindNodeContainer(ctx, node); err != nil || found != id || !running {
+		t.Errorf("FindNodeContainer: %s %v %v", found, running, err)
+	}
+
+	if err := r.StopNode(ctx, id); err != nil {
+		t.Fatalf("StopNode falló: %v", err)
+	}
+	if _, err := os.Stat(nsPath); !os.IsNotExist(err) {
+		t.Errorf("parar el nodo debería borrar el namespace: %v", err)
+	}
+	if _, err := r.GetNodePID(ctx, id); err == nil {
+		t.Error("un nodo parado no debería tener PID")
+	}
+
+	if err := r.StartNode(ctx, id); err != nil {
+		t.Fatalf("StartNode falló: %v", err)
+	}
+	if err := r.PauseNode(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UnpauseNode(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	waitForLog(t, r.LogPath(id), 2)
+
+	if err := r.DeleteNode(ctx, ContainerName(node)); err != nil {
+		t.Fatalf("DeleteNode falló: %v", err)
+	}
+	if _, err := os.Stat(nsPath); !os.IsNotExist(err) {
+		t.Errorf("el namespace debería haberse borrado: %v", err)
+	}
+	if nodes, _ := r.ListNodes(ctx); len(nodes) != 0 {
+		t.Errorf("no deberían quedar nodos: %+v", nodes)
+	}
+}
+
+func TestNamespaceNodeKillsDetachedProcesses(t *testing.T) {
+	r := newTestNamespaceRuntime(t)
+	ctx := context.Background()
+
+	// Un demonio que se va a otra sesión no recibe las señales del grupo del comando
+	node := testNamespaceNode("f")
+	node.Command = []string{"sh", "-c", "setsid sleep infinity & echo up; exec sleep infinity"}
+	id, err := r.CreateNode(ctx, node)
+	if err != nil {
+		t.Fatalf("CreateNode falló: %v", err)
+	}
+	waitForLog(t, r.LogPath(id), 1)
+
+	var pids []int
+	for deadline := time.Now().Add(5 * time.Second); len(pids) < 3 && time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+		pids = namespacePIDs(ContainerName(node))
+	}
+	if len(pids) < 3 {
+		t.Fatalf("se esperaban el holder, el comando y el demonio: %v", pids)
+	}
+
+	if err := r.StopNode(ctx, id); err != nil {
+		t.Fatalf("StopNode falló: %v", err)
+	}
+	for _, pid := range pids {
+		if _, err := os.Stat(fmt.Sprintf("/proc/%d/ns/net", pid)); err == nil {
+			t.Errorf("el proceso %d debería haber terminado", pid)
+		}
+	}
+}
+
+func TestNamespaceNodeStartupFailure(t *testing.T) {
+	r := newTestNamespaceRuntime(t)
+
+	node := testNamespaceNode("d")
+	node.Startup.Commands = []string{"exit 1"}
+	if _, err := r.CreateNode(context.Background(), node); err == nil {
+		t.Fatal("un comando de arranque fallido debería abortar la creación")
+	}
+	if _, err := os.Stat(filepath.Join(namespaceRunDir, ContainerName(node))); !os.IsNotExist(err) {
+		t.Errorf("no debería quedar el namespace: %v", err)
+	}
+}
+
+func TestNamespaceNodeTerminal(t *testing.T) {
+	r := newTestNamespaceRuntime(t)
+	ctx := context.Background()
+
+	id, err := r.CreateNode(ctx, testNamespaceNode("e"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	term, err := r.OpenTerminal(ctx, id, []string{"sh"}, 80, 24)
+	if err != nil {
+		t.Fatalf("OpenTerminal falló: %v", err)
+	}
+	defer term.Close()
+
+	if err := r.ResizeTerminal(ctx, term.ExecID, 100, 30); err != nil {
+		t.Errorf("ResizeTerminal falló: %v", err)
+	}
+	if _, err := term.Conn.Write([]byte("stty size; echo fin-$((1+1))\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	buf := make([]byte, 1024)
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(out.String(), "fin-2") && time.Now().Before(deadline) {
+		n, err := term.Reader.Read(buf)
+		out.Write(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+	if !strings.Contains(out.String(), "fin-2") || !strings.Contains(out.String(), "30 100") {
+		t.Errorf("salida inesperada del terminal: %q", out.String())
+	}
+}
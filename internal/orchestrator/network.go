@@ -1,163 +1,133 @@
 package orchestrator
 
 import (
+	"errors"
 	"fmt"
-	"runtime"
-	
-	"open-veth/internal/models"
+	"net/netip"
+	"syscall"
 
-	"github.com/vishvananda/netlink"
-	"github.com/vishvananda/netns"
+	"open-veth/internal/models"
 )
 
 // NetworkManager maneja la configuración de red a nivel de kernel
-type NetworkManager struct{}
+type NetworkManager struct {
+	kernel Kernel
+}
 
-// NewNetworkManager crea una nueva instancia
+// NewNetworkManager crea una nueva instancia sobre el kernel del host
 func NewNetworkManager() *NetworkManager {
-	return &NetworkManager{}
+	return &NetworkManager{kernel: netlinkKernel{}}
 }
 
-// runInNs ejecuta una función dentro del namespace de red de un proceso (PID)
-// Se encarga de bloquear el hilo y restaurar el namespace original al finalizar.
-func (nm *NetworkManager) runInNs(pid int, action func() error) error {
-	runtime.LockOSThread()
-	defer runtime.UnlockOSThread()
-
-	// Guardar el namespace original
-	origns, err := netns.Get()
-	if err != nil {
-		return fmt.Errorf("error obteniendo netns original: %v", err)
-	}
-	defer origns.Close()
-
-	// Obtener handle del namespace destino
-	targetNs, err := netns.GetFromPid(pid)
-	if err != nil {
-		return fmt.Errorf("error obteniendo ns del pid %d: %v", pid, err)
-	}
-	defer targetNs.Close()
-
-	// Cambiar al namespace destino
-	if err := netns.Set(targetNs); err != nil {
-		return fmt.Errorf("error cambiando al ns %d: %v", pid, err)
-	}
-
-	// Ejecutar la acción
-	err = action()
-
-	// Volver al namespace original
-	// Es crítico hacer esto antes de retornar, incluso si action() falló
-	if errSwitchBack := netns.Set(origns); errSwitchBack != nil {
-		// Si fallamos en volver, estamos en un estado inconsistente (panic worthy en algunos casos)
-		return fmt.Errorf("CRÍTICO: error volviendo al ns original (error acción: %v): %v", err, errSwitchBack)
-	}
-
-	return err
+// NewNetworkManagerWithKernel crea una instancia sobre otro Kernel (p.ej. FakeKernel en tests)
+func NewNetworkManagerWithKernel(k Kernel) *NetworkManager {
+	return &NetworkManager{kernel: k}
 }
 
 // CreateLink creates a veth pair and connects two namespaces (PIDs)
 func (nm *NetworkManager) CreateLink(link models.Link, pidSource, pidTarget int) error {
-	// Temporary names on host
-	hostVethNameSource := fmt.Sprintf("veth%s_s", link.ID[:5])
-	hostVethNameTarget := fmt.Sprintf("veth%s_t", link.ID[:5])
+	// Temporary names on host (namespaced per lab)
+	hostVethNameSource, hostVethNameTarget := hostVethNames(link)
 
 	// DEFENSIVE CLEANUP: Attempt to delete interfaces if they already exist
 	// This prevents 'file exists' errors if previous operations left residue on the host.
-	if l, _ := netlink.LinkByName(hostVethNameSource); l != nil {
-		_ = netlink.LinkDel(l)
-	}
-	if l, _ := netlink.LinkByName(hostVethNameTarget); l != nil {
-		_ = netlink.LinkDel(l)
-	}
+	_ = nm.kernel.LinkDel(HostPID, hostVethNameSource)
+	_ = nm.kernel.LinkDel(HostPID, hostVethNameTarget)
 
 	// 1. Create veth pair on host
-	veth := &netlink.Veth{
-		LinkAttrs: netlink.LinkAttrs{
-			Name:   hostVethNameSource,
-			TxQLen: 1000,
-		},
-		PeerName: hostVethNameTarget,
-	}
-
-	if err := netlink.LinkAdd(veth); err != nil {
+	if err := nm.kernel.AddVeth(HostPID, hostVethNameSource, hostVethNameTarget, 1000); err != nil {
 		return fmt.Errorf("error creating veth pair: %v", err)
 	}
 
-	// Función helper para mover y renombrar
-	moveAndConfigure := func(ifaceHostName, ifaceContainerName string, pid int) error {
-		// Buscamos la interfaz en el host
-		linkRef, err := netlink.LinkByName(ifaceHostName)
-		if err != nil {
-			return err
-		}
-
-		// Obtenemos el NS del destino solo para moverla (netlink.LinkSetNsFd necesita el FD)
-		targetNs, err := netns.GetFromPid(pid)
-		if err != nil {
-			return err
-		}
-		defer targetNs.Close()
-
-		// Movemos la interfaz
-		if err := netlink.LinkSetNsFd(linkRef, int(targetNs)); err != nil {
-			return fmt.Errorf("error moviendo interfaz: %v", err)
-		}
-
-		// Ahora entramos al NS para renombrarla y levantarla
-		return nm.runInNs(pid, func() error {
-			l, err := netlink.LinkByName(ifaceHostName)
-			if err != nil {
-				return fmt.Errorf("interfaz movida no encontrada: %v", err)
-			}
-			
-			if err := netlink.LinkSetName(l, ifaceContainerName); err != nil {
-				return fmt.Errorf("error renombrando: %v", err)
-			}
-			
-			// Re-buscamos por nuevo nombre
-			l, err = netlink.LinkByName(ifaceContainerName)
-			if err != nil {
-				return err
-			}
-			
-			return netlink.LinkSetUp(l)
-		})
+	// Si un extremo falla borramos el par: el que siga en el host se lleva al otro
+	cleanup := func() {
+		_ = nm.kernel.LinkDel(HostPID, hostVethNameSource)
+		_ = nm.kernel.LinkDel(HostPID, hostVethNameTarget)
 	}
 
 	// 2. Mover extremos
-	if err := moveAndConfigure(hostVethNameSource, link.SourceInt, pidSource); err != nil {
+	if err := nm.moveToNs(hostVethNameSource, link.SourceInt, pidSource); err != nil {
+		cleanup()
 		return fmt.Errorf("fallo configurando source: %v", err)
 	}
-	if err := moveAndConfigure(hostVethNameTarget, link.TargetInt, pidTarget); err != nil {
+	if err := nm.moveToNs(hostVethNameTarget, link.TargetInt, pidTarget); err != nil {
+		cleanup()
 		return fmt.Errorf("fallo configurando target: %v", err)
 	}
 
-	fmt.Printf("Link creado: %s (%s) <--> %s (%s)\n", 
+	fmt.Printf("Link creado: %s (%s) <--> %s (%s)\n",
 		link.SourceID, link.SourceInt, link.TargetID, link.TargetInt)
 
+	// 3. Emulación WAN (si el link la define). Si falla, borrar el extremo source
+	// se lleva también al target: no queda un par huérfano sin su link guardado.
+	if !link.Impairment.IsZero() {
+		if err := nm.ApplyImpairment(pidSource, link.SourceInt, link.Impairment); err != nil {
+			_ = nm.kernel.LinkDel(pidSource, link.SourceInt)
+			return fmt.Errorf("fallo aplicando impairment en source: %v", err)
+		}
+		if err := nm.ApplyImpairment(pidTarget, link.TargetInt, link.Impairment); err != nil {
+			_ = nm.kernel.LinkDel(pidSource, link.SourceInt)
+			return fmt.Errorf("fallo aplicando impairment en target: %v", err)
+		}
+	}
+
 	return nil
 }
 
+// moveToNs mueve una interfaz del host al namespace (PID), la renombra y la levanta.
+// Si el renombrado falla la borra, para no dejar el nombre temporal dentro del nodo.
+func (nm *NetworkManager) moveToNs(hostName, nsName string, pid int) error {
+	if err := nm.kernel.LinkSetNs(HostPID, hostName, pid); err != nil {
+		return fmt.Errorf("error moviendo interfaz: %v", err)
+	}
+
+	// Ahora dentro del NS la renombramos y levantamos
+	if err := nm.kernel.LinkSetName(pid, hostName, nsName); err != nil {
+		_ = nm.kernel.LinkDel(pid, hostName)
+		return fmt.Errorf("error renombrando a %s: %w", nsName, err)
+	}
+	return nm.kernel.LinkSetUp(pid, nsName)
+}
+
+// DeleteLink elimina una interfaz veth dentro del namespace (PID).
+// Borrar un extremo destruye también su par (otro contenedor o puerto de bridge).
+func (nm *NetworkManager) DeleteLink(pid int, ifaceName string) error {
+	if err := nm.kernel.LinkDel(pid, ifaceName); err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			return nil // Ya no existe, idempotente
+		}
+		return fmt.Errorf("error eliminando interfaz %s: %v", ifaceName, err)
+	}
+
+	fmt.Printf("Link eliminado en PID %d: %s\n", pid, ifaceName)
+	return nil
+}
+
+// InterfaceExists indica si una interfaz está presente dentro del namespace (PID)
+func (nm *NetworkManager) InterfaceExists(pid int, ifaceName string) (bool, error) {
+	_, err := nm.kernel.LinkByName(pid, ifaceName)
+	if err == nil {
+		return true, nil
+	}
+	if errors.Is(err, ErrLinkNotFound) {
+		return false, nil
+	}
+	return false, fmt.Errorf("error buscando interfaz %s: %v", ifaceName, err)
+}
+
 // CreateBridge crea un Linux Bridge en el host (actúa como Switch)
 func (nm *NetworkManager) CreateBridge(bridgeName string) error {
 	// Verificar si ya existe
-	if _, err := netlink.LinkByName(bridgeName); err == nil {
+	if _, err := nm.kernel.LinkByName(HostPID, bridgeName); err == nil {
 		return nil // Ya existe, idempotente
 	}
 
-	br := &netlink.Bridge{
-		LinkAttrs: netlink.LinkAttrs{
-			Name: bridgeName,
-		},
-	}
-
-	if err := netlink.LinkAdd(br); err != nil {
+	if err := nm.kernel.AddBridge(HostPID, bridgeName); err != nil {
 		return fmt.Errorf("error creando bridge %s: %v", bridgeName, err)
 	}
 
 	// Levantar el bridge
-	if err := netlink.LinkSetUp(br); err != nil {
+	if err := nm.kernel.LinkSetUp(HostPID, bridgeName); err != nil {
 		return fmt.Errorf("error levantando bridge %s: %v", bridgeName, err)
 	}
 
@@ -165,109 +135,121 @@ func (nm *NetworkManager) CreateBridge(bridgeName string) error {
 	return nil
 }
 
+// DeleteBridge elimina un Linux Bridge junto con todos sus puertos.
+// Borrar el veth del lado host elimina también su par dentro del contenedor.
+func (nm *NetworkManager) DeleteBridge(bridgeName string) error {
+	br, err := nm.kernel.LinkByName(HostPID, bridgeName)
+	if err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			return nil // Ya no existe, idempotente
+		}
+		return fmt.Errorf("error buscando bridge %s: %v", bridgeName, err)
+	}
+
+	links, err := nm.kernel.LinkList(HostPID)
+	if err != nil {
+		return fmt.Errorf("error listando interfaces: %v", err)
+	}
+	for _, l := range links {
+		if l.MasterIndex == br.Index {
+			if err := nm.kernel.LinkDel(HostPID, l.Name); err != nil && !errors.Is(err, ErrLinkNotFound) {
+				return fmt.Errorf("error eliminando puerto %s del bridge: %v", l.Name, err)
+			}
+		}
+	}
+
+	if err := nm.kernel.LinkDel(HostPID, bridgeName); err != nil {
+		return fmt.Errorf("error eliminando bridge %s: %v", bridgeName, err)
+	}
+
+	fmt.Printf("Bridge (Switch) eliminado: %s\n", bridgeName)
+	return nil
+}
+
+// BridgeExists indica si el bridge está presente en el host
+func (nm *NetworkManager) BridgeExists(bridgeName string) bool {
+	l, err := nm.kernel.LinkByName(HostPID, bridgeName)
+	return err == nil && l.Kind == "bridge"
+}
+
 // ConnectNodeToBridge conecta un contenedor (PID) a un Bridge en el host
 func (nm *NetworkManager) ConnectNodeToBridge(pid int, containerIface, bridgeName string) error {
 	// Generar nombres cortos y seguros para evitar limite de 15 chars de Linux
-	// Formato: v<PID>-<Primeras3LetrasIface>
-	// Ej: PID=1234, Iface=eth1 -> v1234-eth
-	suffix := containerIface
-	if len(suffix) > 3 {
-		suffix = suffix[:3]
-	}
-	
-	hostVethName := fmt.Sprintf("v%d-%s", pid, suffix)
+	// Formato: vb<hash(bridge, PID, iface)> -> único por puerto aunque dos ifaces compartan prefijo
+	hostVethName := BridgePortName(bridgeName, pid, containerIface)
 	containerVethTemp := hostVethName + "c" // temp name for container side
 
 	// 1. Crear veth pair
-	veth := &netlink.Veth{
-		LinkAttrs: netlink.LinkAttrs{
-			Name: hostVethName,
-		},
-		PeerName: containerVethTemp,
-	}
-
-	if err := netlink.LinkAdd(veth); err != nil {
+	if err := nm.kernel.AddVeth(HostPID, hostVethName, containerVethTemp, 0); err != nil {
 		return fmt.Errorf("error creando veth para bridge: %v", err)
 	}
 
 	// 2. Conectar lado Host al Bridge
-	bridgeLink, err := netlink.LinkByName(bridgeName)
-	if err != nil {
-		return fmt.Errorf("bridge %s no encontrado: %v", bridgeName, err)
-	}
-
-	hostLink, err := netlink.LinkByName(hostVethName)
-	if err != nil {
-		return err
-	}
-
-	if err := netlink.LinkSetMaster(hostLink, bridgeLink); err != nil {
+	if err := nm.kernel.LinkSetMaster(HostPID, hostVethName, bridgeName); err != nil {
+		_ = nm.kernel.LinkDel(HostPID, hostVethName)
+		if errors.Is(err, ErrLinkNotFound) {
+			return fmt.Errorf("bridge %s no encontrado: %w", bridgeName, err)
+		}
 		return fmt.Errorf("error conectando veth %s al bridge: %v", hostVethName, err)
 	}
 
-	if err := netlink.LinkSetUp(hostLink); err != nil {
+	if err := nm.kernel.LinkSetUp(HostPID, hostVethName); err != nil {
+		_ = nm.kernel.LinkDel(HostPID, hostVethName)
 		return fmt.Errorf("error levantando veth host: %v", err)
 	}
 
-	// 3. Mover lado Container al Namespace
-	// Reutilizamos lógica similar a CreateLink, pero simplificada inline o extraemos funcion Move
-	// Por ahora copiamos la logica de movimiento:
-	
-	targetNs, err := netns.GetFromPid(pid)
-	if err != nil {
-		return err
+	// 3. Mover lado Container al Namespace y configurarlo
+	if err := nm.moveToNs(containerVethTemp, containerIface, pid); err != nil {
+		_ = nm.kernel.LinkDel(HostPID, hostVethName)
+		return fmt.Errorf("error moviendo interfaz al container: %v", err)
 	}
-	defer targetNs.Close()
+	return nil
+}
 
-	peerLink, err := netlink.LinkByName(containerVethTemp)
-	if err != nil {
-		return err
+// SetInterfaceIP asigna una IP/CIDR a una interfaz dentro de un namespace (PID)
+func (nm *NetworkManager) SetInterfaceIP(pid int, ifaceName string, ipCidr string) error {
+	// 1. Validar la IP antes de tocar el kernel
+	if _, err := netip.ParsePrefix(ipCidr); err != nil {
+		return fmt.Errorf("formato IP incorrecto %s: %v", ipCidr, err)
 	}
 
-	if err := netlink.LinkSetNsFd(peerLink, int(targetNs)); err != nil {
-		return fmt.Errorf("error moviendo interfaz al container: %v", err)
+	// 2. Asignar la IP (Replace es idempotente al re-aplicar direcciones persistidas)
+	if err := nm.kernel.AddrReplace(pid, ifaceName, ipCidr); err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			return fmt.Errorf("interfaz %s no encontrada: %w", ifaceName, err)
+		}
+		return fmt.Errorf("error asignando IP: %v", err)
 	}
 
-	// 4. Configurar dentro del Container
-	return nm.runInNs(pid, func() error {
-		l, err := netlink.LinkByName(containerVethTemp)
-		if err != nil {
-			return fmt.Errorf("interfaz movida no encontrada: %v", err)
-		}
-		
-		if err := netlink.LinkSetName(l, containerIface); err != nil {
-			return fmt.Errorf("error renombrando a %s: %v", containerIface, err)
-		}
-		
-		// Re-buscar
-		l, err = netlink.LinkByName(containerIface)
-		if err != nil { return err }
-		
-		return netlink.LinkSetUp(l)
-	})
+	fmt.Printf("IP asignada en PID %d: %s -> %s\n", pid, ifaceName, ipCidr)
+	return nil
 }
 
-// SetInterfaceIP asigna una IP/CIDR a una interfaz dentro de un namespace (PID)
-func (nm *NetworkManager) SetInterfaceIP(pid int, ifaceName string, ipCidr string) error {
-	return nm.runInNs(pid, func() error {
-		// 1. Buscar la interfaz
-		link, err := netlink.LinkByName(ifaceName)
-		if err != nil {
-			return fmt.Errorf("interfaz %s no encontrada: %v", ifaceName, err)
-		}
+// RemoveInterfaceIP quita una IP/CIDR de una interfaz dentro de un namespace (PID)
+func (nm *NetworkManager) RemoveInterfaceIP(pid int, ifaceName string, ipCidr string) error {
+	if _, err := netip.ParsePrefix(ipCidr); err != nil {
+		return fmt.Errorf("formato IP incorrecto %s: %v", ipCidr, err)
+	}
 
-		// 2. Parsear la IP
-		addr, err := netlink.ParseAddr(ipCidr)
-		if err != nil {
-			return fmt.Errorf("formato IP incorrecto %s: %v", ipCidr, err)
+	if err := nm.kernel.AddrDel(pid, ifaceName, ipCidr); err != nil && !errors.Is(err, syscall.EADDRNOTAVAIL) {
+		if errors.Is(err, ErrLinkNotFound) {
+			return fmt.Errorf("interfaz %s no encontrada: %w", ifaceName, err)
 		}
+		return fmt.Errorf("error quitando IP: %v", err)
+	}
 
-		// 3. Asignar la IP
-		if err := netlink.AddrAdd(link, addr); err != nil {
-			return fmt.Errorf("error asignando IP: %v", err)
-		}
+	fmt.Printf("IP quitada en PID %d: %s -> %s\n", pid, ifaceName, ipCidr)
+	return nil
+}
 
-		fmt.Printf("IP asignada en PID %d: %s -> %s\n", pid, ifaceName, ipCidr)
-		return nil
-	})
-}
\ No newline at end of file
+// ListInterfaceIPs devuelve las direcciones (CIDR) presentes en una interfaz dentro de un namespace (PID)
+func (nm *NetworkManager) ListInterfaceIPs(pid int, ifaceName string) ([]string, error) {
+	cidrs, err := nm.kernel.AddrList(pid, ifaceName)
+	if err != nil {
+		if errors.Is(err, ErrLinkNotFound) {
+			return nil, fmt.Errorf("interfaz %s no encontrada: %w", ifaceName, err)
+		}
+		return nil, fmt.Errorf("error listando IPs de %s: %v", ifaceName, err)
+	}
+	return cidrs, nil
+}
@@ -0,0 +1,410 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"math"
+	"open-veth/internal/capture"
+	"open-veth/internal/models"
+	"syscall"
+	"testing"
+
+	"github.com/vishvananda/netlink"
+)
+
+// newTestNetwork arma un NetworkManager sobre el kernel falso con un namespace por PID
+func newTestNetwork(pids ...int) (*NetworkManager, *FakeKernel) {
+	k := NewFakeKernel()
+	for _, pid := range pids {
+		k.AddNamespace(pid)
+	}
+	return NewNetworkManagerWithKernel(k), k
+}
+
+// linkNames devuelve los nombres de las interfaces de un namespace, sin lo
+func linkNames(t *testing.T, k *FakeKernel, pid int) []string {
+	t.Helper()
+	links, err := k.LinkList(pid)
+	if err != nil {
+		t.Fatalf("error listando interfaces del pid %d: %v", pid, err)
+	}
+	var names []string
+	for _, l := range links {
+		if l.Name != "lo" {
+			names = append(names, l.Name)
+		}
+	}
+	return names
+}
+
+func TestCreateLink(t *testing.T) {
+	nm, k := newTestNetwork(100, 200)
+	link := models.Link{ID: "l1", TopologyID: "lab", SourceInt: "eth1", TargetInt: "eth2"}
+
+	if err := nm.CreateLink(link, 100, 200); err != nil {
+		t.Fatalf("CreateLink falló: %v", err)
+	}
+
+	pid, name, ok := k.Peer(100, "eth1")
+	if !ok || pid != 200 || name != "eth2" {
+		t.Fatalf("eth1 debería estar conectada a 200:eth2, se obtuvo %d:%s", pid, name)
+	}
+	for _, end := range []struct {
+		pid  int
+		name string
+	}{{100, "eth1"}, {200, "eth2"}} {
+		l, err := k.LinkByName(end.pid, end.name)
+		if err != nil || !l.Up || l.Kind != "veth" {
+			t.Errorf("%d:%s debería existir levantada: %+v %v", end.pid, end.name, l, err)
+		}
+	}
+	if names := linkNames(t, k, HostPID); len(names) != 0 {
+		t.Errorf("no deberían quedar interfaces en el host: %v", names)
+	}
+}
+
+func TestCreateLinkRemovesResidue(t *testing.T) {
+	nm, k := newTestNetwork(100, 200)
+	link := models.Link{ID: "l1", TopologyID: "lab", SourceInt: "eth1", TargetInt: "eth1"}
+
+	// Un intento anterior dejó el par en el host
+	src, dst := hostVethNames(link)
+	if err := k.AddVeth(HostPID, src, dst, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nm.CreateLink(link, 100, 200); err != nil {
+		t.Fatalf("CreateLink debería limpiar los restos y funcionar: %v", err)
+	}
+	if names := linkNames(t, k, HostPID); len(names) != 0 {
+		t.Errorf("no deberían quedar interfaces en el host: %v", names)
+	}
+}
+
+func TestCreateLinkErrorsLeaveNoResidue(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(k *FakeKernel)
+	}{
+		{"namespace destino inexistente", func(k *FakeKernel) { k.DeleteNamespace(200) }},
+		{"nombre ocupado en el destino", func(k *FakeKernel) { _ = k.AddBridge(200, "eth1") }},
+		{"fallo del kernel al crear el par", func(k *FakeKernel) { k.Fail("AddVeth", syscall.ENOMEM) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nm, k := newTestNetwork(100, 200)
+			tt.setup(k)
+
+			err := nm.CreateLink(models.Link{ID: "l1", SourceInt: "eth1", TargetInt: "eth1"}, 100, 200)
+			if err == nil {
+				t.Fatal("CreateLink debería fallar")
+			}
+			if names := linkNames(t, k, HostPID); len(names) != 0 {
+				t.Errorf("no deberían quedar interfaces en el host: %v", names)
+			}
+			if names := linkNames(t, k, 100); len(names) != 0 {
+				t.Errorf("no debería quedar el extremo source: %v", names)
+			}
+		})
+	}
+}
+
+func TestCreateLinkWithImpairment(t *testing.T) {
+	nm, k := newTestNetwork(100, 200)
+	imp := models.Impairment{Delay: 50, Loss: 1}
+	link := models.Link{ID: "l1", SourceInt: "eth1", TargetInt: "eth1", Impairment: imp}
+
+	if err := nm.CreateLink(link, 100, 200); err != nil {
+		t.Fatalf("CreateLink falló: %v", err)
+	}
+	for _, pid := range []int{100, 200} {
+		if got, _ := k.Impairment(pid, "eth1"); got != imp {
+			t.Errorf("pid %d: impairment %+v, se esperaba %+v", pid, got, imp)
+		}
+	}
+
+	// Un Impairment vacío limpia la interfaz
+	if err := nm.ApplyImpairment(100, "eth1", models.Impairment{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := k.Impairment(100, "eth1"); !got.IsZero() {
+		t.Errorf("el impairment debería haberse limpiado: %+v", got)
+	}
+	if err := nm.ApplyImpairment(100, "eth9", imp); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("se esperaba ErrLinkNotFound, se obtuvo %v", err)
+	}
+}
+
+// TestQdiscLimits verifica que en los límites de Validate los campos de tc no se desborden
+func TestQdiscLimits(t *testing.T) {
+	delayUs := uint64(models.MaxImpairmentDelay) * 1000
+	if delayUs > math.MaxUint32 || uint64(float64(delayUs)*netlink.TickInUsec()) > math.MaxUint32 {
+		t.Errorf("delay_ms=%d no entra en la latencia de netem", models.MaxImpairmentDelay)
+	}
+
+	for _, kbit := range []uint64{1, 1000, models.MaxImpairmentRate} {
+		rate := kbit * 1000 / 8
+		tbf := tbfQdisc(1, kbit)
+		burst := max(rate/100, 3200)
+		if tbf.Rate != rate || uint64(tbf.Limit) != rate/20+burst {
+			t.Errorf("rate_kbit=%d: rate %d limit %d, se esperaba %d y %d", kbit, tbf.Rate, tbf.Limit, rate, rate/20+burst)
+		}
+	}
+}
+
+func TestCreateLinkImpairmentErrorLeavesNoResidue(t *testing.T) {
+	nm, k := newTestNetwork(100, 200)
+	k.Fail("SetImpairment", syscall.EINVAL)
+
+	link := models.Link{ID: "l1", SourceInt: "eth1", TargetInt: "eth1", Impairment: models.Impairment{Delay: 10}}
+	if err := nm.CreateLink(link, 100, 200); err == nil {
+		t.Fatal("CreateLink debería fallar")
+	}
+	for _, pid := range []int{HostPID, 100, 200} {
+		if names := linkNames(t, k, pid); len(names) != 0 {
+			t.Errorf("pid %d: no deberían quedar interfaces: %v", pid, names)
+		}
+	}
+
+	// Un reintento no choca con el nombre ocupado
+	k.Fail("SetImpairment", nil)
+	if err := nm.CreateLink(link, 100, 200); err != nil {
+		t.Errorf("el reintento debería funcionar: %v", err)
+	}
+}
+
+func TestDeleteLink(t *testing.T) {
+	nm, _ := newTestNetwork(100, 200)
+	if err := nm.CreateLink(models.Link{ID: "l1", SourceInt: "eth1", TargetInt: "eth1"}, 100, 200); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nm.DeleteLink(100, "eth1"); err != nil {
+		t.Fatalf("DeleteLink falló: %v", err)
+	}
+	if ok, _ := nm.InterfaceExists(200, "eth1"); ok {
+		t.Error("borrar un extremo debería borrar también el par")
+	}
+	if err := nm.DeleteLink(100, "eth1"); err != nil {
+		t.Errorf("DeleteLink debería ser idempotente: %v", err)
+	}
+	if _, err := nm.InterfaceExists(300, "eth1"); err == nil {
+		t.Error("un namespace inexistente debería dar error")
+	}
+}
+
+func TestBridgePorts(t *testing.T) {
+	nm, k := newTestNetwork(100, 200)
+	sw := models.Node{ID: "sw1", TopologyID: "lab", Type: models.SWITCH}
+	br := BridgeName(sw)
+
+	if err := nm.CreateBridge(br); err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.CreateBridge(br); err != nil {
+		t.Errorf("CreateBridge debería ser idempotente: %v", err)
+	}
+	if !nm.BridgeExists(br) {
+		t.Fatal("el bridge debería existir")
+	}
+
+	// Dos nodos con la misma interfaz necesitan puertos distintos
+	for _, pid := range []int{100, 200} {
+		if err := nm.ConnectNodeToBridge(pid, "eth1", br); err != nil {
+			t.Fatalf("ConnectNodeToBridge(%d) falló: %v", pid, err)
+		}
+	}
+	bridge, _ := k.LinkByName(HostPID, br)
+	ports := map[string]bool{}
+	for _, pid := range []int{100, 200} {
+		_, port, ok := k.Peer(pid, "eth1")
+		l, _ := k.LinkByName(HostPID, port)
+		if !ok || l.MasterIndex != bridge.Index || !l.Up {
+			t.Errorf("pid %d: eth1 debería terminar en un puerto levantado del bridge (%+v)", pid, l)
+		}
+		if len(port) > 15 {
+			t.Errorf("nombre de puerto demasiado largo: %s", port)
+		}
+		ports[port] = true
+	}
+	if len(ports) != 2 {
+		t.Errorf("se esperaban dos puertos distintos: %v", ports)
+	}
+
+	// Borrar el bridge se lleva sus puertos y los extremos dentro de los nodos
+	if err := nm.DeleteBridge(br); err != nil {
+		t.Fatal(err)
+	}
+	if nm.BridgeExists(br) {
+		t.Error("el bridge no debería existir")
+	}
+	for _, pid := range []int{HostPID, 100, 200} {
+		if names := linkNames(t, k, pid); len(names) != 0 {
+			t.Errorf("pid %d: quedaron interfaces %v", pid, names)
+		}
+	}
+	if err := nm.DeleteBridge(br); err != nil {
+		t.Errorf("DeleteBridge debería ser idempotente: %v", err)
+	}
+}
+
+func TestConnectNodeToMissingBridge(t *testing.T) {
+	nm, k := newTestNetwork(100)
+
+	err := nm.ConnectNodeToBridge(100, "eth1", "brmissing")
+	if !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("se esperaba ErrLinkNotFound, se obtuvo %v", err)
+	}
+	if names := linkNames(t, k, HostPID); len(names) != 0 {
+		t.Errorf("no deberían quedar interfaces en el host: %v", names)
+	}
+}
+
+func TestInterfaceIPs(t *testing.T) {
+	nm, k := newTestNetwork(100, 200)
+	if err := nm.CreateLink(models.Link{ID: "l1", SourceInt: "eth1", TargetInt: "eth1"}, 100, 200); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cidr := range []string{"10.0.0.1/30", "10.0.0.1/30", "2001:DB8::1/64"} {
+		if err := nm.SetInterfaceIP(100, "eth1", cidr); err != nil {
+			t.Fatalf("SetInterfaceIP(%s) falló: %v", cidr, err)
+		}
+	}
+	cidrs, err := nm.ListInterfaceIPs(100, "eth1")
+	if err != nil || len(cidrs) != 2 || cidrs[1] != "2001:db8::1/64" {
+		t.Errorf("direcciones inesperadas: %v %v", cidrs, err)
+	}
+
+	if err := nm.SetInterfaceIP(100, "eth1", "10.0.0.300/30"); err == nil {
+		t.Error("una IP inválida debería fallar")
+	}
+	if err := nm.SetInterfaceIP(100, "eth9", "10.0.0.1/30"); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("se esperaba ErrLinkNotFound, se obtuvo %v", err)
+	}
+
+	for range 2 {
+		if err := nm.RemoveInterfaceIP(100, "eth1", "10.0.0.1/30"); err != nil {
+			t.Errorf("RemoveInterfaceIP debería ser idempotente: %v", err)
+		}
+	}
+	if cidrs, _ := k.AddrList(100, "eth1"); len(cidrs) != 1 {
+		t.Errorf("debería quedar solo la IPv6: %v", cidrs)
+	}
+}
+
+func TestStoppedNodePID(t *testing.T) {
+	nm, k := newTestNetwork(100)
+	_ = k.AddBridge(HostPID, "eth1")
+
+	// PID 0 es un nodo parado, no el host
+	if err := nm.SetInterfaceIP(0, "eth1", "10.0.0.1/30"); err == nil {
+		t.Error("asignar una IP con PID 0 debería fallar")
+	}
+	if err := nm.ApplyImpairment(0, "eth1", models.Impairment{Delay: 10}); !errors.Is(err, ErrNoNamespace) {
+		t.Errorf("se esperaba ErrNoNamespace, se obtuvo %v", err)
+	}
+	if err := nm.CreateLink(models.Link{ID: "l1", SourceInt: "eth1", TargetInt: "eth1"}, 100, 0); err == nil {
+		t.Error("un link hacia un nodo parado debería fallar")
+	}
+	if cidrs, _ := k.AddrList(HostPID, "eth1"); len(cidrs) != 0 {
+		t.Errorf("el host no debería tener direcciones: %v", cidrs)
+	}
+	if imp, _ := k.Impairment(HostPID, "eth1"); !imp.IsZero() {
+		t.Errorf("el host no debería tener impairment: %+v", imp)
+	}
+}
+
+func TestFakeKernelRules(t *testing.T) {
+	k := NewFakeKernel()
+	k.AddNamespace(100)
+
+	if err := k.AddVeth(HostPID, "veth-name-too-long", "p", 0); !errors.Is(err, syscall.EINVAL) {
+		t.Errorf("un nombre de más de 15 caracteres debería fallar: %v", err)
+	}
+	if err := k.AddVeth(HostPID, "a", "b", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.AddBridge(HostPID, "a"); !errors.Is(err, syscall.EEXIST) {
+		t.Errorf("un nombre repetido debería fallar: %v", err)
+	}
+	_ = k.LinkSetUp(HostPID, "a")
+	if err := k.LinkSetName(HostPID, "a", "c"); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("renombrar una interfaz levantada debería fallar: %v", err)
+	}
+
+	// Mover deja la interfaz abajo y sin direcciones; morir el proceso se lleva al par
+	_ = k.AddrReplace(HostPID, "a", "10.0.0.1/24")
+	if err := k.LinkSetNs(HostPID, "a", 100); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := k.LinkByName(100, "a"); l.Up {
+		t.Error("la interfaz movida debería estar abajo")
+	}
+	if cidrs, _ := k.AddrList(100, "a"); len(cidrs) != 0 {
+		t.Errorf("la interfaz movida no debería conservar direcciones: %v", cidrs)
+	}
+	k.DeleteNamespace(100)
+	if _, err := k.LinkByName(HostPID, "b"); !errors.Is(err, ErrLinkNotFound) {
+		t.Errorf("el par en el host debería desaparecer con el namespace: %v", err)
+	}
+}
+
+func TestFakeKernelWatchAndCapture(t *testing.T) {
+	nm, k := newTestNetwork(100, 200)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := nm.WatchNamespace(ctx, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev := <-events; ev.Interface != "lo" || !ev.Up {
+		t.Errorf("primero deberían llegar las interfaces existentes: %+v", ev)
+	}
+	if _, err := nm.WatchNamespace(ctx, 0); !errors.Is(err, ErrNoNamespace) {
+		t.Errorf("un nodo parado no debería poder observarse: %v", err)
+	}
+
+	if err := nm.CreateLink(models.Link{ID: "l1", SourceInt: "eth1", TargetInt: "eth1"}, 100, 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := nm.SetInterfaceIP(100, "eth1", "10.0.0.1/30"); err != nil {
+		t.Fatal(err)
+	}
+	var got []KernelEvent
+	for len(events) > 0 {
+		got = append(got, <-events)
+	}
+	up := KernelEvent{Interface: "eth1", Kind: "link", Up: true}
+	addr := KernelEvent{Interface: "eth1", Kind: "addr", Address: "10.0.0.1/30"}
+	if len(got) < 2 || got[len(got)-2] != up || got[len(got)-1] != addr {
+		t.Errorf("se esperaba eth1 levantada y con dirección: %+v", got)
+	}
+
+	// Lo que sale por un extremo entra por el otro
+	sock, err := nm.OpenPacketSocket(200, "eth1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sock.Close()
+	if _, err := nm.OpenPacketSocket(200, "eth9", nil); err == nil {
+		t.Error("capturar en una interfaz que no existe debería fallar")
+	}
+	if err := k.InjectPacket(100, "eth1", []byte("frame")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 3)
+	n, origLen, dir, err := sock.ReadPacket(ctx, buf)
+	if err != nil || n != 3 || origLen != 5 || dir != capture.DirInbound {
+		t.Errorf("paquete inesperado: n=%d len=%d dir=%v err=%v", n, origLen, dir, err)
+	}
+
+	// Morir el proceso cierra el watcher y corta la captura del par
+	k.DeleteNamespace(100)
+	for range events {
+	}
+	if _, _, _, err := sock.ReadPacket(ctx, buf); !errors.Is(err, syscall.ENETDOWN) {
+		t.Errorf("se esperaba ENETDOWN, se obtuvo %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package orchestrator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"open-veth/internal/models"
+
+	"github.com/docker/go-units"
+)
+
+// minMemory es el mínimo que Docker acepta como límite de memoria (6 MiB)
+const minMemory = 6 * 1024 * 1024
+
+// maxCPUs acota los pedidos de CPU: más allá no hay host real y NanoCPUs desborda
+const maxCPUs = 1024
+
+// ParseCPU convierte una cantidad de CPUs ("0.5", "2" o milicores "500m") a NanoCPUs.
+// Una cadena vacía significa sin límite (0).
+func ParseCPU(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	var cpus float64
+	var err error
+	if milli, ok := strings.CutSuffix(value, "m"); ok {
+		var m float64
+		m, err = strconv.ParseFloat(milli, 64)
+		cpus = m / 1000
+	} else {
+		cpus, err = strconv.ParseFloat(value, 64)
+	}
+	if err != nil || math.IsNaN(cpus) || math.IsInf(cpus, 0) || cpus <= 0 {
+		return 0, fmt.Errorf("%q: expected a positive number of CPUs (e.g. 0.5 or 500m)", value)
+	}
+	if cpus > maxCPUs {
+		return 0, fmt.Errorf("%q: maximum is %d CPUs", value, maxCPUs)
+	}
+
+	// 0 NanoCPUs es "sin límite" para Docker: un valor tan chico no puede pasar por uno
+	nano := int64(cpus * 1e9)
+	if nano == 0 {
+		return 0, fmt.Errorf("%q: minimum is 1 nanoCPU (0.000000001)", value)
+	}
+	return nano, nil
+}
+
+// ParseMemory convierte un tamaño ("256m", "1g", "512MiB") a bytes.
+// Una cadena vacía significa sin límite (0).
+func ParseMemory(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+
+	bytes, err := units.RAMInBytes(value)
+	if err != nil || bytes <= 0 {
+		return 0, fmt.Errorf("%q: expected a size such as 256m or 1g", value)
+	}
+	if bytes < minMemory {
+		return 0, fmt.Errorf("%q: minimum is 6m", value)
+	}
+	return bytes, nil
+}
+
+// NodeResources valida y traduce los límites de un nodo a valores de Docker
+func NodeResources(node models.Node) (nanoCPUs int64, memory int64, err error) {
+	if nanoCPUs, err = ParseCPU(node.CPURequest); err != nil {
+		return 0, 0, fmt.Errorf("invalid cpu_request %v", err)
+	}
+	if memory, err = ParseMemory(node.RAMLimit); err != nil {
+		return 0, 0, fmt.Errorf("invalid ram_limit %v", err)
+	}
+	return nanoCPUs, memory, nil
+}
@@ -0,0 +1,53 @@
+package orchestrator
+
+import "testing"
+
+// TestParseCPU verifica la conversión de CPUs a NanoCPUs de Docker
+func TestParseCPU(t *testing.T) {
+	cases := map[string]int64{
+		"":     0,
+		"0.5":  500000000,
+		"2":    2000000000,
+		"250m": 250000000,
+	}
+	for in, want := range cases {
+		got, err := ParseCPU(in)
+		if err != nil {
+			t.Fatalf("ParseCPU(%q) falló: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("ParseCPU(%q) = %d, se esperaba %d", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"-1", "0", "abc", "m", "NaN", "Inf", "-Inf", "1e300", "2000", "0.0000000001", "0.0000001m"} {
+		if _, err := ParseCPU(in); err == nil {
+			t.Errorf("ParseCPU(%q) debería fallar", in)
+		}
+	}
+}
+
+// TestParseMemory verifica la conversión de tamaños a bytes
+func TestParseMemory(t *testing.T) {
+	cases := map[string]int64{
+		"":       0,
+		"256m":   256 * 1024 * 1024,
+		"1g":     1024 * 1024 * 1024,
+		"512MiB": 512 * 1024 * 1024,
+	}
+	for in, want := range cases {
+		got, err := ParseMemory(in)
+		if err != nil {
+			t.Fatalf("ParseMemory(%q) falló: %v", in, err)
+		}
+		if got != want {
+			t.Errorf("ParseMemory(%q) = %d, se esperaba %d", in, got, want)
+		}
+	}
+
+	for _, in := range []string{"lots", "1k", "-5m"} {
+		if _, err := ParseMemory(in); err == nil {
+			t.Errorf("ParseMemory(%q) debería fallar", in)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package orchestrator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"open-veth/internal/models"
+)
+
+// ErrNodeState is returned when a lifecycle action does not fit the node's current
+// state (e.g. unpausing a node that is not paused)
+var ErrNodeState = errors.New("invalid node state")
+
+// NodeRuntime runs the processes behind lab nodes. Manager implements it with
+// Docker containers, NamespaceRuntime with bare network namespaces; FakeRuntime
+// keeps everything in memory for tests.
+// Switches are host bridges and never reach the runtime.
+type NodeRuntime interface {
+	// Create and delete
+	CreateNode(ctx context.Context, node models.Node) (string, error) // Returns the runtime ID
+	DeleteNode(ctx context.Context, ref string) error                 // Runtime ID or name
+
+	// Lifecycle
+	StartNode(ctx context.Context, id string) error
+	StopNode(ctx context.Context, id string) error
+	RestartNode(ctx context.Context, id string) error
+	PauseNode(ctx context.Context, id string) error
+	UnpauseNode(ctx context.Context, id string) error
+
+	// Inspect and list
+	GetNodePID(ctx context.Context, id string) (int, error)  // Fails if the node is not running
+	NodePaused(ctx context.Context, id string) (bool, error) // A paused node still reports a PID
+	FindNodeContainer(ctx context.Context, node models.Node) (string, bool, error)
+	GetNodeInterfaces(ctx context.Context, id string) ([]models.InterfaceInfo, error)
+	ListNodes(ctx context.Context) ([]RuntimeNode, error) // Every OpenVeth node, running or not
+	WatchContainers(ctx context.Context) (<-chan ContainerEvent, <-chan error)
+
+	// Exec and attach
+	Exec(ctx context.Context, id string, cmd []string, timeout time.Duration) (ExecResult, error)
+	ExecStream(ctx context.Context, id string, cmd []string, stdout, stderr io.Writer) (int, error)
+	OpenTerminal(ctx context.Context, id string, cmd []string, cols, rows uint) (*TermSession, error)
+	ResizeTerminal(ctx context.Context, execID string, cols, rows uint) error
+
+	// Router configuration (FRR)
+	ApplyStartupConfig(ctx context.Context, id string, node models.Node) error
+	RunningConfig(ctx context.Context, id string) (string, error)
+	RestoreFRRConfig(ctx context.Context, id, config string) error
+}
+
+var _ NodeRuntime = (*Manager)(nil)
+
+// RuntimeNode is a node process as the runtime sees it
+type RuntimeNode struct {
+	ID         string `json:"id"`
+	NodeName   string `json:"node_name"`   // Label openveth.name
+	TopologyID string `json:"topology_id"` // Label openveth.topology
+	Running    bool   `json:"running"`
+}
+
+// MultiRuntime runs container nodes on one runtime and namespace-only nodes on
+// another, routing by node kind or by the "netns:" prefix of runtime IDs
+type MultiRuntime struct {
+	containers NodeRuntime
+	namespaces NodeRuntime
+}
+
+var _ NodeRuntime = (*MultiRuntime)(nil)
+
+func NewMultiRuntime(containers, namespaces NodeRuntime) *MultiRuntime {
+	return &MultiRuntime{containers: containers, namespaces: namespaces}
+}
+
+func (m *MultiRuntime) byID(id string) NodeRuntime {
+	if IsNamespaceID(id) {
+		return m.namespaces
+	}
+	return m.containers
+}
+
+func (m *MultiRuntime) byNode(node models.Node) NodeRuntime {
+	if node.RuntimeKind() == models.RuntimeNamespace {
+		return m.namespaces
+	}
+	return m.containers
+}
+
+func (m *MultiRuntime) CreateNode(ctx context.Context, node models.Node) (string, error) {
+	return m.byNode(node).CreateNode(ctx, node)
+}
+
+// DeleteNode tries both runtimes for a bare name, since either may own it
+func (m *MultiRuntime) DeleteNode(ctx context.Context, ref string) error {
+	if IsNamespaceID(ref) {
+		return m.namespaces.DeleteNode(ctx, ref)
+	}
+	if err := m.namespaces.DeleteNode(ctx, ref); err != nil {
+		return err
+	}
+	return m.containers.DeleteNode(ctx, ref)
+}
+
+func (m *MultiRuntime) StartNode(ctx context.Context, id string) error {
+	return m.byID(id).StartNode(ctx, id)
+}
+
+func (m *MultiRuntime) StopNode(ctx context.Context, id string) error {
+	return m.byID(id).StopNode(ctx, id)
+}
+
+func (m *MultiRuntime) RestartNode(ctx context.Context, id string) error {
+	return m.byID(id).RestartNode(ctx, id)
+}
+
+func (m *MultiRuntime) PauseNode(ctx context.Context, id string) error {
+	return m.byID(id).PauseNode(ctx, id)
+}
+
+func (m *MultiRuntime) UnpauseNode(ctx context.Context, id string) error {
+	return m.byID(id).UnpauseNode(ctx, id)
+}
+
+func (m *MultiRuntime) GetNodePID(ctx context.Context, id string) (int, error) {
+	return m.byID(id).GetNodePID(ctx, id)
+}
+
+func (m *MultiRuntime) NodePaused(ctx context.Context, id string) (bool, error) {
+	return m.byID(id).NodePaused(ctx, id)
+}
+
+func (m *MultiRuntime) FindNodeContainer(ctx context.Context, node models.Node) (string, bool, error) {
+	return m.byNode(node).FindNodeContainer(ctx, node)
+}
+
+func (m *MultiRuntime) GetNodeInterfaces(ctx context.Context, id string) ([]models.InterfaceInfo, error) {
+	return m.byID(id).GetNodeInterfaces(ctx, id)
+}
+
+func (m *MultiRuntime) ListNodes(ctx context.Context) ([]RuntimeNode, error) {
+	list, err := m.containers.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := m.namespaces.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(list, namespaces...), nil
+}
+
+// WatchContainers merges the events of both runtimes. The first error ends both
+// streams, so the caller reconnects them together.
+func (m *MultiRuntime) WatchContainers(ctx context.Context) (<-chan ContainerEvent, <-chan error) {
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan ContainerEvent)
+	errs := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	for _, rt := range []NodeRuntime{m.containers, m.namespaces} {
+		events, rtErrs := rt.WatchContainers(ctx)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ev := range events {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+				}
+			}
+			select {
+			case err := <-rtErrs:
+				select {
+				case errs <- err:
+				default:
+				}
+				cancel()
+			default:
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		cancel()
+		close(out)
+	}()
+
+	return out, errs
+}
+
+func (m *MultiRuntime) Exec(ctx context.Context, id string, cmd []string, timeout time.Duration) (ExecResult, error) {
+	return m.byID(id).Exec(ctx, id, cmd, timeout)
+}
+
+func (m *MultiRuntime) ExecStream(ctx context.Context, id string, cmd []string, stdout, stderr io.Writer) (int, error) {
+	return m.byID(id).ExecStream(ctx, id, cmd, stdout, stderr)
+}
+
+func (m *MultiRuntime) OpenTerminal(ctx context.Context, id string, cmd []string, cols, rows uint) (*TermSession, error) {
+	return m.byID(id).OpenTerminal(ctx, id, cmd, cols, rows)
+}
+
+// ResizeTerminal routes by the exec ID, which keeps the prefix of its node
+func (m *MultiRuntime) ResizeTerminal(ctx context.Context, execID string, cols, rows uint) error {
+	return m.byID(execID).ResizeTerminal(ctx, execID, cols, rows)
+}
+
+func (m *MultiRuntime) ApplyStartupConfig(ctx context.Context, id string, node models.Node) error {
+	return m.byID(id).ApplyStartupConfig(ctx, id, node)
+}
+
+func (m *MultiRuntime) RunningConfig(ctx context.Context, id string) (string, error) {
+	return m.byID(id).RunningConfig(ctx, id)
+}
+
+func (m *MultiRuntime) RestoreFRRConfig(ctx context.Context, id, config string) error {
+	return m.byID(id).RestoreFRRConfig(ctx, id, config)
+}
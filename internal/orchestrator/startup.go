@@ -0,0 +1,171 @@
+package orchestrator
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"open-veth/internal/models"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Paths used by the FRR images (frrouting/frr)
+const (
+	frrConfPath    = "/etc/frr/frr.conf"
+	frrDaemonsPath = "/etc/frr/daemons"
+	frrReloadCmd   = "/usr/lib/frr/frr-reload.py"
+)
+
+// Startup commands and FRR tooling must not block a deploy forever
+const (
+	startupCommandTimeout = 60 * time.Second
+	frrCommandTimeout     = 30 * time.Second
+)
+
+// startupFiles flattens a startup config into the files to write in the container
+func startupFiles(cfg models.StartupConfig) map[string]string {
+	files := make(map[string]string, len(cfg.Files)+2)
+	for p, content := range cfg.Files {
+		files[p] = content
+	}
+	if cfg.FRRConf != "" {
+		files[frrConfPath] = cfg.FRRConf
+	}
+	if cfg.Daemons != "" {
+		files[frrDaemonsPath] = cfg.Daemons
+	}
+	return files
+}
+
+// copyFiles writes files into a container (created or running) as a single tar archive.
+// Missing parent directories are created by Docker on extraction.
+func (m *Manager) copyFiles(ctx context.Context, containerID string, files map[string]string) error {
+	if len(files) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	now := time.Now()
+	for _, p := range paths {
+		content := files[p]
+		hdr := &tar.Header{
+			Name:    strings.TrimPrefix(p, "/"),
+			Mode:    0644,
+			Size:    int64(len(content)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("error packing %s: %v", p, err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			return fmt.Errorf("error packing %s: %v", p, err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error packing startup files: %v", err)
+	}
+
+	if err := m.cli.CopyToContainer(ctx, containerID, "/", &buf, container.CopyToContainerOptions{}); err != nil {
+		return fmt.Errorf("error copying startup files: %v", err)
+	}
+	return nil
+}
+
+// runStartupCommands runs the startup commands of a node in order and stops at the first failure
+func (m *Manager) runStartupCommands(ctx context.Context, containerID string, cmds []string) error {
+	for _, cmd := range cmds {
+		if _, err := m.execOK(ctx, containerID, []string{"sh", "-c", cmd}, startupCommandTimeout); err != nil {
+			return fmt.Errorf("startup command %q: %v", cmd, err)
+		}
+	}
+	return nil
+}
+
+// fixFRROwnership hands the injected FRR files to the frr user so 'write memory' keeps working
+func (m *Manager) fixFRROwnership(ctx context.Context, containerID string) error {
+	if _, err := m.execOK(ctx, containerID, []string{"chown", "-R", "frr:frr", "/etc/frr"}, frrCommandTimeout); err != nil {
+		return fmt.Errorf("error setting /etc/frr ownership: %v", err)
+	}
+	return nil
+}
+
+// ApplyStartupConfig writes the startup files of a node into its running container and
+// reloads FRR. The daemons file is only read by watchfrr (PID 1 of the FRR image), so
+// changing it restarts the container; links are then restored by the event watcher.
+// Startup commands are not re-run: they only run when the container is created.
+func (m *Manager) ApplyStartupConfig(ctx context.Context, containerID string, node models.Node) error {
+	cfg := node.Startup
+	if err := m.copyFiles(ctx, containerID, startupFiles(cfg)); err != nil {
+		return err
+	}
+	if node.Type != models.ROUTER || (cfg.FRRConf == "" && cfg.Daemons == "") {
+		return nil
+	}
+
+	if err := m.fixFRROwnership(ctx, containerID); err != nil {
+		return err
+	}
+
+	if cfg.Daemons != "" {
+		return m.RestartNode(ctx, containerID)
+	}
+
+	return m.reloadFRR(ctx, containerID)
+}
+
+// reloadFRR applies /etc/frr/frr.conf to the running daemons without restarting them
+func (m *Manager) reloadFRR(ctx context.Context, containerID string) error {
+	if _, err := m.execOK(ctx, containerID, []string{frrReloadCmd, "--reload", frrConfPath}, frrCommandTimeout); err != nil {
+		return fmt.Errorf("error reloading FRR: %v", err)
+	}
+	return nil
+}
+
+// RunningConfig returns the output of 'show running-config' of a router
+func (m *Manager) RunningConfig(ctx context.Context, containerID string) (string, error) {
+	res, err := m.execOK(ctx, containerID, []string{"vtysh", "-c", "show running-config"}, frrCommandTimeout)
+	if err != nil {
+		return "", fmt.Errorf("vtysh %v", err)
+	}
+
+	// Drop the "Building configuration..." banner so the output is a valid frr.conf
+	config := res.Stdout
+	if i := strings.Index(config, "frr version"); i > 0 {
+		config = config[i:]
+	}
+	return config, nil
+}
+
+// RestoreFRRConfig replaces frr.conf in a running router and reloads FRR
+func (m *Manager) RestoreFRRConfig(ctx context.Context, containerID, config string) error {
+	if err := m.copyFiles(ctx, containerID, map[string]string{frrConfPath: config}); err != nil {
+		return err
+	}
+	if err := m.fixFRROwnership(ctx, containerID); err != nil {
+		return err
+	}
+	return m.reloadFRR(ctx, containerID)
+}
+
+// finishStartup completes the startup config of a freshly started container
+func (m *Manager) finishStartup(ctx context.Context, containerID string, node models.Node) error {
+	cfg := node.Startup
+	if node.Type == models.ROUTER && (cfg.FRRConf != "" || cfg.Daemons != "") {
+		if err := m.fixFRROwnership(ctx, containerID); err != nil {
+			return err
+		}
+	}
+	return m.runStartupCommands(ctx, containerID, cfg.Commands)
+}
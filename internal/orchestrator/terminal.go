@@ -0,0 +1,66 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// TermSession is an interactive TTY process inside a node
+type TermSession struct {
+	ExecID string
+	Conn   io.Writer // Write side (stdin)
+	Reader io.Reader // TTY output (stdout and stderr are merged by the TTY)
+
+	close func()
+}
+
+// Close ends the attachment; the process gets SIGHUP from the closed TTY
+func (t *TermSession) Close() {
+	t.close()
+}
+
+// OpenTerminal starts cmd with a TTY of cols x rows (0 = Docker default) and attaches to it
+func (m *Manager) OpenTerminal(ctx context.Context, containerID string, cmd []string, cols, rows uint) (*TermSession, error) {
+	var size *[2]uint
+	if cols > 0 && rows > 0 {
+		size = &[2]uint{rows, cols} // Docker expects [height, width]
+	}
+
+	execResp, err := m.cli.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+		Tty:          true,
+		ConsoleSize:  size,
+		Cmd:          cmd,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating exec: %v", err)
+	}
+
+	resp, err := m.cli.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{
+		Tty:         true,
+		ConsoleSize: size,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error attaching to exec: %v", err)
+	}
+
+	return &TermSession{
+		ExecID: execResp.ID,
+		Conn:   resp.Conn,
+		Reader: resp.Reader,
+		close:  resp.Close,
+	}, nil
+}
+
+// ResizeTerminal changes the TTY size of a terminal exec
+func (m *Manager) ResizeTerminal(ctx context.Context, execID string, cols, rows uint) error {
+	if err := m.cli.ContainerExecResize(ctx, execID, container.ResizeOptions{Height: rows, Width: cols}); err != nil {
+		return fmt.Errorf("error resizing terminal: %v", err)
+	}
+	return nil
+}
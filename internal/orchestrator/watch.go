@@ -0,0 +1,114 @@
+package orchestrator
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/vishvananda/netlink"
+	"github.com/vishvananda/netns"
+	"golang.org/x/sys/unix"
+)
+
+// KernelEvent es un cambio de interfaz o dirección observado dentro de un namespace
+type KernelEvent struct {
+	Interface string `json:"interface"`
+	Kind      string `json:"kind"`              // "link" | "addr"
+	Up        bool   `json:"up"`                // Kind == "link": estado operativo
+	Deleted   bool   `json:"deleted"`           // Interfaz o dirección eliminada
+	Address   string `json:"address,omitempty"` // Kind == "addr": CIDR
+}
+
+// WatchNamespace sigue los cambios de links y direcciones del namespace del proceso (PID).
+// El canal se cierra cuando ctx se cancela o el namespace desaparece.
+func (nm *NetworkManager) WatchNamespace(ctx context.Context, pid int) (<-chan KernelEvent, error) {
+	return nm.kernel.WatchNamespace(ctx, pid)
+}
+
+// WatchNamespace se suscribe por netlink al namespace; ListExisting reporta primero las interfaces actuales
+func (netlinkKernel) WatchNamespace(ctx context.Context, pid int) (<-chan KernelEvent, error) {
+	if err := checkPID(pid); err != nil {
+		return nil, err
+	}
+	ns, err := netns.GetFromPid(pid)
+	if err != nil {
+		return nil, fmt.Errorf("error obteniendo ns del pid %d: %v", pid, err)
+	}
+
+	done := make(chan struct{})
+	linkCh := make(chan netlink.LinkUpdate, 32)
+	addrCh := make(chan netlink.AddrUpdate, 32)
+
+	if err := netlink.LinkSubscribeWithOptions(linkCh, done, netlink.LinkSubscribeOptions{Namespace: &ns, ListExisting: true}); err != nil {
+		ns.Close()
+		return nil, fmt.Errorf("error suscribiendo links del pid %d: %v", pid, err)
+	}
+	if err := netlink.AddrSubscribeWithOptions(addrCh, done, netlink.AddrSubscribeOptions{Namespace: &ns}); err != nil {
+		close(done)
+		ns.Close()
+		return nil, fmt.Errorf("error suscribiendo direcciones del pid %d: %v", pid, err)
+	}
+
+	out := make(chan KernelEvent, 32)
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			close(done)
+			ns.Close()
+		})
+	}
+
+	go func() {
+		defer close(out)
+		defer stop()
+
+		names := make(map[int]string) // ifindex -> nombre (las direcciones solo traen el índice)
+		up := make(map[int]bool)
+		for {
+			var ev KernelEvent
+			select {
+			case <-ctx.Done():
+				return
+			case u, ok := <-linkCh:
+				if !ok {
+					return
+				}
+				attrs := u.Link.Attrs()
+				names[attrs.Index] = attrs.Name
+				ev = KernelEvent{
+					Interface: attrs.Name,
+					Kind:      "link",
+					Up:        attrs.OperState == netlink.OperUp || attrs.Flags&net.FlagUp != 0 && attrs.OperState == netlink.OperUnknown,
+					Deleted:   u.Header.Type == unix.RTM_DELLINK,
+				}
+				// Solo reportar transiciones, no cada actualización de estadísticas
+				if prev, seen := up[attrs.Index]; seen && prev == ev.Up && !ev.Deleted {
+					continue
+				}
+				up[attrs.Index] = ev.Up
+				if ev.Deleted {
+					delete(up, attrs.Index)
+				}
+			case u, ok := <-addrCh:
+				if !ok {
+					return
+				}
+				ev = KernelEvent{
+					Interface: names[u.LinkIndex],
+					Kind:      "addr",
+					Deleted:   !u.NewAddr,
+					Address:   u.LinkAddress.String(),
+				}
+			}
+
+			select {
+			case out <- ev:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,204 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Tipos de evento de asciicast v2
+const (
+	EventOutput = "o" // Salida de la terminal
+	EventInput  = "i" // Teclado
+	EventResize = "r" // Cambio de tamaño, data = "COLSxROWS"
+)
+
+// Header es la primera línea de un archivo asciicast v2
+type Header struct {
+	Version   int               `json:"version"`
+	Width     uint              `json:"width"`
+	Height    uint              `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+// Event es una línea [tiempo, tipo, datos] de un asciicast
+type Event struct {
+	Time float64 // Segundos desde el inicio
+	Type string
+	Data string
+}
+
+// UnmarshalJSON decodifica el arreglo [tiempo, tipo, datos]
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 3 {
+		return fmt.Errorf("evento con %d campos, se esperaban 3", len(raw))
+	}
+	if err := json.Unmarshal(raw[0], &e.Time); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(raw[1], &e.Type); err != nil {
+		return err
+	}
+	return json.Unmarshal(raw[2], &e.Data)
+}
+
+// Writer graba una sesión de terminal en formato asciicast v2.
+// Es seguro usarlo desde varias goroutines (salida y entrada van por separado).
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	start   time.Time
+	now     func() time.Time
+	pending map[string][]byte // Bytes UTF-8 incompletos por tipo de evento
+	last    float64
+}
+
+// NewWriter escribe el header y devuelve un Writer que toma el tiempo desde ahora
+func NewWriter(w io.Writer, h Header) (*Writer, error) {
+	return newWriter(w, h, time.Now)
+}
+
+func newWriter(w io.Writer, h Header, now func() time.Time) (*Writer, error) {
+	start := now()
+	h.Version = 2
+	if h.Timestamp == 0 {
+		h.Timestamp = start.Unix()
+	}
+	line, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(append(line, '\n')); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, start: start, now: now, pending: make(map[string][]byte)}, nil
+}
+
+// Output graba salida de la terminal
+func (r *Writer) Output(data []byte) error {
+	return r.write(EventOutput, data)
+}
+
+// Input graba lo que se tipeó
+func (r *Writer) Input(data []byte) error {
+	return r.write(EventInput, data)
+}
+
+// Resize graba un cambio de tamaño
+func (r *Writer) Resize(cols, rows uint) error {
+	return r.write(EventResize, []byte(fmt.Sprintf("%dx%d", cols, rows)))
+}
+
+// Duration devuelve el tiempo del último evento grabado
+func (r *Writer) Duration() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return time.Duration(r.last * float64(time.Second))
+}
+
+// write agrega un evento. Un carácter UTF-8 partido entre dos lecturas se guarda
+// hasta completarse: asciicast exige texto válido.
+func (r *Writer) write(kind string, data []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	buf := append(r.pending[kind], data...)
+	cut := IncompleteSuffix(buf)
+	r.pending[kind] = append([]byte(nil), buf[len(buf)-cut:]...)
+	buf = buf[:len(buf)-cut]
+	if len(buf) == 0 {
+		return nil
+	}
+
+	r.last = r.now().Sub(r.start).Seconds()
+	text, err := json.Marshal(string(buf))
+	if err != nil {
+		return err
+	}
+	line := fmt.Sprintf("[%s, %q, %s]\n", strconv.FormatFloat(r.last, 'f', 6, 64), kind, text)
+	_, err = io.WriteString(r.w, line)
+	return err
+}
+
+// IncompleteSuffix devuelve cuántos bytes finales son el comienzo de un carácter UTF-8 sin terminar
+func IncompleteSuffix(b []byte) int {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(b); i++ {
+		c := b[len(b)-i]
+		if c < utf8.RuneSelf {
+			return 0 // ASCII: no hay nada pendiente
+		}
+		if utf8.RuneStart(c) {
+			if utf8.FullRune(b[len(b)-i:]) {
+				return 0
+			}
+			return i
+		}
+	}
+	return 0
+}
+
+// Reader lee un asciicast v2 evento por evento
+type Reader struct {
+	Header Header
+	sc     *bufio.Scanner
+}
+
+// NewReader lee el header de un asciicast v2
+func NewReader(r io.Reader) (*Reader, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	if !sc.Scan() {
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("asciicast vacío")
+	}
+
+	var h Header
+	if err := json.Unmarshal(sc.Bytes(), &h); err != nil {
+		return nil, fmt.Errorf("header inválido: %v", err)
+	}
+	if h.Version != 2 {
+		return nil, fmt.Errorf("versión de asciicast %d no soportada", h.Version)
+	}
+	return &Reader{Header: h, sc: sc}, nil
+}
+
+// Next devuelve el siguiente evento, o io.EOF al terminar
+func (r *Reader) Next() (Event, error) {
+	for r.sc.Scan() {
+		line := strings.TrimSpace(r.sc.Text())
+		if line == "" {
+			continue
+		}
+		var e Event
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return e, fmt.Errorf("evento inválido: %v", err)
+		}
+		return e, nil
+	}
+	if err := r.sc.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+// ParseSize decodifica el dato de un evento de resize ("COLSxROWS")
+func ParseSize(data string) (cols, rows uint, err error) {
+	if _, err := fmt.Sscanf(data, "%dx%d", &cols, &rows); err != nil {
+		return 0, 0, fmt.Errorf("tamaño inválido %q", data)
+	}
+	return cols, rows, nil
+}
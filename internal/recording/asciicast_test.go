@@ -0,0 +1,84 @@
+package recording
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+// fakeClock avanza medio segundo en cada lectura
+func fakeClock() func() time.Time {
+	t := time.Unix(1700000000, 0)
+	return func() time.Time {
+		cur := t
+		t = t.Add(500 * time.Millisecond)
+		return cur
+	}
+}
+
+// TestWriterRoundTrip graba una sesión y la vuelve a leer
+func TestWriterRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := newWriter(&buf, Header{Width: 80, Height: 24, Title: "r1"}, fakeClock())
+	if err != nil {
+		t.Fatalf("Error creando writer: %v", err)
+	}
+
+	_ = w.Input([]byte("show ip route\r"))
+	_ = w.Resize(120, 40)
+	_ = w.Output([]byte("Codes: K - kernel\r\n"))
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Error leyendo header: %v", err)
+	}
+	if r.Header.Version != 2 || r.Header.Width != 80 || r.Header.Timestamp != 1700000000 {
+		t.Errorf("Header inesperado: %+v", r.Header)
+	}
+
+	want := []Event{
+		{Time: 0.5, Type: EventInput, Data: "show ip route\r"},
+		{Time: 1, Type: EventResize, Data: "120x40"},
+		{Time: 1.5, Type: EventOutput, Data: "Codes: K - kernel\r\n"},
+	}
+	for i, exp := range want {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("Evento %d: %v", i, err)
+		}
+		if got != exp {
+			t.Errorf("Evento %d: se esperaba %+v, se obtuvo %+v", i, exp, got)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Se esperaba io.EOF, se obtuvo %v", err)
+	}
+}
+
+// TestWriterSplitUTF8 verifica que un carácter partido entre lecturas no se corrompe
+func TestWriterSplitUTF8(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := newWriter(&buf, Header{Width: 80, Height: 24}, fakeClock())
+
+	ene := []byte("ñ") // 0xC3 0xB1
+	_ = w.Output([]byte{'a', ene[0]})
+	_ = w.Output([]byte{ene[1], 'b'})
+
+	r, _ := NewReader(&buf)
+	first, _ := r.Next()
+	second, _ := r.Next()
+	if first.Data != "a" || second.Data != "ñb" {
+		t.Errorf("Se esperaba \"a\" y \"ñb\", se obtuvo %q y %q", first.Data, second.Data)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cols, rows, err := ParseSize("132x43")
+	if err != nil || cols != 132 || rows != 43 {
+		t.Errorf("Se obtuvo %dx%d (err %v)", cols, rows, err)
+	}
+	if _, _, err := ParseSize("grande"); err == nil {
+		t.Errorf("Se esperaba error para un tamaño inválido")
+	}
+}
@@ -32,7 +32,7 @@ func NewGormRepository(driver string, dsn string) (*GormRepository, error) {
 	}
 
 	// Auto Migrate models
-	err = db.AutoMigrate(&models.Node{}, &models.Link{})
+	err = db.AutoMigrate(&models.Topology{}, &models.Node{}, &models.Link{}, &models.InterfaceAddress{}, &models.ConfigSnapshot{}, &models.TerminalRecording{}, &models.CaptureSession{})
 	if err != nil {
 		return nil, fmt.Errorf("failed to migrate database: %v", err)
 	}
@@ -40,6 +40,40 @@ func NewGormRepository(driver string, dsn string) (*GormRepository, error) {
 	return &GormRepository{db: db}, nil
 }
 
+func (r *GormRepository) SaveTopology(topo models.Topology) error {
+	return r.db.Save(&topo).Error
+}
+
+func (r *GormRepository) GetTopology(id string) (models.Topology, bool) {
+	var topo models.Topology
+	if err := r.db.First(&topo, "id = ?", id).Error; err != nil {
+		return models.Topology{}, false
+	}
+	return topo, true
+}
+
+func (r *GormRepository) DeleteTopology(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.Link{}, "topology_id = ?", id).Error; err != nil {
+			return err
+		}
+		nodeIDs := tx.Model(&models.Node{}).Select("id").Where("topology_id = ?", id)
+		if err := tx.Delete(&models.InterfaceAddress{}, "node_id IN (?)", nodeIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.Node{}, "topology_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Topology{}, "id = ?", id).Error
+	})
+}
+
+func (r *GormRepository) ListTopologies() ([]models.Topology, error) {
+	var topologies []models.Topology
+	err := r.db.Order("created_at").Find(&topologies).Error
+	return topologies, err
+}
+
 func (r *GormRepository) SaveNode(node models.Node) error {
 	return r.db.Save(&node).Error
 }
@@ -53,7 +87,12 @@ func (r *GormRepository) GetNode(id string) (models.Node, bool) {
 }
 
 func (r *GormRepository) DeleteNode(id string) error {
-	return r.db.Delete(&models.Node{}, "id = ?", id).Error
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&models.InterfaceAddress{}, "node_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Node{}, "id = ?", id).Error
+	})
 }
 
 func (r *GormRepository) ListNodes() ([]models.Node, error) {
@@ -62,6 +101,12 @@ func (r *GormRepository) ListNodes() ([]models.Node, error) {
 	return nodes, err
 }
 
+func (r *GormRepository) ListNodesByTopology(topologyID string) ([]models.Node, error) {
+	var nodes []models.Node
+	err := r.db.Find(&nodes, "topology_id = ?", topologyID).Error
+	return nodes, err
+}
+
 func (r *GormRepository) SaveLink(link models.Link) error {
 	return r.db.Save(&link).Error
 }
@@ -84,10 +129,145 @@ func (r *GormRepository) ListLinks() ([]models.Link, error) {
 	return links, err
 }
 
+func (r *GormRepository) ListLinksByTopology(topologyID string) ([]models.Link, error) {
+	var links []models.Link
+	err := r.db.Find(&links, "topology_id = ?", topologyID).Error
+	return links, err
+}
+
+func (r *GormRepository) AddAddress(addr models.InterfaceAddress) (models.InterfaceAddress, error) {
+	addr.ID = 0 // Autoincremental
+	err := r.db.Create(&addr).Error
+	return addr, err
+}
+
+func (r *GormRepository) GetAddress(id uint) (models.InterfaceAddress, bool) {
+	var addr models.InterfaceAddress
+	if err := r.db.First(&addr, "id = ?", id).Error; err != nil {
+		return models.InterfaceAddress{}, false
+	}
+	return addr, true
+}
+
+func (r *GormRepository) DeleteAddress(id uint) error {
+	return r.db.Delete(&models.InterfaceAddress{}, "id = ?", id).Error
+}
+
+func (r *GormRepository) ListAddresses(nodeID string) ([]models.InterfaceAddress, error) {
+	var addrs []models.InterfaceAddress
+	err := r.db.Order("id").Find(&addrs, "node_id = ?", nodeID).Error
+	return addrs, err
+}
+
+func (r *GormRepository) DeleteLinkAddresses(linkID string) error {
+	return r.db.Delete(&models.InterfaceAddress{}, "link_id = ?", linkID).Error
+}
+
+func (r *GormRepository) AddSnapshot(snap models.ConfigSnapshot) (models.ConfigSnapshot, error) {
+	snap.ID = 0 // Autoincremental
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var last int
+		if err := tx.Model(&models.ConfigSnapshot{}).Where("topology_id = ? AND node_id = ?", snap.TopologyID, snap.NodeID).
+			Select("COALESCE(MAX(version), 0)").Scan(&last).Error; err != nil {
+			return err
+		}
+		snap.Version = last + 1
+		return tx.Create(&snap).Error
+	})
+	return snap, err
+}
+
+func (r *GormRepository) GetSnapshot(id uint) (models.ConfigSnapshot, bool) {
+	var snap models.ConfigSnapshot
+	if err := r.db.First(&snap, "id = ?", id).Error; err != nil {
+		return models.ConfigSnapshot{}, false
+	}
+	return snap, true
+}
+
+func (r *GormRepository) DeleteSnapshot(id uint) error {
+	return r.db.Delete(&models.ConfigSnapshot{}, "id = ?", id).Error
+}
+
+func (r *GormRepository) ListSnapshots(topologyID, nodeID string) ([]models.ConfigSnapshot, error) {
+	var snaps []models.ConfigSnapshot
+	err := r.db.Order("version").Find(&snaps, "topology_id = ? AND node_id = ?", topologyID, nodeID).Error
+	return snaps, err
+}
+
+func (r *GormRepository) AddRecording(rec models.TerminalRecording) (models.TerminalRecording, error) {
+	rec.ID = 0 // Autoincremental
+	err := r.db.Create(&rec).Error
+	return rec, err
+}
+
+func (r *GormRepository) SaveRecording(rec models.TerminalRecording) error {
+	return r.db.Save(&rec).Error
+}
+
+func (r *GormRepository) GetRecording(id uint) (models.TerminalRecording, bool) {
+	var rec models.TerminalRecording
+	if err := r.db.First(&rec, "id = ?", id).Error; err != nil {
+		return models.TerminalRecording{}, false
+	}
+	return rec, true
+}
+
+func (r *GormRepository) DeleteRecording(id uint) error {
+	return r.db.Delete(&models.TerminalRecording{}, "id = ?", id).Error
+}
+
+func (r *GormRepository) ListRecordings(nodeID, user string) ([]models.TerminalRecording, error) {
+	var recs []models.TerminalRecording
+	q := r.db.Order("id")
+	if nodeID != "" {
+		q = q.Where("node_id = ?", nodeID)
+	}
+	if user != "" {
+		q = q.Where("username = ?", user)
+	}
+	err := q.Find(&recs).Error
+	return recs, err
+}
+
+func (r *GormRepository) AddCapture(cs models.CaptureSession) (models.CaptureSession, error) {
+	cs.ID = 0 // Autoincremental
+	err := r.db.Create(&cs).Error
+	return cs, err
+}
+
+func (r *GormRepository) SaveCapture(cs models.CaptureSession) error {
+	return r.db.Save(&cs).Error
+}
+
+func (r *GormRepository) GetCapture(id uint) (models.CaptureSession, bool) {
+	var cs models.CaptureSession
+	if err := r.db.First(&cs, "id = ?", id).Error; err != nil {
+		return models.CaptureSession{}, false
+	}
+	return cs, true
+}
+
+func (r *GormRepository) DeleteCapture(id uint) error {
+	return r.db.Delete(&models.CaptureSession{}, "id = ?", id).Error
+}
+
+func (r *GormRepository) ListCaptures(topologyID string) ([]models.CaptureSession, error) {
+	var list []models.CaptureSession
+	q := r.db.Order("id")
+	if topologyID != "" {
+		q = q.Where("topology_id = ?", topologyID)
+	}
+	err := q.Find(&list).Error
+	return list, err
+}
+
 func (r *GormRepository) ClearAll() error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
 		if err := tx.Exec("DELETE FROM nodes").Error; err != nil { return err }
 		if err := tx.Exec("DELETE FROM links").Error; err != nil { return err }
+		if err := tx.Exec("DELETE FROM topologies").Error; err != nil { return err }
+		if err := tx.Exec("DELETE FROM interface_addresses").Error; err != nil { return err }
 		return nil
 	})
 }
@@ -3,22 +3,85 @@ package storage
 import (
 	"fmt"
 	"open-veth/internal/models"
+	"sort"
 	"sync"
 )
 
 type MemoryRepository struct {
-	nodes map[string]models.Node
-	links map[string]models.Link
-	mu    sync.RWMutex
+	topologies map[string]models.Topology
+	nodes      map[string]models.Node
+	links      map[string]models.Link
+	addresses  map[uint]models.InterfaceAddress
+	nextAddrID uint
+	snapshots  map[uint]models.ConfigSnapshot
+	nextSnapID uint
+	recordings map[uint]models.TerminalRecording
+	nextRecID  uint
+	captures   map[uint]models.CaptureSession
+	nextCapID  uint
+	mu         sync.RWMutex
 }
 
 func NewMemoryRepository() *MemoryRepository {
 	return &MemoryRepository{
-		nodes: make(map[string]models.Node),
-		links: make(map[string]models.Link),
+		topologies: make(map[string]models.Topology),
+		nodes:      make(map[string]models.Node),
+		links:      make(map[string]models.Link),
+		addresses:  make(map[uint]models.InterfaceAddress),
+		snapshots:  make(map[uint]models.ConfigSnapshot),
+		recordings: make(map[uint]models.TerminalRecording),
+		captures:   make(map[uint]models.CaptureSession),
 	}
 }
 
+// --- Topologías ---
+
+func (m *MemoryRepository) SaveTopology(topo models.Topology) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	topo.Nodes, topo.Links = nil, nil
+	m.topologies[topo.ID] = topo
+	return nil
+}
+
+func (m *MemoryRepository) GetTopology(id string) (models.Topology, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	t, ok := m.topologies[id]
+	return t, ok
+}
+
+func (m *MemoryRepository) DeleteTopology(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.topologies[id]; !ok {
+		return fmt.Errorf("topología no encontrada")
+	}
+	delete(m.topologies, id)
+	for nid, n := range m.nodes {
+		if n.TopologyID == id {
+			delete(m.nodes, nid)
+			m.deleteNodeAddresses(nid)
+		}
+	}
+	for lid, l := range m.links {
+		if l.TopologyID == id {
+			delete(m.links, lid)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryRepository) ListTopologies() ([]models.Topology, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]models.Topology, 0, len(m.topologies))
+	for _, t := range m.topologies {
+		list = append(list, t)
+	}
+	return list, nil
+}
+
 // --- Nodos ---
 
 func (m *MemoryRepository) SaveNode(node models.Node) error {
@@ -42,6 +105,7 @@ func (m *MemoryRepository) DeleteNode(id string) error {
 		return fmt.Errorf("nodo no encontrado")
 	}
 	delete(m.nodes, id)
+	m.deleteNodeAddresses(id)
 	return nil
 }
 
@@ -55,6 +119,18 @@ func (m *MemoryRepository) ListNodes() ([]models.Node, error) {
 	return list, nil
 }
 
+func (m *MemoryRepository) ListNodesByTopology(topologyID string) ([]models.Node, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]models.Node, 0)
+	for _, n := range m.nodes {
+		if n.TopologyID == topologyID {
+			list = append(list, n)
+		}
+	}
+	return list, nil
+}
+
 // --- Links ---
 
 func (m *MemoryRepository) SaveLink(link models.Link) error {
@@ -91,10 +167,234 @@ func (m *MemoryRepository) ListLinks() ([]models.Link, error) {
 	return list, nil
 }
 
+func (m *MemoryRepository) ListLinksByTopology(topologyID string) ([]models.Link, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]models.Link, 0)
+	for _, l := range m.links {
+		if l.TopologyID == topologyID {
+			list = append(list, l)
+		}
+	}
+	return list, nil
+}
+
+// --- Direcciones ---
+
+func (m *MemoryRepository) AddAddress(addr models.InterfaceAddress) (models.InterfaceAddress, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextAddrID++
+	addr.ID = m.nextAddrID
+	m.addresses[addr.ID] = addr
+	return addr, nil
+}
+
+func (m *MemoryRepository) GetAddress(id uint) (models.InterfaceAddress, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	a, ok := m.addresses[id]
+	return a, ok
+}
+
+func (m *MemoryRepository) DeleteAddress(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.addresses[id]; !ok {
+		return fmt.Errorf("dirección no encontrada")
+	}
+	delete(m.addresses, id)
+	return nil
+}
+
+func (m *MemoryRepository) ListAddresses(nodeID string) ([]models.InterfaceAddress, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]models.InterfaceAddress, 0)
+	for _, a := range m.addresses {
+		if a.NodeID == nodeID {
+			list = append(list, a)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (m *MemoryRepository) DeleteLinkAddresses(linkID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, a := range m.addresses {
+		if a.LinkID == linkID {
+			delete(m.addresses, id)
+		}
+	}
+	return nil
+}
+
+// deleteNodeAddresses asume que el lock ya está tomado
+func (m *MemoryRepository) deleteNodeAddresses(nodeID string) {
+	for id, a := range m.addresses {
+		if a.NodeID == nodeID {
+			delete(m.addresses, id)
+		}
+	}
+}
+
+// --- Snapshots ---
+
+func (m *MemoryRepository) AddSnapshot(snap models.ConfigSnapshot) (models.ConfigSnapshot, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snap.Version = 1
+	for _, s := range m.snapshots {
+		if s.TopologyID == snap.TopologyID && s.NodeID == snap.NodeID && s.Version >= snap.Version {
+			snap.Version = s.Version + 1
+		}
+	}
+	m.nextSnapID++
+	snap.ID = m.nextSnapID
+	m.snapshots[snap.ID] = snap
+	return snap, nil
+}
+
+func (m *MemoryRepository) GetSnapshot(id uint) (models.ConfigSnapshot, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	s, ok := m.snapshots[id]
+	return s, ok
+}
+
+func (m *MemoryRepository) DeleteSnapshot(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.snapshots[id]; !ok {
+		return fmt.Errorf("snapshot no encontrado")
+	}
+	delete(m.snapshots, id)
+	return nil
+}
+
+func (m *MemoryRepository) ListSnapshots(topologyID, nodeID string) ([]models.ConfigSnapshot, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]models.ConfigSnapshot, 0)
+	for _, s := range m.snapshots {
+		if s.TopologyID == topologyID && s.NodeID == nodeID {
+			list = append(list, s)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	return list, nil
+}
+
+// --- Grabaciones ---
+
+func (m *MemoryRepository) AddRecording(rec models.TerminalRecording) (models.TerminalRecording, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextRecID++
+	rec.ID = m.nextRecID
+	m.recordings[rec.ID] = rec
+	return rec, nil
+}
+
+func (m *MemoryRepository) SaveRecording(rec models.TerminalRecording) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.recordings[rec.ID]; !ok {
+		return fmt.Errorf("grabación no encontrada")
+	}
+	m.recordings[rec.ID] = rec
+	return nil
+}
+
+func (m *MemoryRepository) GetRecording(id uint) (models.TerminalRecording, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	r, ok := m.recordings[id]
+	return r, ok
+}
+
+func (m *MemoryRepository) DeleteRecording(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.recordings[id]; !ok {
+		return fmt.Errorf("grabación no encontrada")
+	}
+	delete(m.recordings, id)
+	return nil
+}
+
+func (m *MemoryRepository) ListRecordings(nodeID, user string) ([]models.TerminalRecording, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]models.TerminalRecording, 0)
+	for _, r := range m.recordings {
+		if (nodeID == "" || r.NodeID == nodeID) && (user == "" || r.User == user) {
+			list = append(list, r)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+// --- Capturas ---
+
+func (m *MemoryRepository) AddCapture(cs models.CaptureSession) (models.CaptureSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextCapID++
+	cs.ID = m.nextCapID
+	m.captures[cs.ID] = cs
+	return cs, nil
+}
+
+func (m *MemoryRepository) SaveCapture(cs models.CaptureSession) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.captures[cs.ID]; !ok {
+		return fmt.Errorf("captura no encontrada")
+	}
+	m.captures[cs.ID] = cs
+	return nil
+}
+
+func (m *MemoryRepository) GetCapture(id uint) (models.CaptureSession, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	cs, ok := m.captures[id]
+	return cs, ok
+}
+
+func (m *MemoryRepository) DeleteCapture(id uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.captures[id]; !ok {
+		return fmt.Errorf("captura no encontrada")
+	}
+	delete(m.captures, id)
+	return nil
+}
+
+func (m *MemoryRepository) ListCaptures(topologyID string) ([]models.CaptureSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	list := make([]models.CaptureSession, 0)
+	for _, cs := range m.captures {
+		if topologyID == "" || cs.TopologyID == topologyID {
+			list = append(list, cs)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
 func (m *MemoryRepository) ClearAll() error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.topologies = make(map[string]models.Topology)
 	m.nodes = make(map[string]models.Node)
 	m.links = make(map[string]models.Link)
+	m.addresses = make(map[uint]models.InterfaceAddress)
 	return nil
 }
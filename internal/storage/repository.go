@@ -4,18 +4,53 @@ import "open-veth/internal/models"
 
 // Repository define las operaciones de persistencia
 type Repository interface {
+	// Topologías (laboratorios)
+	SaveTopology(topo models.Topology) error
+	GetTopology(id string) (models.Topology, bool)
+	DeleteTopology(id string) error // Elimina también sus nodos y links
+	ListTopologies() ([]models.Topology, error)
+
 	// Nodos
 	SaveNode(node models.Node) error
 	GetNode(id string) (models.Node, bool)
-	DeleteNode(id string) error
+	DeleteNode(id string) error // Elimina también sus direcciones
 	ListNodes() ([]models.Node, error)
+	ListNodesByTopology(topologyID string) ([]models.Node, error)
 
 	// Links
 	SaveLink(link models.Link) error
 	GetLink(id string) (models.Link, bool)
 	DeleteLink(id string) error
 	ListLinks() ([]models.Link, error)
+	ListLinksByTopology(topologyID string) ([]models.Link, error)
 	
+	// Direcciones IP de interfaces
+	AddAddress(addr models.InterfaceAddress) (models.InterfaceAddress, error) // Asigna el ID
+	GetAddress(id uint) (models.InterfaceAddress, bool)
+	DeleteAddress(id uint) error
+	ListAddresses(nodeID string) ([]models.InterfaceAddress, error)
+	DeleteLinkAddresses(linkID string) error // Libera las asignaciones IPAM de un link
+
+	// Snapshots de configuración (no se borran con el nodo ni con ClearAll)
+	AddSnapshot(snap models.ConfigSnapshot) (models.ConfigSnapshot, error) // Asigna ID y versión (por laboratorio y nodo)
+	GetSnapshot(id uint) (models.ConfigSnapshot, bool)
+	DeleteSnapshot(id uint) error
+	ListSnapshots(topologyID, nodeID string) ([]models.ConfigSnapshot, error) // Ordenados por versión
+
+	// Grabaciones de terminal (no se borran con el nodo ni con ClearAll)
+	AddRecording(rec models.TerminalRecording) (models.TerminalRecording, error) // Asigna el ID
+	SaveRecording(rec models.TerminalRecording) error
+	GetRecording(id uint) (models.TerminalRecording, bool)
+	DeleteRecording(id uint) error
+	ListRecordings(nodeID, user string) ([]models.TerminalRecording, error) // Filtros vacíos = todos
+
+	// Sesiones de captura (no se borran con el laboratorio ni con ClearAll)
+	AddCapture(cs models.CaptureSession) (models.CaptureSession, error) // Asigna el ID
+	SaveCapture(cs models.CaptureSession) error
+	GetCapture(id uint) (models.CaptureSession, bool)
+	DeleteCapture(id uint) error
+	ListCaptures(topologyID string) ([]models.CaptureSession, error) // Vacío = todas
+
 	// Limpieza
 	ClearAll() error
 }